@@ -0,0 +1,1011 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-task/internal/app"
+	"go-task/internal/paths"
+	"go-task/internal/store"
+	"go-task/internal/task"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// command はコマンドラインから実行できるサブコマンドを表します。
+type command struct {
+	usage       string
+	description string
+	run         func(args []string, stdout io.Writer) error
+}
+
+// commands はサブコマンド名とその実装の対応表です。
+var commands = map[string]command{
+	"undo": {
+		usage:       "undo",
+		description: "Undo the last operation",
+		run:         runUndo,
+	},
+	"redo": {
+		usage:       "redo",
+		description: "Redo the last undone operation",
+		run:         runRedo,
+	},
+	"depend": {
+		usage:       "depend <task-id> [dep-id...]",
+		description: "Set the tasks a task depends on (none clears)",
+		run:         runDepend,
+	},
+	"snooze": {
+		usage:       "snooze <task-id> <period>",
+		description: "Hide a task until 1h, tomorrow, next-week, YYYY-MM-DD, or 'off'",
+		run:         runSnooze,
+	},
+	"template": {
+		usage:       "template <list|save|use|delete> ...",
+		description: "Manage task templates (see 'template help')",
+		run:         runTemplate,
+	},
+	"tag": {
+		usage:       "tag <list|register|unregister|strict> ...",
+		description: "Manage the tag registry (see 'tag help')",
+		run:         runTag,
+	},
+	"encryption": {
+		usage:       "encryption <status|enable|rotate|disable>",
+		description: "Encrypt the task file and backups with a passphrase",
+		run:         runEncryption,
+	},
+	"init": {
+		usage:       "init [--local]",
+		description: "Create the data directory, or .go-task/tasks.json here with --local",
+		run:         runInit,
+	},
+	"backup": {
+		usage:       "backup <list|verify|diff|restore> ...",
+		description: "Inspect backups and restore tasks from them (see 'backup help')",
+		run:         runBackup,
+	},
+	"recover": {
+		usage:       "recover [--dry-run|--no-backup]",
+		description: "Recover a corrupted task file from the newest valid backup",
+		run:         runRecover,
+	},
+	"git": {
+		usage:       "git <init|log|revert> ...",
+		description: "Record every save as a git commit in the data directory (see 'git help')",
+		run:         runGit,
+	},
+	"sync": {
+		usage:       "sync <dir|conflicts|resolve> ...",
+		description: "Merge tasks with a copy in another directory (see 'sync help')",
+		run:         runSync,
+	},
+	"csv": {
+		usage:       "csv <export|import> <file> ...",
+		description: "Export tasks to or import tasks from a CSV file (see 'csv help')",
+		run:         runCSV,
+	},
+	"compact": {
+		usage:       "compact",
+		description: "Write the task file as a single snapshot and clear the journal",
+		run:         runCompact,
+	},
+	"workspace": {
+		usage:       "workspace <list|create|switch|rename|delete> ...",
+		description: "Manage named task lists (see 'workspace help')",
+		run:         runWorkspace,
+	},
+}
+
+// commandOrder はヘルプに表示するサブコマンドの順序です。
+var commandOrder = []string{"undo", "redo", "depend", "snooze", "template", "tag", "workspace", "backup", "recover", "git", "sync", "csv", "compact", "init", "encryption"}
+
+// dataDirFlag はデータ、設定、ログの保存先を指定するグローバルフラグです。
+const dataDirFlag = "--data-dir"
+
+// parseGlobalFlags は引数の先頭にあるグローバルフラグを処理し、残りの引数を返します。
+// "--data-dir <dir>" と "--data-dir=<dir>" の両方の形式を受け付けます。
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 {
+		var dir string
+		switch {
+		case args[0] == dataDirFlag:
+			if len(args) < 2 || args[1] == "" {
+				return nil, fmt.Errorf("%s requires a directory", dataDirFlag)
+			}
+			dir, args = args[1], args[2:]
+		case strings.HasPrefix(args[0], dataDirFlag+"="):
+			dir, args = strings.TrimPrefix(args[0], dataDirFlag+"="), args[1:]
+			if dir == "" {
+				return nil, fmt.Errorf("%s requires a directory", dataDirFlag)
+			}
+		default:
+			return args, nil
+		}
+		if err := paths.SetDataDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// runCommand はサブコマンドを実行し、プロセスの終了コードを返します。
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	name := args[0]
+	if name == "help" || name == "--help" || name == "-h" {
+		fmt.Fprint(stdout, generateCommandLineHelp())
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n%s", name, generateCommandLineHelp())
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		if appErr, ok := err.(*app.AppError); ok && appErr.Type == app.ErrTypePassphrase {
+			fmt.Fprintf(stderr, "Set %s to the passphrase of the task file.\n", passphraseEnv)
+		}
+		if appErr, ok := err.(*app.AppError); ok && appErr.Type == app.ErrTypeGit {
+			fmt.Fprintln(stderr, "The tasks were saved. The next save commits them once git works again.")
+		}
+		if appErr, ok := err.(*app.AppError); ok && appErr.Type == app.ErrTypeCorrupted {
+			fmt.Fprintln(stderr, "Run 'go-task recover' to recover the task file from the newest valid backup.")
+		}
+		return 1
+	}
+	return 0
+}
+
+// generateCommandLineHelp はコマンドラインヘルプテキストを生成します。
+func generateCommandLineHelp() string {
+	var b strings.Builder
+	b.WriteString("Usage:\n")
+	b.WriteString("  go-task [--data-dir <dir>]              Start the interactive task manager\n")
+	b.WriteString("  go-task [--data-dir <dir>] <command>    Run a command\n")
+	b.WriteString("\nThe data directory defaults to $GO_TASK_HOME, then ~/.go-task or the XDG base directories.\n")
+	b.WriteString("\nCommands:\n")
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		b.WriteString(fmt.Sprintf("  %-30s %s\n", cmd.usage, cmd.description))
+	}
+	b.WriteString(fmt.Sprintf("  %-30s %s\n", "help", "Show this help message"))
+	return b.String()
+}
+
+// runUndo は直前の操作を取り消します。
+func runUndo(args []string, stdout io.Writer) error {
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	op, err := a.Undo()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Undid %s (%d task(s))\n", op.Name, len(op.Before))
+	return saveIfNeeded(a)
+}
+
+// runRedo は直前に取り消した操作をやり直します。
+func runRedo(args []string, stdout io.Writer) error {
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	op, err := a.Redo()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Redid %s (%d task(s))\n", op.Name, len(op.After))
+	return saveIfNeeded(a)
+}
+
+// saveIfNeeded は自動保存が無効な場合にタスクデータを明示的に保存します。
+// コマンドはプロセス終了時に変更が失われないよう、常に保存してから終了します。
+func saveIfNeeded(a *app.App) error {
+	if a.Tasks.Settings.AutoSave {
+		return nil
+	}
+	return a.Save()
+}
+
+// runDepend はタスクが依存するタスクを設定します。
+func runDepend(args []string, stdout io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: go-task depend <task-id> [dep-id...]")
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	t, err := a.SetDependencies(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s now depends on %d task(s)\n", t.Title, len(t.Depends))
+	return saveIfNeeded(a)
+}
+
+// runSnooze はタスクを指定された期間まで待機状態にします。"off" を指定すると待機を解除します。
+func runSnooze(args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: go-task snooze <task-id> <period>")
+	}
+	var until *time.Time
+	if args[1] != "off" {
+		t, err := app.SnoozeUntil(args[1], time.Now())
+		if err != nil {
+			return err
+		}
+		until = &t
+	}
+
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	t, err := a.SnoozeTask(args[0], until)
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		fmt.Fprintf(stdout, "%s is hidden until %s\n", t.Title, until.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Fprintf(stdout, "%s is visible again\n", t.Title)
+	}
+	return saveIfNeeded(a)
+}
+
+// templateUsage は template サブコマンドの使い方です。
+const templateUsage = `usage:
+  go-task template list                          List saved templates and their variables
+  go-task template save <name> <task-id>         Save an existing task as a template
+  go-task template use <name> [var=value...]     Create a task from a template
+  go-task template delete <name>                 Delete a template`
+
+// runTemplate はタスクテンプレートの一覧表示・保存・使用・削除を行います。
+func runTemplate(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, templateUsage)
+		return nil
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		templates, err := a.GetTemplates()
+		if err != nil {
+			return err
+		}
+		if len(templates) == 0 {
+			fmt.Fprintln(stdout, "No templates saved.")
+		}
+		for _, tpl := range templates {
+			vars, _ := tpl.Variables()
+			fmt.Fprintf(stdout, "%s\t%s", tpl.Name, tpl.Title)
+			if len(vars) > 0 {
+				fmt.Fprintf(stdout, "\t(variables: %s)", strings.Join(vars, ", "))
+			}
+			fmt.Fprintln(stdout)
+		}
+		return nil
+
+	case args[0] == "save" && len(args) == 3:
+		tpl, err := a.SaveTaskAsTemplate(args[2], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Saved template %s\n", tpl.Name)
+		return nil
+
+	case args[0] == "use" && len(args) >= 2:
+		tpl, err := a.GetTemplate(args[1])
+		if err != nil {
+			return err
+		}
+		vars := make(map[string]string)
+		for _, arg := range args[2:] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("invalid variable %q, expected var=value", arg)
+			}
+			vars[key] = value
+		}
+		names, err := tpl.Variables()
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for _, name := range names {
+			if _, ok := vars[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+		}
+
+		t, err := a.AddTaskFromTemplate(tpl.Name, vars)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created %s (%s)\n", t.Title, t.ID)
+		return saveIfNeeded(a)
+
+	case args[0] == "delete" && len(args) == 2:
+		if err := a.DeleteTemplate(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Deleted template %s\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("%s", templateUsage)
+}
+
+// tagUsage は tag サブコマンドの使い方です。
+const tagUsage = `usage:
+  go-task tag list                                              List tags with their registry settings
+  go-task tag register <name> [color=N] [description=D] [hidden=true]  Register a tag (color: 0-255 or #RRGGBB)
+  go-task tag unregister <name>                                 Remove a tag from the registry
+  go-task tag strict <on|off>                                   Warn when unregistered tags are used`
+
+// runTag はタグレジストリの一覧表示・登録・削除と厳格モードの切り替えを行います。
+func runTag(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, tagUsage)
+		return nil
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		// 使用中のタグと、未使用の登録済みタグを合わせて表示する
+		tags := a.GetAllUniqueTags()
+		inUse := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			inUse[tag] = true
+		}
+		for name := range a.Tasks.TagRegistry {
+			if !inUse[name] {
+				tags = append(tags, name)
+			}
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			info, registered := a.GetTagInfo(tag)
+			status := "unregistered"
+			if registered {
+				status = fmt.Sprintf("color=%s hidden=%t", info.Color, info.Hidden)
+			}
+			fmt.Fprintf(stdout, "%s\t%s\t%s\n", tag, status, info.Description)
+		}
+		fmt.Fprintf(stdout, "strict mode: %t\n", a.Tasks.Settings.StrictTags)
+		return nil
+
+	case args[0] == "register" && len(args) >= 2:
+		info := a.Tasks.TagRegistry[args[1]]
+		for _, arg := range args[2:] {
+			key, value, ok := strings.Cut(arg, "=")
+			switch {
+			case ok && key == "color":
+				info.Color = value
+			case ok && key == "description":
+				info.Description = value
+			case ok && key == "hidden":
+				info.Hidden = value == "true"
+			default:
+				return fmt.Errorf("invalid option %q, expected color=, description= or hidden=", arg)
+			}
+		}
+		if err := a.RegisterTag(args[1], info); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Registered tag %s\n", args[1])
+		return saveIfNeeded(a)
+
+	case args[0] == "unregister" && len(args) == 2:
+		if err := a.UnregisterTag(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Unregistered tag %s\n", args[1])
+		return saveIfNeeded(a)
+
+	case args[0] == "strict" && len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+		if err := a.SetStrictTags(args[1] == "on"); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Strict tag mode %s\n", args[1])
+		return saveIfNeeded(a)
+	}
+	return fmt.Errorf("%s", tagUsage)
+}
+
+// workspaceUsage は workspace サブコマンドの使い方です。
+const workspaceUsage = `usage:
+  go-task workspace list                  List workspaces (* marks the current one)
+  go-task workspace create <name>         Create an empty workspace
+  go-task workspace switch <name>         Open <name> from now on
+  go-task workspace rename <old> <new>    Rename a workspace
+  go-task workspace delete <name>         Delete a workspace with its tasks and backups`
+
+// runWorkspace はワークスペースの一覧表示・作成・切り替え・名前の変更・削除を行います。
+func runWorkspace(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, workspaceUsage)
+		return nil
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		current, err := app.CurrentWorkspace()
+		if err != nil {
+			return err
+		}
+		names, err := app.ListWorkspaces()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			fmt.Fprintf(stdout, "%s %s\n", marker, name)
+		}
+		return nil
+
+	case args[0] == "create" && len(args) == 2:
+		if err := app.CreateWorkspace(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created workspace %s\n", args[1])
+		return nil
+
+	case args[0] == "switch" && len(args) == 2:
+		if _, err := app.SwitchWorkspace(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Switched to workspace %s\n", args[1])
+		return nil
+
+	case args[0] == "rename" && len(args) == 3:
+		if err := app.RenameWorkspace(args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Renamed workspace %s to %s\n", args[1], args[2])
+		return nil
+
+	case args[0] == "delete" && len(args) == 2:
+		if err := app.DeleteWorkspace(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Deleted workspace %s\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("%s", workspaceUsage)
+}
+
+// backupUsage は backup サブコマンドの使い方です。
+const backupUsage = `usage:
+  go-task backup list                     List backups with their task counts
+  go-task backup verify                   Check that every backup can be read and is valid
+  go-task backup diff <name>              Show how the current tasks differ from a backup
+  go-task backup restore <name>           Replace all tasks and settings with a backup
+  go-task backup restore <name> <task-id> Restore a single task from a backup
+
+The current tasks are backed up automatically before every restore.`
+
+// runBackup はバックアップの一覧表示・検証・差分表示・復元を行います。
+func runBackup(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, backupUsage)
+		return nil
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case (args[0] == "list" || args[0] == "verify") && len(args) == 1:
+		statuses, err := a.InspectBackups()
+		if err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			fmt.Fprintln(stdout, "No backups.")
+			return nil
+		}
+		invalid := 0
+		for _, st := range statuses {
+			created := st.CreatedAt.Local().Format("2006-01-02 15:04:05")
+			switch {
+			case st.Err != nil:
+				invalid++
+				fmt.Fprintf(stdout, "%s\t%s\tINVALID: %v\n", st.Name, created, st.Err)
+			case args[0] == "verify":
+				fmt.Fprintf(stdout, "%s\t%s\tOK\n", st.Name, created)
+			default:
+				fmt.Fprintf(stdout, "%s\t%s\t%d task(s)\t%d bytes\n", st.Name, created, st.TaskCount, st.Size)
+			}
+		}
+		if args[0] == "verify" && invalid > 0 {
+			return fmt.Errorf("%d of %d backup(s) are invalid", invalid, len(statuses))
+		}
+		return nil
+
+	case args[0] == "diff" && len(args) == 2:
+		diff, err := a.DiffBackup(args[1])
+		if err != nil {
+			return err
+		}
+		if diff.Empty() {
+			fmt.Fprintln(stdout, "No differences.")
+			return nil
+		}
+		for _, t := range diff.Removed {
+			fmt.Fprintf(stdout, "- %s %s (only in backup)\n", t.ID, t.Title)
+		}
+		for _, t := range diff.Added {
+			fmt.Fprintf(stdout, "+ %s %s (not in backup)\n", t.ID, t.Title)
+		}
+		for _, m := range diff.Modified {
+			fmt.Fprintf(stdout, "~ %s %s\n", m.Current.ID, m.Current.Title)
+			for _, c := range m.Changes {
+				fmt.Fprintf(stdout, "    %s: %q -> %q\n", c.Field, c.OldValue, c.NewValue)
+			}
+		}
+		if diff.SettingsChanged {
+			fmt.Fprintln(stdout, "~ settings")
+		}
+		return nil
+
+	case args[0] == "restore" && len(args) == 2:
+		if err := a.RestoreBackupByName(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Restored %d task(s) from %s\n", len(a.Tasks.Tasks), args[1])
+		return saveIfNeeded(a)
+
+	case args[0] == "restore" && len(args) == 3:
+		t, err := a.RestoreTaskFromBackup(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Restored %s from %s\n", t.Title, args[1])
+		return saveIfNeeded(a)
+	}
+	return fmt.Errorf("%s", backupUsage)
+}
+
+// gitUsage は git サブコマンドの使い方です。
+const gitUsage = `usage:
+  go-task git init            Make the data directory a git repository and commit every save
+  go-task git log [count]     Show the commits of the task file (default: last 20)
+  go-task git revert <commit> Replace all tasks and settings with those of a past commit
+
+git must be installed. A revert is recorded as a new commit, so no history is lost.`
+
+// defaultGitLogCount は git log で表示するコミットの既定の件数です。
+const defaultGitLogCount = 20
+
+// runGit はタスクファイルの git の履歴を有効にし、コミットの一覧表示と過去のコミットへの復元を行います。
+func runGit(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, gitUsage)
+		return nil
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "init" && len(args) == 1:
+		if err := a.InitGitHistory(); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Git history enabled. Every save is now committed to the data directory.")
+		return nil
+
+	case args[0] == "log" && len(args) <= 2:
+		count := defaultGitLogCount
+		if len(args) == 2 {
+			if count, err = strconv.Atoi(args[1]); err != nil || count <= 0 {
+				return fmt.Errorf("invalid count %q", args[1])
+			}
+		}
+		commits, err := a.GitLog(count)
+		if err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			fmt.Fprintln(stdout, "No commits.")
+		}
+		for _, c := range commits {
+			fmt.Fprintf(stdout, "%.10s\t%s\t%s\n", c.Hash, c.Time.Local().Format("2006-01-02 15:04:05"), c.Subject)
+		}
+		return nil
+
+	case args[0] == "revert" && len(args) == 2:
+		if err := a.RevertToCommit(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Reverted %d task(s) to commit %s\n", len(a.Tasks.Tasks), args[1])
+		return saveIfNeeded(a)
+	}
+	return fmt.Errorf("%s", gitUsage)
+}
+
+// runRecover は破損したタスクファイルを最新の有効なバックアップと、破損したファイルから読み込めたタスクで復旧します。
+// --dry-run では復旧される内容を表示するだけで、--no-backup ではバックアップを使用しません。
+func runRecover(args []string, stdout io.Writer) error {
+	var report *store.RecoveryReport
+	var err error
+	switch {
+	case len(args) == 0:
+		report, err = app.Recover(true)
+	case len(args) == 1 && args[0] == "--no-backup":
+		report, err = app.Recover(false)
+	case len(args) == 1 && args[0] == "--dry-run":
+		report, err = app.PlanRecovery()
+	default:
+		return fmt.Errorf("usage: go-task recover [--dry-run|--no-backup]")
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Backup != "" {
+		fmt.Fprintf(stdout, "From backup %s: %d task(s)\n", report.Backup, len(report.FromBackup))
+		for _, t := range report.FromBackup {
+			fmt.Fprintf(stdout, "  %s %s\n", t.ID, t.Title)
+		}
+	} else if len(args) == 0 || args[0] == "--dry-run" {
+		fmt.Fprintln(stdout, "No valid backup found.")
+	}
+	fmt.Fprintf(stdout, "Salvaged from the corrupted file: %d task(s)\n", len(report.Salvaged))
+	for _, t := range report.Salvaged {
+		fmt.Fprintf(stdout, "  %s %s\n", t.ID, t.Title)
+	}
+	if report.QuarantinePath == "" {
+		fmt.Fprintln(stdout, "Dry run: the task file was not changed.")
+		return nil
+	}
+	fmt.Fprintf(stdout, "The corrupted file was moved to %s\n", report.QuarantinePath)
+	if report.JournalPath != "" {
+		fmt.Fprintf(stdout, "The journal was moved to %s\n", report.JournalPath)
+	}
+	return nil
+}
+
+// syncUsage は sync サブコマンドの使い方です。
+const syncUsage = `usage:
+  go-task sync <dir>|<dir>/tasks.json         Merge tasks with the task file in dir (e.g. a synced folder)
+  go-task sync conflicts                      List the conflicts found by sync
+  go-task sync resolve <task-id> local|remote Keep the local or the remote side of a conflict
+
+Fields changed on one side are merged; fields changed on both sides take the newer
+value and are reported as conflicts. Deleted tasks do not come back from the other side.
+Use ./conflicts to sync with a directory named conflicts.`
+
+// runSync は別のディレクトリのタスクデータとの同期と、同期で検出した競合の表示、解決を行います。
+func runSync(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, syncUsage)
+		return nil
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "conflicts" && len(args) == 1:
+		conflicts := a.Conflicts()
+		if len(conflicts) == 0 {
+			fmt.Fprintln(stdout, "No conflicts.")
+		}
+		for _, c := range conflicts {
+			fmt.Fprintln(stdout, formatSyncConflict(c))
+		}
+		return nil
+
+	case args[0] == "resolve" && len(args) == 3:
+		if args[2] != "local" && args[2] != "remote" {
+			return fmt.Errorf("%s", syncUsage)
+		}
+		t, err := a.ResolveConflict(args[1], args[2] == "remote")
+		if err != nil {
+			return err
+		}
+		if t != nil {
+			fmt.Fprintf(stdout, "Resolved %s: %s\n", t.ID, t.Title)
+		} else {
+			fmt.Fprintf(stdout, "Resolved %s: deleted\n", args[1])
+		}
+		return saveIfNeeded(a)
+
+	case len(args) == 1:
+		result, err := a.Sync(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Synced with %s: %d added, %d updated, %d deleted locally, %d changed remotely\n",
+			result.Remote, result.Added, result.Updated, result.Deleted, result.RemoteChanged)
+		for _, c := range result.Conflicts {
+			fmt.Fprintln(stdout, formatSyncConflict(c))
+		}
+		if n := len(a.Conflicts()); n > 0 {
+			fmt.Fprintf(stdout, "%d unresolved conflict(s). Resolve them with 'go-task sync resolve' or in the TUI.\n", n)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s", syncUsage)
+}
+
+// formatSyncConflict は同期の競合を1行で表します。
+func formatSyncConflict(c task.SyncConflict) string {
+	title := ""
+	switch {
+	case c.Local != nil:
+		title = c.Local.Title
+	case c.Remote != nil:
+		title = c.Remote.Title
+	}
+	switch {
+	case c.Local == nil:
+		return fmt.Sprintf("conflict %s %s: deleted locally, changed remotely", c.TaskID, title)
+	case c.Remote == nil:
+		return fmt.Sprintf("conflict %s %s: changed locally, deleted remotely", c.TaskID, title)
+	}
+	return fmt.Sprintf("conflict %s %s: %s changed on both sides", c.TaskID, title, strings.Join(c.Fields, ", "))
+}
+
+// csvUsage は csv サブコマンドの使い方です。%s には列の一覧が入ります。
+const csvUsage = `usage:
+  go-task csv export <file> [--columns title,priority,...]
+  go-task csv import <file> [--map title=Name,priority=Prio,...] [--dry-run]
+
+Columns: %s
+The file must be in the data directory. Without --map, import matches the CSV header
+to the columns by name (e.g. Name, Deadline, Labels). --dry-run shows the mapping and
+the rows that would fail without changing the tasks.`
+
+// runCSV はタスクの CSV ファイルへのエクスポートと、CSV ファイルからのインポートを行います。
+func runCSV(args []string, stdout io.Writer) error {
+	usage := fmt.Sprintf(csvUsage, strings.Join(app.CSVColumns(), ", "))
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprintln(stdout, usage)
+		return nil
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%s", usage)
+	}
+	action, file := args[0], args[1]
+
+	var columns []string
+	var mapping app.CSVMapping
+	dryRun := false
+	for rest := args[2:]; len(rest) > 0; rest = rest[1:] {
+		switch {
+		case rest[0] == "--columns" && action == "export" && len(rest) > 1:
+			columns = strings.Split(rest[1], ",")
+			rest = rest[1:]
+		case rest[0] == "--map" && action == "import" && len(rest) > 1:
+			mapping = make(app.CSVMapping)
+			for _, pair := range strings.Split(rest[1], ",") {
+				field, column, ok := strings.Cut(pair, "=")
+				if !ok {
+					return fmt.Errorf("invalid mapping %q (use field=Column)", pair)
+				}
+				mapping[strings.TrimSpace(field)] = strings.TrimSpace(column)
+			}
+			rest = rest[1:]
+		case rest[0] == "--dry-run" && action == "import":
+			dryRun = true
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	}
+
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "export":
+		if err := a.ExportTasksCSV(file, columns); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Exported %d task(s) to %s\n", len(a.Tasks.Tasks), file)
+		return nil
+
+	case "import":
+		var report *app.CSVImportReport
+		if dryRun {
+			report, err = a.PreviewCSVImport(file, mapping)
+		} else {
+			report, err = a.ImportTasksCSV(file, mapping)
+		}
+		if err != nil {
+			return err
+		}
+		for _, field := range app.CSVColumns() {
+			if column, ok := report.Mapping[field]; ok {
+				fmt.Fprintf(stdout, "  %-12s <- %s\n", field, column)
+			}
+		}
+		for _, e := range report.Errors {
+			fmt.Fprintln(stdout, e.Error())
+		}
+		if dryRun {
+			fmt.Fprintf(stdout, "Dry run: %d task(s) would be imported, %d row(s) skipped.\n", len(report.Tasks), len(report.Errors))
+			return nil
+		}
+		fmt.Fprintf(stdout, "Imported %d task(s), skipped %d row(s).\n", len(report.Tasks), len(report.Errors))
+		return saveIfNeeded(a)
+	}
+	return fmt.Errorf("%s", usage)
+}
+
+// runCompact はジャーナル形式で追記された変更を含むタスクデータ全体を tasks.json に書き出し、ジャーナルを削除します。
+func runCompact(args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: go-task compact")
+	}
+	a, err := app.NewApp()
+	if err != nil {
+		return err
+	}
+	if err := a.Compact(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Compacted %d task(s) into the task file.\n", len(a.Tasks.Tasks))
+	return nil
+}
+
+// runInit はデータディレクトリを作成します。--local を指定した場合はカレントディレクトリにローカルのタスクファイルを作成します。
+func runInit(args []string, stdout io.Writer) error {
+	switch {
+	case len(args) == 0:
+		if err := store.EnsureDataDirExists(); err != nil {
+			return err
+		}
+		dir, err := store.GetConfigDirPath()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Data directory: %s\n", dir)
+		return nil
+
+	case len(args) == 1 && args[0] == "--local":
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		dir, err := app.InitLocal(wd)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created local task file %s\n", filepath.Join(dir, "tasks.json"))
+		return nil
+	}
+	return fmt.Errorf("usage: go-task init [--local]")
+}
+
+// passphraseEnv は暗号化されたタスクファイルのパスフレーズを指定する環境変数の名前です。
+const passphraseEnv = "GO_TASK_PASSPHRASE"
+
+// readPassphrase はプロンプトを表示してパスフレーズを読み込みます。
+// 標準入力が端末の場合は入力を表示せずに読み込みます。テストでは置き換えて使用します。
+var readPassphrase = func(prompt string, stdout io.Writer) (string, error) {
+	fmt.Fprint(stdout, prompt)
+	if term.IsTerminal(os.Stdin.Fd()) {
+		p, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(stdout)
+		return string(p), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassphrase は新しいパスフレーズを確認のために2回読み込みます。
+func readNewPassphrase(stdout io.Writer) (string, error) {
+	passphrase, err := readPassphrase("New passphrase: ", stdout)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	confirm, err := readPassphrase("Confirm passphrase: ", stdout)
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", fmt.Errorf("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// runEncryption はタスクファイルとバックアップの暗号化の状態表示・有効化・パスフレーズの変更・解除を行います。
+func runEncryption(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: go-task encryption <status|enable|rotate|disable>")
+	}
+	a, err := app.NewApp()
+	if appErr, ok := err.(*app.AppError); ok && appErr.Type == app.ErrTypePassphrase {
+		// 環境変数で指定されていない場合は現在のパスフレーズを尋ねる
+		passphrase, err := readPassphrase("Passphrase: ", stdout)
+		if err != nil {
+			return err
+		}
+		store.SetPassphrase(passphrase)
+		a, err = app.NewApp()
+	}
+	if err != nil {
+		return err
+	}
+	encrypted, err := a.Encrypted()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		if encrypted {
+			fmt.Fprintln(stdout, "The task file and backups are encrypted.")
+		} else {
+			fmt.Fprintln(stdout, "The task file and backups are not encrypted.")
+		}
+		return nil
+
+	case "enable", "rotate":
+		if args[0] == "enable" && encrypted {
+			return fmt.Errorf("the task file is already encrypted; use 'encryption rotate' to change the passphrase")
+		}
+		if args[0] == "rotate" && !encrypted {
+			return fmt.Errorf("the task file is not encrypted; use 'encryption enable' first")
+		}
+		passphrase, err := readNewPassphrase(stdout)
+		if err != nil {
+			return err
+		}
+		if err := a.SetEncryption(passphrase); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Encrypted the task file and backups with the new passphrase.")
+		return nil
+
+	case "disable":
+		if !encrypted {
+			return fmt.Errorf("the task file is not encrypted")
+		}
+		if err := a.SetEncryption(""); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Decrypted the task file and backups.")
+		return nil
+	}
+	return fmt.Errorf("usage: go-task encryption <status|enable|rotate|disable>")
+}
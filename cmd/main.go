@@ -3,11 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"go-task/internal/app"
 	"go-task/internal/config"
 	"go-task/internal/log"
+	"go-task/internal/store"
 	"go-task/internal/task"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -16,6 +19,9 @@ import (
 	"github.com/pkg/profile"
 )
 
+// 期限の入力・表示形式
+const dueDateLayout = "2006-01-02"
+
 var (
 	// 優先度に応じた色
 	priorityColors = map[task.Priority]lipgloss.Color{
@@ -24,6 +30,13 @@ var (
 		task.PriorityLow:    lipgloss.Color("10"), // Green
 	}
 
+	// スヌーズ画面のキーと待機期間のプリセットの対応 ("0" は待機の解除)
+	snoozePresets = map[string]string{
+		"1": app.SnoozeOneHour,
+		"2": app.SnoozeTomorrow,
+		"3": app.SnoozeNextWeek,
+	}
+
 	// 状態に応じたアイコン
 	statusIcons = map[task.Status]string{
 		task.StatusTODO:       "●",
@@ -41,8 +54,12 @@ type model struct {
 	currentView     string
 	err             *app.AppError  // Use custom error type
 	detailViewTask  *task.Task     // Currently viewed task in detail view
+	detailTab       string         // Active tab in detail view: "details" or "history"
+	checklistCursor int            // Selected checklist item in detail view
 	cfg             *config.Config // Application configuration
 	helpViewContent string         // Content for the help view
+	statusMessage   string         // One-line feedback shown in main view (e.g. after undo)
+	showWaiting     bool           // Whether the main view lists waiting (snoozed) tasks instead
 
 	// Filter fields
 	filterStatusInput textinput.Model
@@ -67,6 +84,7 @@ type model struct {
 	descriptionInput textinput.Model
 	priorityInput    textinput.Model
 	tagsInput        textinput.Model
+	dueInput         textinput.Model
 	focusIndex       int // Which input field is focused
 
 	// Settings form fields
@@ -80,12 +98,58 @@ type model struct {
 
 	// Import form fields
 	importInput textinput.Model
+
+	// Checklist item form field (detail view)
+	checklistInput textinput.Model
+
+	// Tag management view
+	tagCursor int             // Selected tag in the tag view
+	tagAction string          // Pending tag action: "rename" or "merge"
+	tagInput  textinput.Model // New tag name for rename/merge
+
+	// Template selection (add view)
+	templates        []task.Template   // Templates listed in the template view
+	templateCursor   int               // Selected template
+	templateVars     []string          // Variables still to be prompted for
+	templateValues   map[string]string // Values entered so far
+	templateVarInput textinput.Model
+
+	// Workspace switcher
+	workspaces      []string // Workspaces listed in the workspace view
+	workspaceCursor int      // Selected workspace
+
+	// Backup manager
+	backups      []app.BackupStatus // Backups listed in the backup view
+	backupCursor int                // Selected backup
+	backupDiff   *app.BackupDiff    // Differences between the selected backup and the current tasks
+	diffCursor   int                // Selected restorable task in the backup diff view
+
+	// Sync conflicts
+	conflictCursor int // Selected conflict in the conflict view
+
+	// Combined view of local and global tasks
+	globalApp *app.App // Global task list shown alongside a local task file
+
+	// Passphrase prompt for encrypted task files
+	passphraseInput textinput.Model
+
+	// Recovery prompt for a corrupted task file
+	recovery *store.RecoveryReport
+
+	// Whether a reload of tasks changed by another process is running in the background
+	reloading bool
 }
 
 func initialModel() model {
 	a, err := app.NewApp()
 	if err != nil {
 		appErr, _ := err.(*app.AppError)
+		if appErr != nil && appErr.Type == app.ErrTypePassphrase {
+			return newPassphraseModel(appErr.Message)
+		}
+		if appErr != nil && appErr.Type == app.ErrTypeCorrupted {
+			return newRecoveryModel(appErr)
+		}
 		return model{err: appErr}
 	}
 
@@ -93,6 +157,7 @@ func initialModel() model {
 	if err != nil {
 		return model{err: app.NewAppError(app.ErrTypeInternal, "Failed to load config", err)}
 	}
+	a.UrgencyCoefficients = cfg.Settings.Urgency
 
 	ti := textinput.New()
 	ti.Placeholder = "Task Title"
@@ -115,6 +180,11 @@ func initialModel() model {
 	tai.CharLimit = 100
 	tai.Width = 50
 
+	dui := textinput.New()
+	dui.Placeholder = "Due date (YYYY-MM-DD, optional)"
+	dui.CharLimit = 10
+	dui.Width = 50
+
 	fsi := textinput.New()
 	fsi.Placeholder = "Filter by status (e.g., TODO,IN_PROGRESS)"
 	fsi.CharLimit = 50
@@ -136,10 +206,24 @@ func initialModel() model {
 	si.Width = 50
 
 	sortInput := textinput.New()
-	sortInput.Placeholder = "Sort by: created_at, updated_at, priority (e.g., created_at asc)"
+	sortInput.Placeholder = "Sort by: created_at, updated_at, priority, urgency (e.g., created_at asc)"
 	sortInput.CharLimit = 50
 	sortInput.Width = 50
 
+	ci := textinput.New()
+	ci.Placeholder = "Checklist item"
+	ci.CharLimit = 255
+	ci.Width = 50
+
+	tgi := textinput.New()
+	tgi.Placeholder = "New tag name (e.g. work/backend)"
+	tgi.CharLimit = 100
+	tgi.Width = 50
+
+	tvi := textinput.New()
+	tvi.CharLimit = 255
+	tvi.Width = 50
+
 	// Settings input fields
 	dpi := textinput.New()
 	dpi.Placeholder = fmt.Sprintf("Default Priority (e.g., %s, %s, %s)", task.PriorityHigh, task.PriorityMedium, task.PriorityLow)
@@ -158,13 +242,14 @@ func initialModel() model {
 
 	return model{
 		app:                  a,
-		tasks:                a.GetAllTasks(),
+		tasks:                a.GetVisibleTasks(),
 		selected:             make(map[string]struct{}),
 		currentView:          "main", // "main", "add", "edit", "detail", "filter", "filter_priority", "filter_tags", "search", "sort"
 		titleInput:           ti,
 		descriptionInput:     di,
 		priorityInput:        pi,
 		tagsInput:            tai,
+		dueInput:             dui,
 		focusIndex:           0,
 		filterStatusInput:    fsi,
 		filteredStatuses:     make(map[task.Status]struct{}),
@@ -183,28 +268,213 @@ func initialModel() model {
 		themeInput:           themeInput,
 		exportInput:          textinput.New(),
 		importInput:          textinput.New(),
+		checklistInput:       ci,
+		templateVarInput:     tvi,
+		tagInput:             tgi,
+	}
+}
+
+// newPassphraseModel は暗号化されたタスクファイルのパスフレーズを入力する画面のモデルを返します。
+func newPassphraseModel(message string) model {
+	pi := textinput.New()
+	pi.Placeholder = "Passphrase"
+	pi.EchoMode = textinput.EchoPassword
+	pi.CharLimit = 1024
+	pi.Width = 50
+	pi.Focus()
+	return model{currentView: "passphrase", passphraseInput: pi, statusMessage: message}
+}
+
+// updatePassphrase はパスフレーズ入力画面のキー操作を処理し、入力されたパスフレーズでタスクデータを読み込み直します。
+func (m model) updatePassphrase(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "ctrl+c", "esc":
+		return m, tea.Quit
+	case "enter":
+		store.SetPassphrase(m.passphraseInput.Value())
+		next := initialModel()
+		if next.currentView == "passphrase" {
+			return next, nil
+		}
+		return next, backgroundChecks()
 	}
+	var cmd tea.Cmd
+	m.passphraseInput, cmd = m.passphraseInput.Update(msg)
+	return m, cmd
+}
+
+// newRecoveryModel は破損したタスクファイルの復旧方法を選ぶ画面のモデルを返します。
+func newRecoveryModel(loadErr *app.AppError) model {
+	report, err := app.PlanRecovery()
+	if err != nil {
+		// 復旧の準備にも失敗した場合は元の読み込みエラーを表示する
+		return model{err: loadErr}
+	}
+	return model{currentView: "recovery", recovery: report, statusMessage: loadErr.Error()}
+}
+
+// updateRecovery は復旧画面のキー操作を処理し、選択された方法で復旧してタスクデータを読み込み直します。
+func (m model) updateRecovery(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	var useBackup bool
+	switch key.String() {
+	case "ctrl+c", "q", "esc":
+		return m, tea.Quit
+	case "b":
+		if m.recovery.Backup == "" {
+			return m, nil
+		}
+		useBackup = true
+	case "s":
+		useBackup = false
+	default:
+		return m, nil
+	}
+
+	report, err := app.Recover(useBackup)
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m, nil
+	}
+	next := initialModel()
+	if next.err == nil && next.currentView == "main" {
+		next.statusMessage = recoverySummary(report)
+	}
+	return next, backgroundChecks()
+}
+
+// recoverySummary は復旧結果を1行にまとめます。
+func recoverySummary(report *store.RecoveryReport) string {
+	source := "no backup"
+	if report.Backup != "" {
+		source = fmt.Sprintf("%d from %s", len(report.FromBackup), report.Backup)
+	}
+	return fmt.Sprintf("Recovered %d task(s) (%s, %d salvaged). The corrupted file was moved to %s.",
+		len(report.FromBackup)+len(report.Salvaged), source, len(report.Salvaged), report.QuarantinePath)
+}
+
+// waitCheckInterval は待機期間を過ぎたタスクを一覧に戻すために再確認する間隔です。
+const waitCheckInterval = time.Minute
+
+// waitCheckMsg は待機中タスクの再確認を促すメッセージです。
+type waitCheckMsg time.Time
+
+// waitCheck は一定時間後に waitCheckMsg を送るコマンドを返します。
+func waitCheck() tea.Cmd {
+	return tea.Tick(waitCheckInterval, func(t time.Time) tea.Msg {
+		return waitCheckMsg(t)
+	})
+}
+
+// reloadCheckInterval は他のプロセスや同期ツールによるタスクデータの変更を確認する間隔です。
+const reloadCheckInterval = 2 * time.Second
+
+// reloadCheckMsg はタスクデータの変更の確認を促すメッセージです。
+type reloadCheckMsg time.Time
+
+// reloadCheck は一定時間後に reloadCheckMsg を送るコマンドを返します。
+func reloadCheck() tea.Cmd {
+	return tea.Tick(reloadCheckInterval, func(t time.Time) tea.Msg {
+		return reloadCheckMsg(t)
+	})
+}
+
+// reloadedMsg は別の goroutine で読み込み直したタスクデータを Update に渡すメッセージです。
+type reloadedMsg struct {
+	tasks *app.ReloadedTasks
+	err   error
+}
+
+// reloadCmd はタスクデータを読み込み直し、保存していない変更とマージして reloadedMsg を送るコマンドを返します。
+// 読み込みと復号は UI を止めないよう別の goroutine で行い、App への適用は Update で行います。
+func reloadCmd(a *app.App) tea.Cmd {
+	load := a.PrepareReload()
+	return func() tea.Msg {
+		tasks, err := load()
+		return reloadedMsg{tasks: tasks, err: err}
+	}
+}
+
+// backgroundChecks はメイン画面の表示中に定期的に行う確認のコマンドを返します。
+func backgroundChecks() tea.Cmd {
+	return tea.Batch(waitCheck(), reloadCheck())
 }
 
 func (m model) Init() tea.Cmd {
-	// Just return `nil` for now, which means "no I/O right now, please."
-	return nil
+	// Periodically re-check waiting tasks so snoozed tasks reappear on their own,
+	// and pick up changes made to the data file by other processes
+	return backgroundChecks()
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.err != nil {
-		// エラーが発生している場合は、qで終了のみ
+		// エラーが発生している場合は、qで終了のみ (git のエラーは esc で閉じられる)
 		if msg, ok := msg.(tea.KeyMsg); ok && (msg.String() == "q" || msg.String() == "ctrl+c") {
 			return m, tea.Quit
 		}
+		// エラーを閉じた後も定期的な確認が続くよう、エラーの表示中も確認のコマンドを予約し直す
+		switch msg.(type) {
+		case waitCheckMsg:
+			return m, waitCheck()
+		case reloadCheckMsg:
+			return m, reloadCheck()
+		case reloadedMsg:
+			// 読み込んだ内容は適用しない。変更は残っているため、エラーを閉じた後の確認で読み込み直す
+			m.reloading = false
+			return m, nil
+		}
+		// git のコミットに失敗しただけならタスクは保存済みのため、esc でそのまま作業を続けられる
+		if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "esc" && m.err.Type == app.ErrTypeGit {
+			m.err = nil
+			m.tasks = m.listTasks()
+			m.clampCursor()
+			m.statusMessage = "Tasks were saved without a git commit. The next save commits them."
+		}
 		return m, nil
 	}
+	if m.currentView == "passphrase" {
+		return m.updatePassphrase(msg)
+	}
+	if m.currentView == "recovery" {
+		return m.updateRecovery(msg)
+	}
 
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
+	case waitCheckMsg:
+		// Refresh the list only when a snoozed task became visible (or vice versa),
+		// so that the cursor does not jump around on every tick
+		if m.currentView == "main" && !m.isFiltering {
+			if list := m.listTasks(); len(list) != len(m.tasks) {
+				m.tasks = m.app.SortTasks(list, m.sortBy, m.sortAsc)
+				m.clampCursor()
+			}
+		}
+		return m, waitCheck()
+
+	case reloadCheckMsg:
+		// Only the file timestamps are checked here; the data is read in the background only when they changed
+		if !m.reloading && m.app.ExternalChanges() {
+			m.reloading = true
+			return m, tea.Batch(reloadCheck(), reloadCmd(m.app))
+		}
+		return m, reloadCheck()
+
+	case reloadedMsg:
+		m.reloading = false
+		return m.reloadTasks(msg), nil
+
 	case tea.KeyMsg:
+		m.statusMessage = "" // Feedback is shown only until the next key press
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
@@ -252,6 +522,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "v": // View task details
 			if m.currentView == "main" && len(m.tasks) > 0 {
 				m.detailViewTask = &m.tasks[m.cursor]
+				m.detailTab = "details"
+				m.checklistCursor = 0
 				m.currentView = "detail"
 				return m, nil
 			}
@@ -281,11 +553,225 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if err != nil {
 					m.err, _ = err.(*app.AppError)
 				} else {
-					m.tasks = m.app.GetAllTasks() // Refresh tasks
+					m.tasks = m.listTasks() // Refresh tasks
+				}
+				return m, nil
+			}
+
+		case "d": // Delete selected tasks, or the task under the cursor
+			if m.currentView == "tags" {
+				tags := m.app.GetAllUniqueTags()
+				if len(tags) == 0 {
+					return m, nil
+				}
+				tag := tags[m.tagCursor]
+				n, err := m.app.DeleteTag(tag)
+				m = m.afterTagChange(err)
+				m.statusMessage = fmt.Sprintf("Removed %s from %d task(s).", tag, n)
+				return m, nil
+			}
+			if m.currentView == "main" && len(m.tasks) > 0 {
+				var ids []string
+				for id := range m.selected {
+					ids = append(ids, id)
+				}
+				if len(ids) == 0 {
+					ids = []string{m.tasks[m.cursor].ID}
+				}
+				if err := m.app.DeleteTasks(ids); err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m.selected = make(map[string]struct{})
+				m.tasks = m.listTasks() // Refresh tasks
+				m.clampCursor()
+				m.statusMessage = fmt.Sprintf("Deleted %d task(s). Press [u] to undo.", len(ids))
+				return m, nil
+			}
+
+		case "u": // Undo last operation
+			if m.currentView == "main" {
+				op, err := m.app.Undo()
+				if err != nil {
+					m.statusMessage = "Nothing to undo."
+					return m, nil
+				}
+				m.tasks = m.listTasks() // Refresh tasks
+				m.clampCursor()
+				m.statusMessage = fmt.Sprintf("Undid %s.", op.Name)
+				return m, nil
+			}
+
+		case "ctrl+r": // Redo last undone operation
+			if m.currentView == "main" {
+				op, err := m.app.Redo()
+				if err != nil {
+					m.statusMessage = "Nothing to redo."
+					return m, nil
+				}
+				m.tasks = m.listTasks() // Refresh tasks
+				m.clampCursor()
+				m.statusMessage = fmt.Sprintf("Redid %s.", op.Name)
+				return m, nil
+			}
+
+		case "z": // Snooze task
+			if m.currentView == "main" && len(m.tasks) > 0 {
+				m.currentView = "snooze"
+				return m, nil
+			}
+
+		case "1", "2", "3", "0": // Snooze presets
+			if m.currentView == "snooze" {
+				taskID := m.tasks[m.cursor].ID
+				var until *time.Time
+				if preset, ok := snoozePresets[msg.String()]; ok {
+					t, err := app.SnoozeUntil(preset, time.Now())
+					if err != nil {
+						m.err, _ = err.(*app.AppError)
+						return m, nil
+					}
+					until = &t
+				}
+				if _, err := m.app.SnoozeTask(taskID, until); err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				if until != nil {
+					m.statusMessage = fmt.Sprintf("Snoozed until %s.", until.Format("2006-01-02 15:04"))
+				} else {
+					m.statusMessage = "Task woken up."
+				}
+				m.currentView = "main"
+				m.tasks = m.listTasks() // Refresh tasks
+				m.clampCursor()
+				return m, nil
+			}
+
+		case "w": // Switch workspace
+			if m.currentView == "main" {
+				names, err := app.ListWorkspaces()
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m.workspaces = names
+				m.workspaceCursor = 0
+				for i, name := range names {
+					if name == m.app.Workspace() {
+						m.workspaceCursor = i
+					}
+				}
+				m.currentView = "workspaces"
+				return m, nil
+			}
+
+		case "b": // Manage backups
+			if m.currentView == "main" {
+				return m.openBackups(), nil
+			}
+
+		case "R": // Restore the selected backup
+			if m.currentView == "backups" && len(m.backups) > 0 {
+				b := m.backups[m.backupCursor]
+				if b.Err != nil {
+					m.statusMessage = fmt.Sprintf("Cannot restore %s: %v", b.Name, b.Err)
+					return m, nil
 				}
+				if err := m.app.RestoreBackupByName(b.Name); err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m = m.openBackups() // The restore added a backup of the previous data
+				m.tasks = m.listTasks()
+				m.clampCursor()
+				m.statusMessage = fmt.Sprintf("Restored %s. Press [u] in the task list to undo.", b.Name)
+				return m, nil
+			}
+
+		case "L": // Show local and global tasks together
+			if m.currentView == "main" && m.app.LocalDir() != "" {
+				if m.globalApp == nil {
+					a, err := app.NewGlobalApp()
+					if err != nil {
+						m.err, _ = err.(*app.AppError)
+						return m, nil
+					}
+					m.globalApp = a
+				}
+				m.currentView = "combined"
+				return m, nil
+			} else if m.currentView == "combined" {
+				m.currentView = "main"
+				return m, nil
+			}
+
+		case "C": // Resolve sync conflicts
+			if m.currentView == "main" {
+				m.currentView = "conflicts"
+				m.conflictCursor = 0
 				return m, nil
 			}
 
+		case "l": // Keep the local side of the selected conflict
+			if m.currentView == "conflicts" && len(m.app.Conflicts()) > 0 {
+				return m.resolveConflict(false), nil
+			}
+
+		case "T": // Manage tags
+			if m.currentView == "main" {
+				m.currentView = "tags"
+				m.tagCursor = 0
+				return m, nil
+			}
+
+		case "r", "m": // Rename or merge the selected tag
+			if m.currentView == "conflicts" && msg.String() == "r" && len(m.app.Conflicts()) > 0 {
+				// Use the remote side of the selected conflict
+				return m.resolveConflict(true), nil
+			}
+			if m.currentView == "tags" && len(m.app.GetAllUniqueTags()) > 0 {
+				m.tagAction = "rename"
+				if msg.String() == "m" {
+					m.tagAction = "merge"
+				}
+				m.currentView = "tag_edit"
+				m.tagInput.Focus()
+				return m, nil
+			}
+
+		case "W": // Toggle waiting (snoozed) tasks view
+			if m.currentView == "main" {
+				m.showWaiting = !m.showWaiting
+				m.tasks = m.listTasks()
+				m.cursor = 0
+				return m, nil
+			}
+
+		case "n": // New checklist item
+			if m.currentView == "detail" && m.detailViewTask != nil {
+				m.currentView = "checklist_add"
+				m.checklistInput.Focus()
+				return m, nil
+			}
+
+		case "x": // Toggle checklist item
+			if m.currentView == "detail" && m.detailViewTask != nil && len(m.detailViewTask.Checklist) > 0 {
+				_, err := m.app.ToggleChecklistItem(m.detailViewTask.ID, m.checklistCursor)
+				return m.afterChecklistChange(err), nil
+			}
+
+		case "K", "J": // Move checklist item up/down
+			if m.currentView == "detail" && m.detailViewTask != nil && len(m.detailViewTask.Checklist) > 0 {
+				delta := 1
+				if msg.String() == "K" {
+					delta = -1
+				}
+				_, err := m.app.MoveChecklistItem(m.detailViewTask.ID, m.checklistCursor, delta)
+				m.checklistCursor += delta
+				return m.afterChecklistChange(err), nil
+			}
+
 		case "e": // Edit task
 			if m.currentView == "main" && len(m.tasks) > 0 { // カーソルがタスクを指している場合
 				t := m.tasks[m.cursor]
@@ -293,6 +779,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.descriptionInput.SetValue(t.Description)
 				m.priorityInput.SetValue(string(t.Priority))
 				m.tagsInput.SetValue(strings.Join(t.Tags, ","))
+				m.dueInput.SetValue("")
+				if t.DueDate != nil {
+					m.dueInput.SetValue(t.DueDate.Format(dueDateLayout))
+				}
 				m.currentView = "edit"
 				m.focusIndex = 0
 				m.titleInput.Focus()
@@ -327,18 +817,61 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+		case "ctrl+t": // Create a task from a template
+			if m.currentView == "add" {
+				templates, err := m.app.GetTemplates()
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m.templates = templates
+				m.templateCursor = 0
+				m.currentView = "template_select"
+				return m, nil
+			}
+
 		case "esc":
-			if m.currentView == "add" || m.currentView == "edit" || m.currentView == "filter" || m.currentView == "filter_priority" || m.currentView == "filter_tags" || m.currentView == "search" || m.currentView == "sort" || m.currentView == "detail" || m.currentView == "settings" || m.currentView == "export" || m.currentView == "import" || m.currentView == "help" {
+			if m.currentView == "tag_edit" {
+				// Return to the tag list
+				m.currentView = "tags"
+				m.tagInput.SetValue("")
+				m.tagInput.Blur()
+				return m, nil
+			}
+			if m.currentView == "template_select" || m.currentView == "template_vars" {
+				// Return to the add form
+				m.currentView = "add"
+				m.templateVarInput.SetValue("")
+				m.templateVarInput.Blur()
+				return m, nil
+			}
+			if m.currentView == "backup_diff" {
+				// Return to the backup list
+				m.currentView = "backups"
+				m.backupDiff = nil
+				m.statusMessage = ""
+				return m, nil
+			}
+			if m.currentView == "checklist_add" {
+				// Return to the task rather than the main view
+				m.currentView = "detail"
+				m.checklistInput.SetValue("")
+				m.checklistInput.Blur()
+				return m, nil
+			}
+			if m.currentView == "add" || m.currentView == "edit" || m.currentView == "filter" || m.currentView == "filter_priority" || m.currentView == "filter_tags" || m.currentView == "search" || m.currentView == "sort" || m.currentView == "detail" || m.currentView == "settings" || m.currentView == "export" || m.currentView == "import" || m.currentView == "help" || m.currentView == "snooze" || m.currentView == "tags" || m.currentView == "workspaces" || m.currentView == "backups" || m.currentView == "combined" || m.currentView == "conflicts" {
 				m.currentView = "main"
 				// Clear form fields
 				m.titleInput.SetValue("")
 				m.descriptionInput.SetValue("")
 				m.priorityInput.SetValue("")
 				m.tagsInput.SetValue("")
+				m.dueInput.SetValue("")
 				m.titleInput.Blur()
 				m.descriptionInput.Blur()
 				m.priorityInput.Blur()
 				m.tagsInput.Blur()
+				m.dueInput.Blur()
 				m.filterStatusInput.SetValue("") // Clear status filter input
 				m.filterStatusInput.Blur()
 				m.filteredStatuses = make(map[task.Status]struct{}) // Clear filtered statuses
@@ -356,8 +889,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.sortBy = "created_at" // Reset sort by
 				m.sortAsc = false       // Reset sort order
 				m.isFiltering = false
-				m.detailViewTask = nil                 // Clear selected task for detail view
-				m.tasks = m.app.GetAllTasks()          // Reset tasks to all tasks
+				m.detailViewTask = nil // Clear selected task for detail view
+				m.detailTab = ""
+				m.tasks = m.listTasks()                // Reset tasks to all tasks
 				m.selected = make(map[string]struct{}) // Clear selection
 
 				// Clear settings form fields
@@ -371,10 +905,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "up", "shift+tab":
+			if m.currentView == "detail" && msg.String() == "shift+tab" {
+				m.toggleDetailTab()
+				return m, nil
+			}
 			if m.currentView == "add" || m.currentView == "edit" {
 				m.focusIndex--
 				if m.focusIndex < 0 {
-					m.focusIndex = 3
+					m.focusIndex = 4
 				}
 				cmds = append(cmds, m.setFocus())
 			} else if m.currentView == "settings" {
@@ -383,6 +921,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.settingsFocusIndex = 2
 				}
 				cmds = append(cmds, m.setSettingsFocus())
+			} else if m.currentView == "template_select" {
+				if m.templateCursor > 0 {
+					m.templateCursor--
+				}
+			} else if m.currentView == "tags" {
+				if m.tagCursor > 0 {
+					m.tagCursor--
+				}
+			} else if m.currentView == "workspaces" {
+				if m.workspaceCursor > 0 {
+					m.workspaceCursor--
+				}
+			} else if m.currentView == "backups" {
+				if m.backupCursor > 0 {
+					m.backupCursor--
+				}
+			} else if m.currentView == "conflicts" {
+				if m.conflictCursor > 0 {
+					m.conflictCursor--
+				}
+			} else if m.currentView == "backup_diff" {
+				if m.diffCursor > 0 {
+					m.diffCursor--
+				}
+			} else if m.currentView == "detail" && msg.String() == "up" {
+				if m.checklistCursor > 0 {
+					m.checklistCursor--
+				}
 			} else if m.currentView == "main" {
 				if m.cursor > 0 {
 					m.cursor--
@@ -390,9 +956,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "down", "tab":
+			if m.currentView == "detail" && msg.String() == "tab" {
+				m.toggleDetailTab()
+				return m, nil
+			}
 			if m.currentView == "add" || m.currentView == "edit" {
 				m.focusIndex++
-				if m.focusIndex > 3 {
+				if m.focusIndex > 4 {
 					m.focusIndex = 0
 				}
 				cmds = append(cmds, m.setFocus())
@@ -402,6 +972,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.settingsFocusIndex = 0
 				}
 				cmds = append(cmds, m.setSettingsFocus())
+			} else if m.currentView == "template_select" {
+				if m.templateCursor < len(m.templates)-1 {
+					m.templateCursor++
+				}
+			} else if m.currentView == "tags" {
+				if m.tagCursor < len(m.app.GetAllUniqueTags())-1 {
+					m.tagCursor++
+				}
+			} else if m.currentView == "workspaces" {
+				if m.workspaceCursor < len(m.workspaces)-1 {
+					m.workspaceCursor++
+				}
+			} else if m.currentView == "backups" {
+				if m.backupCursor < len(m.backups)-1 {
+					m.backupCursor++
+				}
+			} else if m.currentView == "conflicts" {
+				if m.conflictCursor < len(m.app.Conflicts())-1 {
+					m.conflictCursor++
+				}
+			} else if m.currentView == "backup_diff" {
+				if m.diffCursor < len(m.backupDiff.RestorableTasks())-1 {
+					m.diffCursor++
+				}
+			} else if m.currentView == "detail" {
+				if m.detailViewTask != nil && m.checklistCursor < len(m.detailViewTask.Checklist)-1 {
+					m.checklistCursor++
+				}
 			} else if m.currentView == "main" {
 				if m.cursor < len(m.tasks)-1 {
 					m.cursor++
@@ -419,21 +1017,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					tags = strings.Split(tagsStr, ",")
 				}
 
-				_, err := m.app.AddTask(title, description, priority, tags)
+				dueDate, err := parseDueDate(m.dueInput.Value())
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+
+				_, err = m.app.AddTaskFrom(task.Task{
+					Title:       title,
+					Description: description,
+					Priority:    priority,
+					Tags:        tags,
+					DueDate:     dueDate,
+				})
 				if err != nil {
 					m.err, _ = err.(*app.AppError)
 				} else {
 					m.currentView = "main"
-					m.tasks = m.app.GetAllTasks() // Refresh tasks
+					m.tasks = m.listTasks() // Refresh tasks
+					m.warnUnregisteredTags(tags)
 					// Clear form fields
 					m.titleInput.SetValue("")
 					m.descriptionInput.SetValue("")
 					m.priorityInput.SetValue("")
 					m.tagsInput.SetValue("")
+					m.dueInput.SetValue("")
 					m.titleInput.Blur()
 					m.descriptionInput.Blur()
 					m.priorityInput.Blur()
 					m.tagsInput.Blur()
+					m.dueInput.Blur()
 				}
 				return m, tea.Batch(cmds...)
 			} else if m.currentView == "edit" {
@@ -452,22 +1065,46 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					tags = strings.Split(tagsStr, ",")
 				}
 
-				_, err := m.app.UpdateTask(taskID, title, description, "", priority, tags) // Status is not edited here
+				dueDate, err := parseDueDate(m.dueInput.Value())
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+
+				// Status is not edited here; an empty due date clears it
+				_, err = m.app.UpdateTaskWith(taskID, func(t *task.Task) {
+					if title != "" {
+						t.Title = title
+					}
+					if description != "" {
+						t.Description = description
+					}
+					if priority != "" {
+						t.Priority = priority
+					}
+					if tags != nil {
+						t.Tags = tags
+					}
+					t.DueDate = dueDate
+				})
 				if err != nil {
 					m.err, _ = err.(*app.AppError)
 				} else {
 					m.currentView = "main"
-					m.tasks = m.app.GetAllTasks() // Refresh tasks
+					m.tasks = m.listTasks() // Refresh tasks
+					m.warnUnregisteredTags(tags)
 					// m.selected = make(map[string]struct{}) // 選択状態をクリアしない
 					// Clear form fields
 					m.titleInput.SetValue("")
 					m.descriptionInput.SetValue("")
 					m.priorityInput.SetValue("")
 					m.tagsInput.SetValue("")
+					m.dueInput.SetValue("")
 					m.titleInput.Blur()
 					m.descriptionInput.Blur()
 					m.priorityInput.Blur()
 					m.tagsInput.Blur()
+					m.dueInput.Blur()
 				}
 				return m, tea.Batch(cmds...)
 			} else if m.currentView == "filter" {
@@ -479,10 +1116,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.filteredStatuses[task.Status(strings.TrimSpace(s))] = struct{}{}
 					}
 					m.isFiltering = true
-					m.tasks = m.app.GetFilteredTasksByStatus(m.convertStatusMapToList())
+					m.tasks = m.app.ExcludeWaiting(m.app.GetFilteredTasksByStatus(m.convertStatusMapToList()))
 				} else {
 					m.isFiltering = false
-					m.tasks = m.app.GetAllTasks()
+					m.tasks = m.listTasks()
 				}
 				m.currentView = "main"
 				m.filterStatusInput.SetValue("")
@@ -497,10 +1134,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.filteredPriorities[task.Priority(strings.TrimSpace(p))] = struct{}{}
 					}
 					m.isFiltering = true
-					m.tasks = m.app.GetFilteredTasksByPriority(m.convertPriorityMapToList())
+					m.tasks = m.app.ExcludeWaiting(m.app.GetFilteredTasksByPriority(m.convertPriorityMapToList()))
 				} else {
 					m.isFiltering = false
-					m.tasks = m.app.GetAllTasks()
+					m.tasks = m.listTasks()
 				}
 				m.currentView = "main"
 				m.filterPriorityInput.SetValue("")
@@ -515,10 +1152,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.filteredTags[strings.TrimSpace(t)] = struct{}{}
 					}
 					m.isFiltering = true
-					m.tasks = m.app.GetFilteredTasksByTags(m.convertTagMapToList())
+					m.tasks = m.app.ExcludeWaiting(m.app.GetFilteredTasksByTags(m.convertTagMapToList()))
 				} else {
 					m.isFiltering = false
-					m.tasks = m.app.GetAllTasks()
+					m.tasks = m.listTasks()
 				}
 				m.currentView = "main"
 				m.filterTagsInput.SetValue("")
@@ -528,10 +1165,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.searchKeyword = m.searchInput.Value()
 				if m.searchKeyword != "" {
 					m.isFiltering = true
-					m.tasks = m.app.Search(m.searchKeyword)
+					m.tasks = m.app.ExcludeWaiting(m.app.Search(m.searchKeyword))
 				} else {
 					m.isFiltering = false
-					m.tasks = m.app.GetAllTasks()
+					m.tasks = m.listTasks()
 				}
 				m.currentView = "main"
 				m.searchInput.SetValue("")
@@ -554,12 +1191,97 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// Clear sort if input is empty
 					m.sortBy = "created_at"
 					m.sortAsc = false
-					m.tasks = m.app.SortTasks(m.app.GetAllTasks(), m.sortBy, m.sortAsc) // Reset to default sort
+					m.tasks = m.app.SortTasks(m.listTasks(), m.sortBy, m.sortAsc) // Reset to default sort
 				}
 				m.currentView = "main"
 				m.sortInput.SetValue("")
 				m.sortInput.Blur()
 				return m, tea.Batch(cmds...)
+			} else if m.currentView == "tag_edit" {
+				tag := m.app.GetAllUniqueTags()[m.tagCursor]
+				newTag := strings.TrimSpace(m.tagInput.Value())
+				var n int
+				var err error
+				if m.tagAction == "merge" {
+					n, err = m.app.MergeTags([]string{tag}, newTag)
+				} else {
+					n, err = m.app.RenameTag(tag, newTag)
+				}
+				m = m.afterTagChange(err)
+				if m.err == nil {
+					m.statusMessage = fmt.Sprintf("Updated %d task(s).", n)
+					m.currentView = "tags"
+					m.tagInput.SetValue("")
+					m.tagInput.Blur()
+				}
+				return m, tea.Batch(cmds...)
+			} else if m.currentView == "workspaces" {
+				return m.switchWorkspace(m.workspaces[m.workspaceCursor]), nil
+			} else if m.currentView == "backups" {
+				if len(m.backups) == 0 {
+					return m, nil
+				}
+				diff, err := m.app.DiffBackup(m.backups[m.backupCursor].Name)
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m.backupDiff = diff
+				m.diffCursor = 0
+				m.statusMessage = ""
+				m.currentView = "backup_diff"
+				return m, nil
+			} else if m.currentView == "backup_diff" {
+				restorable := m.backupDiff.RestorableTasks()
+				if len(restorable) == 0 {
+					return m, nil
+				}
+				name := m.backups[m.backupCursor].Name
+				t, err := m.app.RestoreTaskFromBackup(name, restorable[m.diffCursor].ID)
+				if err != nil {
+					m.err, _ = err.(*app.AppError)
+					return m, nil
+				}
+				m.tasks = m.listTasks()
+				m.clampCursor()
+				// Refresh the diff against the backup that is still selected
+				if diff, err := m.app.DiffBackup(name); err == nil {
+					m.backupDiff = diff
+					if n := len(diff.RestorableTasks()); m.diffCursor >= n && n > 0 {
+						m.diffCursor = n - 1
+					}
+				}
+				m.statusMessage = fmt.Sprintf("Restored %s.", t.Title)
+				return m, nil
+			} else if m.currentView == "template_select" {
+				if len(m.templates) == 0 {
+					return m, nil
+				}
+				vars, err := m.templates[m.templateCursor].Variables()
+				if err != nil {
+					m.err = app.NewAppError(app.ErrTypeValidation, "Invalid template.", err)
+					return m, nil
+				}
+				m.templateVars = vars
+				m.templateValues = make(map[string]string)
+				m.currentView = "template_vars"
+				m.templateVarInput.Focus()
+				return m.nextTemplateVar()
+			} else if m.currentView == "template_vars" {
+				m.templateValues[m.templateVars[0]] = m.templateVarInput.Value()
+				m.templateVars = m.templateVars[1:]
+				m.templateVarInput.SetValue("")
+				return m.nextTemplateVar()
+			} else if m.currentView == "checklist_add" {
+				_, err := m.app.AddChecklistItem(m.detailViewTask.ID, m.checklistInput.Value())
+				m = m.afterChecklistChange(err)
+				if m.err == nil {
+					m.checklistCursor = len(m.detailViewTask.Checklist) - 1
+					m.currentView = "detail"
+					m.checklistInput.SetValue("")
+					m.checklistInput.Blur()
+				}
+				return m, tea.Batch(cmds...)
 			} else if m.currentView == "settings" {
 				// Save settings
 				defaultPriority := task.Priority(strings.ToUpper(m.defaultPriorityInput.Value()))
@@ -601,6 +1323,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		case " ": // Space bar for main view selection
+			if m.currentView == "detail" && m.detailViewTask != nil && len(m.detailViewTask.Checklist) > 0 {
+				_, err := m.app.ToggleChecklistItem(m.detailViewTask.ID, m.checklistCursor)
+				return m.afterChecklistChange(err), nil
+			}
 			if m.currentView == "main" {
 				if len(m.tasks) > 0 {
 					taskID := m.tasks[m.cursor].ID
@@ -636,6 +1362,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.priorityInput, cmd = m.priorityInput.Update(msg)
 		case 3:
 			m.tagsInput, cmd = m.tagsInput.Update(msg)
+		case 4:
+			m.dueInput, cmd = m.dueInput.Update(msg)
 		}
 		cmds = append(cmds, cmd)
 	} else if m.currentView == "filter" {
@@ -656,15 +1384,290 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	} else if m.currentView == "import" {
 		m.importInput, cmd = m.importInput.Update(msg)
 		cmds = append(cmds, cmd)
+	} else if m.currentView == "checklist_add" {
+		m.checklistInput, cmd = m.checklistInput.Update(msg)
+		cmds = append(cmds, cmd)
+	} else if m.currentView == "template_vars" {
+		m.templateVarInput, cmd = m.templateVarInput.Update(msg)
+		cmds = append(cmds, cmd)
+	} else if m.currentView == "tag_edit" {
+		m.tagInput, cmd = m.tagInput.Update(msg)
+		cmds = append(cmds, cmd)
 	}
 
 	// Return the updated model to the Bubble Tea runtime for processing.
 	return m, tea.Batch(cmds...)
 }
 
+// nextTemplateVar は未入力のテンプレート変数があれば入力を促し、
+// すべて入力済みであればテンプレートからタスクを作成してメイン画面に戻ります。
+func (m model) nextTemplateVar() (tea.Model, tea.Cmd) {
+	if len(m.templateVars) > 0 {
+		m.templateVarInput.Placeholder = m.templateVars[0]
+		return m, nil
+	}
+
+	t, err := m.app.AddTaskFromTemplate(m.templates[m.templateCursor].Name, m.templateValues)
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m, nil
+	}
+	m.templateVarInput.Blur()
+	m.titleInput.SetValue("")
+	m.titleInput.Blur()
+	m.currentView = "main"
+	m.tasks = m.listTasks() // Refresh tasks
+	m.statusMessage = fmt.Sprintf("Created %q from template.", t.Title)
+	return m, nil
+}
+
+// renderTagChips はタグをタグレジストリの色のチップとして描画します。非表示のタグは描画しません。
+func (m model) renderTagChips(tags []string) string {
+	var chips []string
+	for _, tag := range tags {
+		info, _ := m.app.GetTagInfo(tag)
+		if info.Hidden || strings.TrimSpace(tag) == "" {
+			continue
+		}
+		style := lipgloss.NewStyle().Padding(0, 1)
+		if info.Color != "" {
+			style = style.Background(lipgloss.Color(info.Color)).Foreground(lipgloss.Color("0"))
+		} else {
+			style = style.Foreground(lipgloss.Color("8"))
+		}
+		chips = append(chips, style.Render(tag))
+	}
+	return strings.Join(chips, " ")
+}
+
+// warnUnregisteredTags は厳格モードで未登録のタグが使われた場合に警告メッセージを設定します。
+func (m *model) warnUnregisteredTags(tags []string) {
+	if unknown := m.app.UnregisteredTags(tags); len(unknown) > 0 {
+		m.statusMessage = fmt.Sprintf("Warning: unregistered tag(s): %s", strings.Join(unknown, ", "))
+	}
+}
+
+// openBackups はバックアップを検証して一覧を表示します。
+func (m model) openBackups() model {
+	statuses, err := m.app.InspectBackups()
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m
+	}
+	m.backups = statuses
+	m.backupCursor = 0
+	m.backupDiff = nil
+	m.statusMessage = ""
+	m.currentView = "backups"
+	return m
+}
+
+// toggleDetailTab は詳細画面の詳細タブと変更履歴タブを切り替えます。
+func (m *model) toggleDetailTab() {
+	if m.detailTab == "history" {
+		m.detailTab = "details"
+	} else {
+		m.detailTab = "history"
+	}
+}
+
+// resolveConflict は選択された同期の競合を、ローカルまたはリモートの内容を選んで解決します。
+func (m model) resolveConflict(useRemote bool) model {
+	c := m.app.Conflicts()[m.conflictCursor]
+	if _, err := m.app.ResolveConflict(c.TaskID, useRemote); err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m
+	}
+	side := "local"
+	if useRemote {
+		side = "remote"
+	}
+	m.statusMessage = fmt.Sprintf("Resolved %s with the %s version.", c.TaskID, side)
+	if n := len(m.app.Conflicts()); m.conflictCursor >= n && n > 0 {
+		m.conflictCursor = n - 1
+	}
+	m.tasks = m.listTasks()
+	m.clampCursor()
+	return m
+}
+
+// switchWorkspace は指定されたワークスペースに切り替え、そのタスク一覧を表示します。
+func (m model) switchWorkspace(name string) model {
+	a, err := app.SwitchWorkspace(name)
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m
+	}
+	a.UrgencyCoefficients = m.cfg.Settings.Urgency
+	// 切り替え前の App (一緒に表示していたグローバルのタスク一覧を含む) の定期バックアップを終了する
+	closeErr := closeApps(m.app, m.globalApp)
+	m.app = a
+	m.globalApp = nil
+	// 設定画面での保存で切り替え前のワークスペースに戻らないよう、保持している設定も更新する
+	m.cfg.CurrentWorkspace = name
+	m.tasks = m.listTasks()
+	m.cursor = 0
+	m.selected = make(map[string]struct{})
+	m.currentView = "main"
+	m.statusMessage = fmt.Sprintf("Switched to workspace %s.", name)
+	if closeErr != nil {
+		m.statusMessage += " Warning: " + closeErr.Error()
+	}
+	return m
+}
+
+// closeApps は使い終えた App を閉じ、最初のエラーを返します。nil の App は無視します。
+func closeApps(apps ...*app.App) error {
+	var first error
+	for _, a := range apps {
+		if a == nil {
+			continue
+		}
+		if err := a.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// afterTagChange はタグの一括変更後にタスク一覧を更新し、タグ一覧のカーソルを範囲内に収めます。
+func (m model) afterTagChange(err error) model {
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m
+	}
+	m.tasks = m.listTasks()
+	if n := len(m.app.GetAllUniqueTags()); m.tagCursor >= n {
+		m.tagCursor = n - 1
+	}
+	if m.tagCursor < 0 {
+		m.tagCursor = 0
+	}
+	return m
+}
+
+// afterChecklistChange はチェックリストの変更後に一覧と詳細表示中のタスクを更新します。
+func (m model) afterChecklistChange(err error) model {
+	if err != nil {
+		m.err, _ = err.(*app.AppError)
+		return m
+	}
+	m.tasks = m.listTasks()
+	if t, err := m.app.GetTaskByID(m.detailViewTask.ID); err == nil {
+		m.detailViewTask = t
+	}
+	if n := len(m.detailViewTask.Checklist); m.checklistCursor >= n {
+		m.checklistCursor = n - 1
+	}
+	if m.checklistCursor < 0 {
+		m.checklistCursor = 0
+	}
+	return m
+}
+
+// reloadTasks は reloadCmd で読み込み直したタスクデータを適用し、一覧、選択、詳細表示中のタスクを更新します。
+// カーソルは同じIDのタスクに留め、保存していない変更と競合した場合は警告を表示します。
+// 読み込みの間にタスクを変更していた場合は何もせず、次の確認で読み込み直します。
+func (m model) reloadTasks(msg reloadedMsg) model {
+	var cursorID string
+	if m.cursor < len(m.tasks) {
+		cursorID = m.tasks[m.cursor].ID
+	}
+	if msg.err != nil {
+		m.err, _ = msg.err.(*app.AppError)
+		return m
+	}
+	result, err := m.app.ApplyReload(msg.tasks)
+	if result.Discarded {
+		return m
+	}
+
+	if m.isFiltering {
+		// Keep the filtered list as it is, dropping tasks that no longer exist
+		shown := make([]task.Task, 0, len(m.tasks))
+		for _, t := range m.tasks {
+			if updated, err := m.app.GetTaskByID(t.ID); err == nil {
+				shown = append(shown, *updated)
+			}
+		}
+		m.tasks = shown
+	} else {
+		m.tasks = m.app.SortTasks(m.listTasks(), m.sortBy, m.sortAsc)
+	}
+	m.clampCursor()
+	for i, t := range m.tasks {
+		if t.ID == cursorID {
+			m.cursor = i
+			break
+		}
+	}
+	for id := range m.selected {
+		if _, err := m.app.GetTaskByID(id); err != nil {
+			delete(m.selected, id)
+		}
+	}
+	if m.detailViewTask != nil {
+		if t, err := m.app.GetTaskByID(m.detailViewTask.ID); err == nil {
+			m.detailViewTask = t
+		} else if m.currentView == "detail" {
+			m.detailViewTask = nil
+			m.currentView = "main"
+		}
+	}
+
+	switch {
+	case err != nil:
+		m.statusMessage = err.Error()
+	case len(result.Conflicts) > 0:
+		titles := make([]string, 0, len(result.Conflicts))
+		for _, id := range result.Conflicts {
+			if t, err := m.app.GetTaskByID(id); err == nil {
+				titles = append(titles, t.Title)
+			}
+		}
+		m.statusMessage = fmt.Sprintf("Warning: %d task(s) were also changed by another process; your unsaved edits were kept: %s",
+			len(result.Conflicts), strings.Join(titles, ", "))
+	case result.Changed:
+		m.statusMessage = "Reloaded tasks changed by another process."
+	}
+	return m
+}
+
+// listTasks はメイン画面に表示するタスクを返します。
+// 通常は待機中のタスクを除き、待機中ビューでは待機中のタスクのみを返します。
+func (m model) listTasks() []task.Task {
+	if m.showWaiting {
+		return m.app.GetWaitingTasks()
+	}
+	return m.app.GetVisibleTasks()
+}
+
+// parseDueDate は入力された期限 (YYYY-MM-DD) を解析します。空文字の場合は nil を返します。
+func parseDueDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	due, err := time.ParseInLocation(dueDateLayout, value, time.Local)
+	if err != nil {
+		return nil, app.NewAppError(app.ErrTypeValidation, fmt.Sprintf("Invalid due date %q. Use YYYY-MM-DD.", value), err)
+	}
+	return &due, nil
+}
+
+// clampCursor はタスク数の変化に合わせてカーソル位置を範囲内に収めます。
+func (m *model) clampCursor() {
+	if m.cursor >= len(m.tasks) {
+		m.cursor = len(m.tasks) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
 func (m *model) setFocus() tea.Cmd {
-	cmds := make([]tea.Cmd, 4)
-	inputs := []*textinput.Model{&m.titleInput, &m.descriptionInput, &m.priorityInput, &m.tagsInput}
+	cmds := make([]tea.Cmd, 5)
+	inputs := []*textinput.Model{&m.titleInput, &m.descriptionInput, &m.priorityInput, &m.tagsInput, &m.dueInput}
 	for i := 0; i <= len(inputs)-1; i++ {
 		if i == m.focusIndex {
 			// Set focused state
@@ -732,9 +1735,18 @@ func (m model) generateHelpText() string {
 	b.WriteString("Commands:\n")
 	b.WriteString("  [a]dd: Add a new task\n")
 	b.WriteString("  [e]dit: Edit the selected task\n")
-	b.WriteString("  [d]elete: Delete the selected task\n")
+	b.WriteString("  [d]elete: Delete the selected tasks (or the task under the cursor)\n")
+	b.WriteString("  [u]ndo: Undo the last operation\n")
+	b.WriteString("  [ctrl+r] redo: Redo the last undone operation\n")
 	b.WriteString("  [v]iew: View details of the selected task\n")
 	b.WriteString("  [c]omplete: Change status of the selected task (cycle through TODO, IN_PROGRESS, DONE, PENDING)\n")
+	b.WriteString("  [z] snooze: Hide the selected task until later (1h, tomorrow, next week)\n")
+	b.WriteString("  [W]aiting: Toggle the list of waiting (snoozed) tasks\n")
+	b.WriteString("  [T]ags: Rename, merge or delete tags across all tasks\n")
+	b.WriteString("  [w]orkspace: Switch to another task list\n")
+	b.WriteString("  [b]ackups: List, compare and restore backups\n")
+	b.WriteString("  [C]onflicts: Resolve conflicts found by 'go-task sync'\n")
+	b.WriteString("  [L]ocal+global: Show local and global tasks together (with a local task file)\n")
 	b.WriteString("  [f]ilter: Filter tasks by status\n")
 	b.WriteString("  [p]riority filter: Filter tasks by priority\n")
 	b.WriteString("  [t]ag filter: Filter tasks by tags\n")
@@ -748,6 +1760,7 @@ func (m model) generateHelpText() string {
 	b.WriteString("\nNavigation:\n")
 	b.WriteString("  [up]/[down] arrows: Move cursor in main view\n")
 	b.WriteString("  [tab]/[shift+tab]: Navigate form fields\n")
+	b.WriteString("  [ctrl+t]: Create a task from a template in the add form\n")
 	b.WriteString("  [enter]: Submit form or select item\n")
 	b.WriteString("  [esc]: Go back to main view or cancel current operation\n")
 	b.WriteString("\nPress [esc] to return to main view.\n")
@@ -770,6 +1783,18 @@ func (m model) View() string {
 			b.WriteString("Suggestion: The requested item could not be found. Please check the ID and try again.")
 		case app.ErrTypeInternal:
 			b.WriteString("Suggestion: An unexpected internal error occurred. Please check the logs for more details.")
+		case app.ErrTypeConflict:
+			b.WriteString("Suggestion: Another go-task instance saved changes first. Restart go-task to load them; your last change was not saved.")
+		case app.ErrTypePassphrase:
+			b.WriteString("Suggestion: Restart go-task and enter the passphrase, or set GO_TASK_PASSPHRASE.")
+		case app.ErrTypeVersion:
+			b.WriteString("Suggestion: Upgrade go-task to the latest version. Your data file was not modified.")
+		case app.ErrTypeCorrupted:
+			b.WriteString("Suggestion: Run 'go-task recover' to restore the task file from the newest valid backup.")
+		case app.ErrTypeGit:
+			b.WriteString("Suggestion: Your tasks were saved. Check that git works in the data directory; the next save commits the changes.")
+			b.WriteString("\n\nPress [esc] to continue or 'q' to quit.")
+			return b.String()
 		}
 
 		b.WriteString("\n\nPress 'q' to quit.")
@@ -777,8 +1802,39 @@ func (m model) View() string {
 	}
 
 	switch m.currentView {
+	case "recovery":
+		var b strings.Builder
+		b.WriteString(lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9")).Render("The task file is corrupted"))
+		b.WriteString("\n\n" + m.statusMessage + "\n\n")
+		fmt.Fprintf(&b, "%d task(s) can be salvaged from the corrupted file.\n", len(m.recovery.Salvaged))
+		if m.recovery.Backup != "" {
+			fmt.Fprintf(&b, "The newest valid backup is %s (%d other task(s)).\n", m.recovery.Backup, len(m.recovery.FromBackup))
+		} else {
+			b.WriteString("No valid backup was found.\n")
+		}
+		b.WriteString("\nThe corrupted file is kept next to the task file in either case.\n\n")
+		if m.recovery.Backup != "" {
+			b.WriteString("[b] recover from the backup and the salvaged tasks\n")
+		}
+		b.WriteString("[s] keep only the salvaged tasks\n")
+		b.WriteString("[q] quit without changing anything")
+		return b.String()
+	case "passphrase":
+		return fmt.Sprintf(
+			"This task file is encrypted.\n\n%s\n\nPassphrase:\n%s\n\n%s",
+			m.statusMessage,
+			m.passphraseInput.View(),
+			"[enter] to unlock, [esc] to quit",
+		)
 	case "main":
-		s := "GoTask CLI v1.0.0\n\n"
+		location := "workspace: " + m.app.Workspace()
+		if dir := m.app.LocalDir(); dir != "" {
+			location = "local: " + filepath.Dir(dir)
+		}
+		s := fmt.Sprintf("GoTask CLI v1.0.0  [%s]\n\n", location)
+		if m.showWaiting {
+			s += "Waiting tasks (press [W] to return to all tasks)\n\n"
+		}
 
 		if m.isFiltering {
 			filterConditions := []string{}
@@ -832,11 +1888,24 @@ func (m model) View() string {
 				}
 
 				styledTitle := lipgloss.NewStyle().Foreground(priorityColor).Render(displayTitle)
+				if done, total := t.ChecklistProgress(); total > 0 {
+					styledTitle += fmt.Sprintf(" [%d/%d]", done, total)
+				}
+				if chips := m.renderTagChips(t.Tags); chips != "" {
+					styledTitle += " " + chips
+				}
 
 				s += fmt.Sprintf("%s %s %s %s\n", cursor, statusIcon, styledTitle, lipgloss.NewStyle().Foreground(priorityColor).Render(string(t.Priority)))
 			}
 		}
 
+		if m.statusMessage != "" {
+			s += "\n" + m.statusMessage + "\n"
+		}
+		if n := len(m.app.Conflicts()); n > 0 {
+			s += lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("\n%d sync conflict(s). Press [C] to resolve them.", n)) + "\n"
+		}
+
 		total, completed, incomplete := m.app.GetTaskStats()
 		s += fmt.Sprintf("\nTotal: %d | Incomplete: %d | Completed: %d\n", total, incomplete, completed)
 		s += fmt.Sprintf("Sorted by: %s %s\n\n", m.sortBy, func() string {
@@ -846,6 +1915,11 @@ func (m model) View() string {
 			return "desc"
 		}())
 		s += "[a]dd [e]dit [d]elete [v]iew [c]omplete [f]ilter [p]riority filter [t]ag filter [s]earch [o]sort [g]settings [x]export [i]import [q]uit [h]elp\n"
+		s += "[u]ndo [ctrl+r] redo [z] snooze [W]aiting [T]ags [w]orkspace"
+		if m.app.LocalDir() != "" {
+			s += " [L]ocal+global"
+		}
+		s += "\n"
 		return s
 
 	case "detail":
@@ -853,37 +1927,277 @@ func (m model) View() string {
 			return "Error: No task selected for detail view. Press [esc] to return to main."
 		}
 		t := m.detailViewTask
-		s := "Task Details\n\n"
+		if m.detailTab == "history" {
+			s := "Task Details   Details | [History]\n\n"
+			history := m.app.GetTaskHistory(t.ID)
+			if len(history) == 0 {
+				s += "No history recorded for this task.\n"
+			}
+			for _, h := range history {
+				s += fmt.Sprintf("%s [%s] %s: %q -> %q\n", h.Timestamp.Format("2006-01-02 15:04:05"), h.Source, h.Field, h.OldValue, h.NewValue)
+			}
+			s += "\n[tab] to switch tab, [esc] to back\n"
+			return s
+		}
+		s := "Task Details   [Details] | History\n\n"
 		s += fmt.Sprintf("ID: %s\n", t.ID)
 		s += fmt.Sprintf("Title: %s\n", t.Title)
 		s += fmt.Sprintf("Description: %s\n", t.Description)
 		s += fmt.Sprintf("Status: %s\n", t.Status)
 		s += fmt.Sprintf("Priority: %s\n", t.Priority)
 		s += fmt.Sprintf("Tags: %s\n", strings.Join(t.Tags, ", "))
+		if t.DueDate != nil {
+			s += fmt.Sprintf("Due Date: %s\n", t.DueDate.Format(dueDateLayout))
+		}
+		if t.WaitUntil != nil {
+			s += fmt.Sprintf("Waiting Until: %s\n", t.WaitUntil.Format("2006-01-02 15:04:05"))
+		}
+		done, total := t.ChecklistProgress()
+		s += fmt.Sprintf("Checklist: [%d/%d]\n", done, total)
+		for i, item := range t.Checklist {
+			cursor := " "
+			if i == m.checklistCursor {
+				cursor = ">"
+			}
+			mark := "[ ]"
+			if item.Done {
+				mark = "[x]"
+			}
+			s += fmt.Sprintf("  %s %s %s\n", cursor, mark, item.Text)
+		}
+		if urgency, err := m.app.GetTaskUrgency(t.ID); err == nil {
+			s += fmt.Sprintf("Urgency: %.2f\n", urgency)
+		}
 		s += fmt.Sprintf("Created At: %s\n", t.CreatedAt.Format("2006-01-02 15:04:05"))
 		s += fmt.Sprintf("Updated At: %s\n", t.UpdatedAt.Format("2006-01-02 15:04:05"))
 		if t.CompletedAt != nil {
 			s += fmt.Sprintf("Completed At: %s\n", t.CompletedAt.Format("2006-01-02 15:04:05"))
 		}
-		s += "\n[esc] to back\n"
+		s += "\n[n]ew item [x]/[space] toggle [K]/[J] move up/down [up]/[down] select item\n"
+		s += "[tab] to switch tab, [esc] to back\n"
 		return s
 
+	case "checklist_add":
+		return fmt.Sprintf(
+			"Add Checklist Item to \"%s\"\n\n%s\n\n%s",
+			m.detailViewTask.Title,
+			m.checklistInput.View(),
+			"[enter] to add, [esc] to cancel",
+		)
+	case "tags":
+		var b strings.Builder
+		b.WriteString("Manage Tags\n\n")
+		tags := m.app.GetAllUniqueTags()
+		if len(tags) == 0 {
+			b.WriteString("No tags available.\n")
+		}
+		for i, tag := range tags {
+			cursor := " "
+			if i == m.tagCursor {
+				cursor = ">"
+			}
+			// Child tags are indented under their parent; counts include child tags
+			count := len(m.app.GetFilteredTasksByTags([]string{tag}))
+			indent := strings.Repeat("  ", task.TagDepth(tag))
+			line := fmt.Sprintf("%s %s%s (%d)", cursor, indent, m.renderTagChips([]string{tag}), count)
+			if info, ok := m.app.GetTagInfo(tag); ok && info.Description != "" {
+				line += " " + info.Description
+			}
+			b.WriteString(line + "\n")
+		}
+		if m.statusMessage != "" {
+			b.WriteString("\n" + m.statusMessage + "\n")
+		}
+		b.WriteString("\n[r]ename [m]erge into [d]elete from all tasks, [esc] to back")
+		return b.String()
+	case "tag_edit":
+		tag := m.app.GetAllUniqueTags()[m.tagCursor]
+		title := fmt.Sprintf("Rename \"%s\" (child tags are renamed too)", tag)
+		if m.tagAction == "merge" {
+			title = fmt.Sprintf("Merge \"%s\" into", tag)
+		}
+		return fmt.Sprintf(
+			"%s\n\n%s\n\n%s",
+			title,
+			m.tagInput.View(),
+			"[enter] to apply, [esc] to cancel",
+		)
+	case "combined":
+		var b strings.Builder
+		for _, section := range []struct {
+			title string
+			tasks []task.Task
+		}{
+			{fmt.Sprintf("Local tasks (%s)", filepath.Dir(m.app.LocalDir())), m.app.GetVisibleTasks()},
+			{fmt.Sprintf("Global tasks (workspace: %s)", m.globalApp.Workspace()), m.globalApp.GetVisibleTasks()},
+		} {
+			b.WriteString(section.title + "\n")
+			if len(section.tasks) == 0 {
+				b.WriteString("  No tasks.\n")
+			}
+			for _, t := range section.tasks {
+				priorityColor := priorityColors[t.Priority]
+				b.WriteString(fmt.Sprintf("  %s %s %s\n", statusIcons[t.Status], lipgloss.NewStyle().Foreground(priorityColor).Render(t.Title), lipgloss.NewStyle().Foreground(priorityColor).Render(string(t.Priority))))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("[L] or [esc] to return to the local task list")
+		return b.String()
+	case "backups":
+		var b strings.Builder
+		b.WriteString("Backups\n\n")
+		if len(m.backups) == 0 {
+			b.WriteString("No backups yet.\n")
+		}
+		for i, st := range m.backups {
+			cursor := " "
+			if i == m.backupCursor {
+				cursor = ">"
+			}
+			status := fmt.Sprintf("%d task(s)", st.TaskCount)
+			if st.Err != nil {
+				status = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("INVALID: " + st.Err.Error())
+			}
+			b.WriteString(fmt.Sprintf("%s %s  %s  %s\n", cursor, st.CreatedAt.Local().Format("2006-01-02 15:04:05"), st.Name, status))
+		}
+		if m.statusMessage != "" {
+			b.WriteString("\n" + m.statusMessage + "\n")
+		}
+		b.WriteString("\nThe current tasks are backed up before every restore.\n")
+		b.WriteString("\n[enter] to compare with the current tasks, [R]estore all tasks and settings, [esc] to back")
+		return b.String()
+	case "conflicts":
+		var b strings.Builder
+		b.WriteString("Sync conflicts\n\n")
+		conflicts := m.app.Conflicts()
+		if len(conflicts) == 0 {
+			b.WriteString("No conflicts.\n")
+		}
+		for i, c := range conflicts {
+			cursor := " "
+			if i == m.conflictCursor {
+				cursor = ">"
+			}
+			switch {
+			case c.Local == nil:
+				b.WriteString(fmt.Sprintf("%s %s: deleted locally, changed remotely\n", cursor, c.Remote.Title))
+			case c.Remote == nil:
+				b.WriteString(fmt.Sprintf("%s %s: changed locally, deleted remotely\n", cursor, c.Local.Title))
+			default:
+				b.WriteString(fmt.Sprintf("%s %s\n", cursor, c.Local.Title))
+				// The diff from the remote to the local task lists both values of each conflicting field
+				changes := make(map[string]task.HistoryEntry)
+				for _, e := range task.DiffTask(c.Remote, c.Local, c.DetectedAt, "") {
+					changes[e.Field] = e
+				}
+				for _, field := range c.Fields {
+					e := changes[field]
+					b.WriteString(fmt.Sprintf("      %s: local %q, remote %q\n", field, e.NewValue, e.OldValue))
+				}
+			}
+		}
+		if m.statusMessage != "" {
+			b.WriteString("\n" + m.statusMessage + "\n")
+		}
+		b.WriteString("\nThe newer version is currently applied. Resolved tasks are sent to the other side on the next sync.\n")
+		b.WriteString("\n[l] keep local, [r] use remote, [esc] to back")
+		return b.String()
+	case "backup_diff":
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("Differences from %s\n\n", m.backups[m.backupCursor].Name))
+		if m.backupDiff.Empty() {
+			b.WriteString("No differences.\n")
+		}
+		for i, t := range m.backupDiff.RestorableTasks() {
+			cursor := " "
+			if i == m.diffCursor {
+				cursor = ">"
+			}
+			if i < len(m.backupDiff.Removed) {
+				b.WriteString(fmt.Sprintf("%s - %s (only in backup)\n", cursor, t.Title))
+				continue
+			}
+			d := m.backupDiff.Modified[i-len(m.backupDiff.Removed)]
+			b.WriteString(fmt.Sprintf("%s ~ %s\n", cursor, d.Current.Title))
+			for _, c := range d.Changes {
+				b.WriteString(fmt.Sprintf("      %s: %q -> %q\n", c.Field, c.OldValue, c.NewValue))
+			}
+		}
+		for _, t := range m.backupDiff.Added {
+			b.WriteString(fmt.Sprintf("  + %s (not in backup)\n", t.Title))
+		}
+		if m.backupDiff.SettingsChanged {
+			b.WriteString("  ~ settings\n")
+		}
+		if m.statusMessage != "" {
+			b.WriteString("\n" + m.statusMessage + "\n")
+		}
+		b.WriteString("\n[enter] to restore the selected task from the backup, [esc] to back")
+		return b.String()
+	case "workspaces":
+		var b strings.Builder
+		b.WriteString("Switch Workspace\n\n")
+		for i, name := range m.workspaces {
+			cursor := " "
+			if i == m.workspaceCursor {
+				cursor = ">"
+			}
+			current := ""
+			if name == m.app.Workspace() {
+				current = " (current)"
+			}
+			b.WriteString(fmt.Sprintf("%s %s%s\n", cursor, name, current))
+		}
+		b.WriteString("\nUse 'go-task workspace create <name>' to add a workspace.\n")
+		b.WriteString("\n[enter] to switch, [esc] to cancel")
+		return b.String()
+	case "template_select":
+		var b strings.Builder
+		b.WriteString("New Task from Template\n\n")
+		if len(m.templates) == 0 {
+			b.WriteString("No templates saved. Use 'go-task template save <name> <task-id>' to create one.\n")
+		}
+		for i, tpl := range m.templates {
+			cursor := " "
+			if i == m.templateCursor {
+				cursor = ">"
+			}
+			b.WriteString(fmt.Sprintf("%s %s (%s)\n", cursor, tpl.Name, tpl.Title))
+		}
+		b.WriteString("\n[enter] to use, [esc] to cancel")
+		return b.String()
+	case "template_vars":
+		return fmt.Sprintf(
+			"Template \"%s\"\n\n%s:\n%s\n\n%s",
+			m.templates[m.templateCursor].Name,
+			m.templateVars[0],
+			m.templateVarInput.View(),
+			"[enter] to continue, [esc] to cancel",
+		)
+	case "snooze":
+		return fmt.Sprintf(
+			"Snooze \"%s\"\n\n[1] 1 hour\n[2] Tomorrow morning\n[3] Next Monday morning\n[0] Wake up now\n\n%s",
+			m.tasks[m.cursor].Title,
+			"[esc] to cancel",
+		)
 	case "add":
 		return fmt.Sprintf(
-			"Add New Task\n\n%s\n%s\n%s\n%s\n\n%s",
+			"Add New Task\n\n%s\n%s\n%s\n%s\n%s\n\n%s",
 			m.titleInput.View(),
 			m.descriptionInput.View(),
 			m.priorityInput.View(),
 			m.tagsInput.View(),
-			"[enter] to submit, [esc] to cancel",
+			m.dueInput.View(),
+			"[enter] to submit, [ctrl+t] from template, [esc] to cancel",
 		)
 	case "edit":
 		return fmt.Sprintf(
-			"Edit Task\n\n%s\n%s\n%s\n%s\n\n%s",
+			"Edit Task\n\n%s\n%s\n%s\n%s\n%s\n\n%s",
 			m.titleInput.View(),
 			m.descriptionInput.View(),
 			m.priorityInput.View(),
 			m.tagsInput.View(),
+			m.dueInput.View(),
 			"[enter] to save, [esc] to cancel",
 		)
 	case "filter":
@@ -920,7 +2234,7 @@ func (m model) View() string {
 		)
 	case "sort":
 		return fmt.Sprintf(
-			"Sort Tasks (e.g., created_at asc, priority desc)\n\n%s\n\n%s",
+			"Sort Tasks (e.g., created_at asc, priority desc, urgency desc)\n\n%s\n\n%s",
 			m.sortInput.View(),
 			"[enter] to apply sort, [esc] to cancel",
 		)
@@ -957,10 +2271,34 @@ func main() {
 		defer profile.Start(profile.MemProfile, profile.ProfilePath(".")).Stop()
 	}
 
+	// 暗号化されたタスクファイルのパスフレーズは環境変数でも指定できる
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		store.SetPassphrase(passphrase)
+	}
+
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n%s", err, generateCommandLineHelp())
+		os.Exit(2)
+	}
+
+	// サブコマンドが指定された場合はTUIを起動せずに実行する
+	if len(args) > 0 {
+		os.Exit(runCommand(args, os.Stdout, os.Stderr))
+	}
+
 	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		log.Error("Application failed:", err)
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	// Take the on-exit backup for whichever task file was open when the TUI quit,
+	// and for the global task list if it was shown alongside it.
+	if fm, ok := final.(model); ok {
+		if err := closeApps(fm.app, fm.globalApp); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
 }
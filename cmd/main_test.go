@@ -3,10 +3,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"go-task/internal/app"
+	"go-task/internal/paths"
 	"go-task/internal/store"
 	"go-task/internal/task"
 
@@ -222,3 +225,653 @@ func TestView(t *testing.T) {
 		t.Errorf("View missing footer menu")
 	}
 }
+
+func TestUndoKey(t *testing.T) {
+	m := initialModel()
+	if _, err := m.app.AddTask("Undo Me", "", task.PriorityLow, nil); err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	m.tasks = m.app.GetAllTasks()
+	count := len(m.tasks)
+
+	// Change status with 'c', then undo with 'u'
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = updatedModel.(model)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = updatedModel.(model)
+	if m.tasks[0].Status != task.StatusTODO {
+		t.Errorf("Expected status to be restored to TODO, got %s", m.tasks[0].Status)
+	}
+	if !strings.Contains(m.View(), "Undid update.") {
+		t.Errorf("Expected undo feedback in view")
+	}
+
+	// Redo with ctrl+r
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = updatedModel.(model)
+	if m.tasks[0].Status != task.StatusInProgress {
+		t.Errorf("Expected status to be IN_PROGRESS after redo, got %s", m.tasks[0].Status)
+	}
+
+	// Delete with 'd' and undo
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = updatedModel.(model)
+	if len(m.tasks) != count-1 {
+		t.Errorf("Expected %d tasks after delete, got %d", count-1, len(m.tasks))
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = updatedModel.(model)
+	if len(m.tasks) != count {
+		t.Errorf("Expected %d tasks after undoing delete, got %d", count, len(m.tasks))
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	var stdout, stderr strings.Builder
+	if code := runCommand([]string{"help"}, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit code 0 for help, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "undo") {
+		t.Errorf("Expected help to list the undo command, got %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runCommand([]string{"unknown-command"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 for unknown command, got %d", code)
+	}
+}
+
+func TestCSVCommand(t *testing.T) {
+	configDir, err := store.GetConfigDirPath()
+	if err != nil {
+		t.Fatalf("GetConfigDirPath failed: %v", err)
+	}
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	file := filepath.Join(configDir, "cli_import.csv")
+	if err := os.WriteFile(file, []byte("Task,Importance\nFrom CSV,high\nBroken,urgent\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	defer os.Remove(file)
+
+	var stdout, stderr strings.Builder
+	if code := runCommand([]string{"csv", "import", file, "--map", "title=Task,priority=Importance", "--dry-run"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0 for csv import, got %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"title        <- Task", `row 3: priority: unknown priority "urgent"`, "1 task(s) would be imported"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected %q in the dry run output, got:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := runCommand([]string{"csv", "export", file, "--columns", "title,owner"}, &stdout, &stderr); code == 0 {
+		t.Errorf("Expected a non-zero exit code for an unknown column")
+	}
+}
+
+func TestSnoozeKey(t *testing.T) {
+	m := initialModel()
+	if _, err := m.app.AddTask("Snooze Me", "", task.PriorityLow, nil); err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	m.tasks = m.app.GetVisibleTasks()
+	m.cursor = len(m.tasks) - 1
+	count := len(m.tasks)
+
+	// Snooze until tomorrow with 'z' then '2'
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	m = updatedModel.(model)
+	if m.currentView != "snooze" {
+		t.Fatalf("Expected view to be 'snooze', got %s", m.currentView)
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = updatedModel.(model)
+	if m.currentView != "main" || len(m.tasks) != count-1 {
+		t.Errorf("Expected snoozed task to be hidden, got %d tasks in view %s", len(m.tasks), m.currentView)
+	}
+
+	// The waiting view lists the snoozed task
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("W")})
+	m = updatedModel.(model)
+	if len(m.tasks) != 1 || m.tasks[0].Title != "Snooze Me" {
+		t.Fatalf("Expected waiting view to list the snoozed task, got %v", m.tasks)
+	}
+
+	// Wake it up with 'z' then '0'
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	m = updatedModel.(model)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("0")})
+	m = updatedModel.(model)
+	if len(m.tasks) != 0 {
+		t.Errorf("Expected waiting view to be empty after wake up, got %d tasks", len(m.tasks))
+	}
+}
+
+func TestDetailHistoryTab(t *testing.T) {
+	m := initialModel()
+	added, err := m.app.AddTask("History Tab", "", task.PriorityLow, nil)
+	if err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	if _, err := m.app.UpdateTask(added.ID, "History Tab Renamed", "", "", "", nil); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	m.tasks = m.app.GetVisibleTasks()
+	m.cursor = len(m.tasks) - 1
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	m = updatedModel.(model)
+	if !strings.Contains(m.View(), "[Details] | History") {
+		t.Fatalf("Expected the details tab, got:\n%s", m.View())
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	view := m.View()
+	if !strings.Contains(view, "Details | [History]") || !strings.Contains(view, `title: "History Tab" -> "History Tab Renamed"`) {
+		t.Errorf("Expected the history tab with the title change, got:\n%s", view)
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	m = updatedModel.(model)
+	if !strings.Contains(m.View(), "[Details] | History") {
+		t.Errorf("Expected shift+tab to return to the details tab")
+	}
+}
+
+func TestChecklistKeys(t *testing.T) {
+	m := initialModel()
+	if _, err := m.app.AddTask("Checklist Task", "", task.PriorityLow, nil); err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	m.tasks = m.app.GetVisibleTasks()
+	m.cursor = len(m.tasks) - 1
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	m = updatedModel.(model)
+
+	// Add an item with 'n' and enter
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m = updatedModel.(model)
+	if m.currentView != "checklist_add" {
+		t.Fatalf("Expected view to be 'checklist_add', got %s", m.currentView)
+	}
+	m.checklistInput.SetValue("First step")
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "detail" || len(m.detailViewTask.Checklist) != 1 {
+		t.Fatalf("Expected one checklist item in detail view, got %v in %s", m.detailViewTask.Checklist, m.currentView)
+	}
+
+	// Toggle it with 'x'
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = updatedModel.(model)
+	if !m.detailViewTask.Checklist[0].Done {
+		t.Errorf("Expected checklist item to be done after 'x'")
+	}
+	if !strings.Contains(m.View(), "Checklist: [1/1]") {
+		t.Errorf("Expected detail view to show checklist progress")
+	}
+}
+
+func TestTemplateKeys(t *testing.T) {
+	m := initialModel()
+	tpl := task.Template{Name: "weekly", Title: "Weekly report {{.week}}"}
+	if err := m.app.SaveTemplate(tpl); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	defer m.app.DeleteTemplate("weekly")
+	count := len(m.app.GetAllTasks())
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = updatedModel.(model)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	m = updatedModel.(model)
+	if m.currentView != "template_select" {
+		t.Fatalf("Expected view to be 'template_select', got %s", m.currentView)
+	}
+	m.templateCursor = len(m.templates) - 1
+
+	// Choosing the template prompts for its variable
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "template_vars" {
+		t.Fatalf("Expected view to be 'template_vars', got %s", m.currentView)
+	}
+	m.templateVarInput.SetValue("42")
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "main" {
+		t.Fatalf("Expected view to be 'main', got %s", m.currentView)
+	}
+
+	tasks := m.app.GetAllTasks()
+	if len(tasks) != count+1 || tasks[len(tasks)-1].Title != "Weekly report 42" {
+		t.Errorf("Expected a task created from the template, got %v", tasks[len(tasks)-1])
+	}
+}
+
+func TestTagView(t *testing.T) {
+	m := initialModel()
+	if _, err := m.app.AddTask("Tagged", "", task.PriorityLow, []string{"zz-old/child"}); err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("T")})
+	m = updatedModel.(model)
+	if m.currentView != "tags" {
+		t.Fatalf("Expected view to be 'tags', got %s", m.currentView)
+	}
+	tags := m.app.GetAllUniqueTags()
+	m.tagCursor = len(tags) - 1
+
+	// Rename the selected tag with 'r'
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updatedModel.(model)
+	if m.currentView != "tag_edit" {
+		t.Fatalf("Expected view to be 'tag_edit', got %s", m.currentView)
+	}
+	m.tagInput.SetValue("zz-new")
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "tags" {
+		t.Fatalf("Expected view to be 'tags', got %s", m.currentView)
+	}
+	if got := m.app.GetFilteredTasksByTags([]string{"zz-new"}); len(got) != 1 || got[0].Tags[0] != "zz-new" {
+		t.Errorf("Expected tag to be renamed to zz-new, got %v", got)
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	defer paths.SetDataDir("")
+
+	dir := t.TempDir()
+	args, err := parseGlobalFlags([]string{"--data-dir", dir, "tag", "list"})
+	if err != nil || strings.Join(args, " ") != "tag list" {
+		t.Fatalf("parseGlobalFlags() = %v, %v, want [tag list]", args, err)
+	}
+	if got, _ := store.GetConfigDirPath(); got != dir {
+		t.Errorf("data dir after --data-dir = %q, want %q", got, dir)
+	}
+
+	args, err = parseGlobalFlags([]string{"--data-dir=" + dir})
+	if err != nil || len(args) != 0 {
+		t.Errorf("parseGlobalFlags() with --data-dir=<dir> = %v, %v", args, err)
+	}
+	if _, err := parseGlobalFlags([]string{"--data-dir"}); err == nil {
+		t.Errorf("parseGlobalFlags() without a directory should fail")
+	}
+}
+
+func TestWorkspaceKeys(t *testing.T) {
+	if err := app.CreateWorkspace("tui-test"); err != nil {
+		t.Fatalf("CreateWorkspace failed: %v", err)
+	}
+	defer app.DeleteWorkspace("tui-test")
+
+	m := initialModel()
+	global, err := app.NewGlobalApp()
+	if err != nil {
+		t.Fatalf("NewGlobalApp failed: %v", err)
+	}
+	m.globalApp = global
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	m = updatedModel.(model)
+	if m.currentView != "workspaces" {
+		t.Fatalf("Expected view to be 'workspaces', got %s", m.currentView)
+	}
+	for i, name := range m.workspaces {
+		if name == "tui-test" {
+			m.workspaceCursor = i
+		}
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "main" || m.app.Workspace() != "tui-test" {
+		t.Fatalf("Expected to switch to tui-test, got %q in view %s", m.app.Workspace(), m.currentView)
+	}
+	if !strings.Contains(m.View(), "[workspace: tui-test]") {
+		t.Errorf("Expected the header to show the current workspace")
+	}
+	if len(m.tasks) != 0 {
+		t.Errorf("Expected the new workspace to be empty, got %d tasks", len(m.tasks))
+	}
+	if m.cfg.CurrentWorkspace != "tui-test" {
+		t.Errorf("Expected the in-memory config to follow the switch, got %q", m.cfg.CurrentWorkspace)
+	}
+	// The global task list of the previous workspace is closed and opened again on the next 'L'
+	if m.globalApp != nil {
+		t.Errorf("Expected the global task list of the previous workspace to be closed")
+	}
+}
+
+func TestCombinedView(t *testing.T) {
+	m := initialModel()
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	if updatedModel.(model).currentView != "main" {
+		t.Fatalf("Expected 'L' to be ignored without a local task file")
+	}
+
+	localDir, err := app.InitLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitLocal failed: %v", err)
+	}
+	local, err := app.NewLocalApp(localDir)
+	if err != nil {
+		t.Fatalf("NewLocalApp failed: %v", err)
+	}
+	local.AddTask("Local only", "", task.PriorityLow, nil)
+	m.app = local
+	m.tasks = m.listTasks()
+	if !strings.Contains(m.View(), "[local: "+filepath.Dir(localDir)+"]") {
+		t.Errorf("Expected the header to show the local task file")
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	m = updatedModel.(model)
+	if m.currentView != "combined" {
+		t.Fatalf("Expected view to be 'combined', got %s", m.currentView)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Local only") || !strings.Contains(view, "Global tasks") {
+		t.Errorf("Expected the combined view to list local and global tasks, got %s", view)
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	if updatedModel.(model).currentView != "main" {
+		t.Errorf("Expected 'L' to return to the main view")
+	}
+}
+
+func TestPassphrasePrompt(t *testing.T) {
+	a, err := app.NewApp()
+	if err != nil {
+		t.Fatalf("NewApp failed: %v", err)
+	}
+	if err := a.SetEncryption("open sesame"); err != nil {
+		t.Fatalf("SetEncryption failed: %v", err)
+	}
+	defer func() {
+		store.SetPassphrase("open sesame")
+		if a, err := app.NewApp(); err == nil {
+			a.SetEncryption("")
+		}
+		store.SetPassphrase("")
+	}()
+
+	store.SetPassphrase("")
+	m := initialModel()
+	if m.currentView != "passphrase" || m.err != nil {
+		t.Fatalf("Expected the passphrase prompt, got view %s (err %v)", m.currentView, m.err)
+	}
+
+	m.passphraseInput.SetValue("wrong")
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "passphrase" || !strings.Contains(m.View(), "Wrong passphrase") {
+		t.Fatalf("Expected the prompt to report a wrong passphrase, got view %s", m.currentView)
+	}
+
+	m.passphraseInput.SetValue("open sesame")
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "main" || m.app == nil {
+		t.Errorf("Expected the main view after unlocking, got %s", m.currentView)
+	}
+}
+
+func TestBackupView(t *testing.T) {
+	m := initialModel()
+	added, err := m.app.AddTask("Backed up", "", task.PriorityLow, nil)
+	if err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	if err := m.app.Close(); err != nil { // on_exit takes a backup
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := m.app.DeleteTask(added.ID); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+	m.tasks = m.listTasks()
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = updatedModel.(model)
+	if m.currentView != "backups" || len(m.backups) == 0 {
+		t.Fatalf("Expected the backup view with backups, got view %s with %d backups", m.currentView, len(m.backups))
+	}
+	if !strings.Contains(m.View(), "task(s)") {
+		t.Errorf("Expected task counts in the backup view")
+	}
+
+	// Compare the newest backup and restore the deleted task from it
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.currentView != "backup_diff" {
+		t.Fatalf("Expected view to be 'backup_diff', got %s", m.currentView)
+	}
+	if !strings.Contains(m.View(), "Backed up (only in backup)") {
+		t.Errorf("Expected the deleted task in the diff, got %s", m.View())
+	}
+	for i, r := range m.backupDiff.RestorableTasks() {
+		if r.ID == added.ID {
+			m.diffCursor = i
+		}
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if _, err := m.app.GetTaskByID(added.ID); err != nil {
+		t.Errorf("Expected the task to be restored: %v", err)
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updatedModel.(model)
+	if m.currentView != "backups" {
+		t.Errorf("Expected esc to return to the backup list, got %s", m.currentView)
+	}
+}
+
+func TestRecoveryPrompt(t *testing.T) {
+	dataDir, err := paths.DataDir()
+	if err != nil {
+		t.Fatalf("DataDir failed: %v", err)
+	}
+	path := filepath.Join(dataDir, "tasks.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	corrupted := `{"version": "1.1.0", "tasks": [{"id": "r1", "title": "Salvage me", "status": "TODO", "priority": "LOW"}, {"id": "r2", "tit`
+	if err := os.WriteFile(path, []byte(corrupted), 0600); err != nil {
+		t.Fatalf("Failed to write task file: %v", err)
+	}
+	defer os.Remove(path)
+
+	m := initialModel()
+	if m.currentView != "recovery" {
+		t.Fatalf("Expected the recovery view for a corrupted task file, got %s (err %v)", m.currentView, m.err)
+	}
+	if !strings.Contains(m.View(), "1 task(s) can be salvaged") {
+		t.Errorf("Expected the salvage count in the recovery view, got %s", m.View())
+	}
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = updatedModel.(model)
+	if m.currentView != "main" || m.err != nil {
+		t.Fatalf("Expected the main view after recovery, got %s (err %v)", m.currentView, m.err)
+	}
+	if len(m.tasks) != 1 || m.tasks[0].Title != "Salvage me" {
+		t.Errorf("Expected the salvaged task, got %+v", m.tasks)
+	}
+	if !strings.Contains(m.View(), "Recovered 1 task(s)") {
+		t.Errorf("Expected the recovery summary in the main view")
+	}
+	matches, _ := filepath.Glob(path + ".corrupt-*")
+	for _, match := range matches {
+		os.Remove(match)
+	}
+	if len(matches) != 1 {
+		t.Errorf("Expected the corrupted file to be quarantined, found %v", matches)
+	}
+}
+
+func TestReloadCheck(t *testing.T) {
+	m := initialModel()
+	if _, err := m.app.AddTask("Reload Cursor", "", task.PriorityHigh, nil); err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	m.tasks = m.listTasks()
+	m.cursor = len(m.tasks) - 1
+	cursorID := m.tasks[m.cursor].ID
+
+	// Nothing changed on disk: the tick leaves the model alone
+	updatedModel, cmd := m.Update(reloadCheckMsg(time.Now()))
+	m = updatedModel.(model)
+	if cmd == nil || m.statusMessage != "" {
+		t.Fatalf("Expected an unchanged model and another tick, got status %q", m.statusMessage)
+	}
+
+	// Another process adds a task in front of the one under the cursor
+	other, err := app.NewApp()
+	if err != nil {
+		t.Fatalf("NewApp failed: %v", err)
+	}
+	if _, err := other.AddTaskFrom(task.Task{Title: "From Another Process", Priority: task.PriorityHigh}); err != nil {
+		t.Fatalf("AddTaskFrom failed: %v", err)
+	}
+	other.Tasks.Tasks[0], other.Tasks.Tasks[len(other.Tasks.Tasks)-1] = other.Tasks.Tasks[len(other.Tasks.Tasks)-1], other.Tasks.Tasks[0]
+	if err := other.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// The tick only starts the reload; the tasks are applied when its result arrives
+	updatedModel, cmd = m.Update(reloadCheckMsg(time.Now()))
+	m = updatedModel.(model)
+	if !m.reloading || m.statusMessage != "" {
+		t.Fatalf("Expected a reload to be started without touching the list, got status %q", m.statusMessage)
+	}
+	if _, again := m.Update(reloadCheckMsg(time.Now())); again == nil {
+		t.Fatalf("Expected the next tick to be scheduled while reloading")
+	}
+	reloaded := awaitReloaded(t, cmd)
+	updatedModel, _ = m.Update(reloaded)
+	m = updatedModel.(model)
+	if m.reloading {
+		t.Errorf("Expected the reload to be finished")
+	}
+	if m.statusMessage != "Reloaded tasks changed by another process." {
+		t.Errorf("Expected a reload message, got %q", m.statusMessage)
+	}
+	found := false
+	for _, tk := range m.tasks {
+		found = found || tk.Title == "From Another Process"
+	}
+	if !found {
+		t.Errorf("Expected the task added by another process to be listed")
+	}
+	if m.tasks[m.cursor].ID != cursorID {
+		t.Errorf("Expected the cursor to stay on task %s, got %s", cursorID, m.tasks[m.cursor].ID)
+	}
+}
+
+// awaitReloaded runs the commands returned for a reload tick and returns the reloadedMsg.
+// The reload tick itself is left running in the background.
+func awaitReloaded(t *testing.T, cmd tea.Cmd) reloadedMsg {
+	t.Helper()
+	msgs := make(chan tea.Msg, 4)
+	var run func(tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, c := range batch {
+				go run(c)
+			}
+			return
+		}
+		msgs <- msg
+	}
+	go run(cmd)
+	for {
+		select {
+		case msg := <-msgs:
+			if reloaded, ok := msg.(reloadedMsg); ok {
+				return reloaded
+			}
+		case <-time.After(reloadCheckInterval / 2):
+			t.Fatalf("Expected a reloadedMsg from the reload command")
+		}
+	}
+}
+
+func TestConflictView(t *testing.T) {
+	m := initialModel()
+	local, err := m.app.AddTask("Laptop Title", "", task.PriorityLow, nil)
+	if err != nil {
+		t.Fatalf("AddTask failed: %v", err)
+	}
+	remote := local.Clone()
+	remote.Title = "Desktop Title"
+	m.app.Tasks.Conflicts = []task.SyncConflict{{TaskID: local.ID, Fields: []string{"title"}, Local: local, Remote: &remote}}
+	m.tasks = m.listTasks()
+	if !strings.Contains(m.View(), "1 sync conflict(s)") {
+		t.Errorf("Expected a conflict hint in the main view")
+	}
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("C")})
+	m = updatedModel.(model)
+	if m.currentView != "conflicts" {
+		t.Fatalf("Expected view to be 'conflicts', got %s", m.currentView)
+	}
+	if !strings.Contains(m.View(), `title: local "Laptop Title", remote "Desktop Title"`) {
+		t.Errorf("Expected both values of the conflicting field, got:\n%s", m.View())
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updatedModel.(model)
+	if got, _ := m.app.GetTaskByID(local.ID); got.Title != "Desktop Title" {
+		t.Errorf("Expected the remote title after [r], got %q", got.Title)
+	}
+	if len(m.app.Conflicts()) != 0 || !strings.Contains(m.View(), "No conflicts.") {
+		t.Errorf("Expected the conflict to be resolved, got %d conflicts", len(m.app.Conflicts()))
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updatedModel.(model)
+	if m.currentView != "main" {
+		t.Errorf("Expected view to be 'main' after esc, got %s", m.currentView)
+	}
+}
+
+func TestGitErrorCanBeDismissed(t *testing.T) {
+	m := initialModel()
+	m.err = app.NewAppError(app.ErrTypeGit, "Tasks were saved, but the git commit failed.", nil)
+	if !strings.Contains(m.View(), "Your tasks were saved") {
+		t.Errorf("Expected the git error to say the tasks were saved, got:\n%s", m.View())
+	}
+	// Background checks keep rescheduling themselves while the error is shown
+	for _, tick := range []tea.Msg{waitCheckMsg(time.Now()), reloadCheckMsg(time.Now())} {
+		if _, cmd := m.Update(tick); cmd == nil {
+			t.Errorf("Expected %T to be rescheduled while the git error is shown", tick)
+		}
+	}
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updatedModel.(model)
+	if m.err != nil || !strings.Contains(m.statusMessage, "without a git commit") {
+		t.Errorf("Expected esc to dismiss the git error, got err %v and status %q", m.err, m.statusMessage)
+	}
+	for _, tick := range []tea.Msg{waitCheckMsg(time.Now()), reloadCheckMsg(time.Now())} {
+		if _, cmd := m.Update(tick); cmd == nil {
+			t.Errorf("Expected %T to be rescheduled after dismissing the git error", tick)
+		}
+	}
+
+	// Other errors still require quitting
+	m.err = app.NewAppError(app.ErrTypeIO, "Failed to save tasks.", nil)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updatedModel.(model)
+	if m.err == nil {
+		t.Errorf("Expected esc to keep an IO error")
+	}
+}
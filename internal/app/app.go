@@ -1,14 +1,15 @@
 package app
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
 	"time"
 
+	"go-task/internal/config"
 	"go-task/internal/log"
+	"go-task/internal/paths"
 	"go-task/internal/store"
 	"go-task/internal/task"
 
@@ -18,13 +19,119 @@ import (
 // App はアプリケーションの主要なロジックを管理します。
 type App struct {
 	Tasks *task.Tasks
+	// storage はタスクデータの保存先です。
+	storage store.Store
+	// UrgencyCoefficients は緊急度スコアの計算に使用する係数です。
+	UrgencyCoefficients task.UrgencyCoefficients
+	// workspace はタスクデータを読み込んだワークスペースの名前です。
+	workspace string
+	// localDir はローカルのタスクファイルを使用している場合の .go-task ディレクトリのパスです。
+	localDir string
+	// backup は自動バックアップの設定です。
+	backup config.BackupSettings
+	// index は Tasks.Tasks の索引です。repo で取得します。
+	index *repository
+	// pending は保存していないタスクの変更の記録です。Reload で使用します。
+	pending *unsavedChanges
+	// edits はタスクを変更した回数です。読み込み直しの間にタスクが変更されたかの判定に使用します (ApplyReload を参照)。
+	edits int
+	// stopBackups は閉じると定期バックアップの goroutine を終了させるチャネルです。goroutine がない場合や Close の後は nil です。
+	stopBackups chan struct{}
+	// backupsDone は定期バックアップの goroutine が終了すると閉じられるチャネルです。
+	backupsDone chan struct{}
 }
 
 // NewApp は新しいAppインスタンスを作成し、タスクデータをロードします。
+// カレントディレクトリから親ディレクトリに向かってローカルのタスクファイル (.go-task/tasks.json) が見つかった場合はそれを使用し、
+// 見つからない場合や --data-dir フラグが指定されている場合は NewGlobalApp と同じです。
 func NewApp() (*App, error) {
-	tasks, err := store.LoadTasks()
+	if dir, ok := findLocalDir(); ok {
+		return NewLocalApp(dir)
+	}
+	return NewGlobalApp()
+}
+
+// findLocalDir は --data-dir フラグが指定されていない場合に、カレントディレクトリから親ディレクトリへ
+// ローカルのタスクファイルを探します。
+func findLocalDir() (string, bool) {
+	if paths.DataDirSet() {
+		return "", false
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	return store.FindLocalDir(wd)
+}
+
+// NewLocalApp は指定された .go-task ディレクトリのタスクファイルを使用する新しいAppインスタンスを作成します。
+func NewLocalApp(dir string) (*App, error) {
+	s, err := openStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newApp(s, loadBackupSettings())
+	if err != nil {
+		return nil, err
+	}
+	a.localDir = dir
+	return a, nil
+}
+
+// NewGlobalApp は設定ファイルで選択されているワークスペースからタスクデータをロードした新しいAppインスタンスを作成します。
+// 選択されているワークスペースが存在しない場合は既定のワークスペースを使用します。
+func NewGlobalApp() (*App, error) {
+	name, err := openWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	return NewAppForWorkspace(name)
+}
+
+// openWorkspace は設定ファイルで選択されているワークスペースの名前を返します。
+// 選択されているワークスペースが存在しない場合は既定のワークスペースを返します。
+func openWorkspace() (string, error) {
+	name, err := CurrentWorkspace()
+	if err != nil {
+		return "", err
+	}
+	if exists, err := store.WorkspaceExists(name); err == nil && !exists {
+		log.Error("Current workspace not found, falling back to the default workspace:", name)
+		name = store.DefaultWorkspace
+	}
+	return name, nil
+}
+
+// NewAppForWorkspace は指定されたワークスペースからタスクデータをロードした新しいAppインスタンスを作成します。
+func NewAppForWorkspace(name string) (*App, error) {
+	ws, err := store.WorkspaceStore(name)
+	if err != nil {
+		return nil, newWorkspaceError("Failed to open workspace.", err)
+	}
+	s, err := openStore(ws.Dir())
+	if err != nil {
+		return nil, err
+	}
+	a, err := newApp(s, loadBackupSettings())
+	if err != nil {
+		return nil, err
+	}
+	a.workspace = name
+	return a, nil
+}
+
+// NewAppWithStore は指定された保存先を使用する新しいAppインスタンスを作成し、タスクデータをロードします。
+// バックアップには既定の設定 (config.DefaultBackupSettings) を使用します。
+func NewAppWithStore(s store.Store) (*App, error) {
+	return newApp(s, config.DefaultBackupSettings())
+}
+
+// newApp は指定された保存先とバックアップ設定を使用する新しいAppインスタンスを作成し、タスクデータをロードします。
+func newApp(s store.Store, backup config.BackupSettings) (*App, error) {
+	s.SetBackupOptions(backup.Options())
+	tasks, err := s.Load()
 	if err != nil {
-		return nil, NewAppError(ErrTypeIO, "Failed to load tasks from storage.", err)
+		return nil, newLoadError(ErrTypeIO, "Failed to load tasks from storage.", err)
 	}
 
 	// If no tasks are loaded and not in test environment, add some dummy data for demonstration
@@ -64,30 +171,47 @@ func NewApp() (*App, error) {
 		}
 		// Save dummy data if auto-save is enabled
 		if tasks.Settings.AutoSave {
-			if err := store.SaveTasks(tasks); err != nil {
+			if err := s.Save(tasks); err != nil {
 				return nil, NewAppError(ErrTypeIO, "Failed to auto-save dummy tasks.", err)
 			}
 		}
 	}
 
-	app := &App{Tasks: tasks}
+	app := &App{Tasks: tasks, storage: s, UrgencyCoefficients: task.DefaultUrgencyCoefficients(), backup: backup}
 
-	// 自動バックアップが有効な場合、バックアップ処理をスケジュール
+	interval, err := backup.IntervalDuration()
+	if err != nil {
+		log.Error("Invalid backup interval, using the default:", err)
+		interval, _ = config.DefaultBackupSettings().IntervalDuration()
+	}
+
+	// 自動バックアップが有効な場合、バックアップ処理をスケジュール。Close で終了する
 	if app.Tasks.Settings.AutoSave {
+		stop, done := make(chan struct{}), make(chan struct{})
+		app.stopBackups, app.backupsDone = stop, done
 		go func() {
+			defer close(done)
 			// 初回起動時に古いバックアップをクリーンアップ
-			if err := store.CleanOldBackups(); err != nil {
+			if err := s.CleanOldBackups(); err != nil {
 				log.Error("Failed to clean old backups:", err)
 			}
-			// 1時間ごとにバックアップを実行
-			ticker := time.NewTicker(1 * time.Hour)
+			if interval == 0 {
+				return // 定期バックアップは無効
+			}
+			// 設定された間隔でバックアップを実行
+			ticker := time.NewTicker(interval)
 			defer ticker.Stop()
-			for range ticker.C {
-				if err := store.CreateBackup(app.Tasks); err != nil {
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+				if err := s.CreateBackup(app.Tasks); err != nil {
 					log.Error("Failed to create backup:", err)
 				} else {
 					// バックアップ成功後、古いバックアップをクリーンアップ
-					if err := store.CleanOldBackups(); err != nil {
+					if err := s.CleanOldBackups(); err != nil {
 						log.Error("Failed to clean old backups after new backup:", err)
 					}
 				}
@@ -98,37 +222,56 @@ func NewApp() (*App, error) {
 	return app, nil
 }
 
-// AddTask は新しいタスクを作成し、タスクリストに追加します。
-func (a *App) AddTask(title, description string, priority task.Priority, tags []string) (*task.Task, error) {
-	if title == "" {
-		return nil, NewAppError(ErrTypeValidation, "Title cannot be empty.", nil)
-	}
-
-	if priority == "" {
-		priority = a.Tasks.Settings.DefaultPriority
+// Save は現在のタスクデータをデータファイルに保存します。
+func (a *App) Save() error {
+	if err := a.storage.Save(a.Tasks); err != nil {
+		log.Error("Failed to save tasks:", err)
+		return newSaveError("Failed to save tasks.", err)
 	}
+	return nil
+}
 
-	newTask := task.Task{
-		ID:          uuid.New().String(),
+// AddTask は新しいタスクを作成し、タスクリストに追加します。
+func (a *App) AddTask(title, description string, priority task.Priority, tags []string) (*task.Task, error) {
+	return a.AddTaskFrom(task.Task{
 		Title:       title,
 		Description: description,
-		Status:      task.StatusTODO,
 		Priority:    priority,
 		Tags:        tags,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	})
+}
+
+// AddTaskFrom は指定された内容で新しいタスクを作成し、タスクリストに追加します。
+// ID、状態、作成日時、更新日時は自動で設定されます。
+func (a *App) AddTaskFrom(t task.Task) (*task.Task, error) {
+	if t.Title == "" {
+		return nil, NewAppError(ErrTypeValidation, "Title cannot be empty.", nil)
+	}
+
+	if t.Priority == "" {
+		t.Priority = a.Tasks.Settings.DefaultPriority
 	}
 
+	now := time.Now()
+	newTask := t.Clone()
+	newTask.ID = uuid.New().String()
+	newTask.Status = task.StatusTODO
+	newTask.CreatedAt = now
+	newTask.UpdatedAt = now
+	newTask.CompletedAt = nil
+
 	if err := newTask.Validate(); err != nil {
 		log.Error("Validation error on add:", err)
 		return nil, NewAppError(ErrTypeValidation, "Invalid task data.", err)
 	}
 
-	a.Tasks.Tasks = append(a.Tasks.Tasks, newTask)
+	before := a.snapshot([]string{newTask.ID})
+	a.appendTasks(newTask)
+	a.recordOperation(SourceAdd, before)
 	if a.Tasks.Settings.AutoSave {
-		if err := store.SaveTasks(a.Tasks); err != nil {
+		if err := a.storage.Save(a.Tasks); err != nil {
 			log.Error("Failed to save tasks on add:", err)
-			return nil, NewAppError(ErrTypeIO, "Failed to auto-save tasks.", err)
+			return nil, newSaveError("Failed to auto-save tasks.", err)
 		}
 	}
 	return &newTask, nil
@@ -136,72 +279,126 @@ func (a *App) AddTask(title, description string, priority task.Priority, tags []
 
 // GetTaskByID は指定されたIDのタスクを返します。
 func (a *App) GetTaskByID(id string) (*task.Task, error) {
-	for _, t := range a.Tasks.Tasks {
-		if t.ID == id {
-			return &t, nil
-		}
+	if i, ok := a.taskPosition(id); ok {
+		t := a.Tasks.Tasks[i]
+		return &t, nil
 	}
 	return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
 }
 
-// UpdateTask は既存のタスクを更新します。
+// UpdateTask は既存のタスクを更新します。空の値が指定されたフィールドは変更しません。
 func (a *App) UpdateTask(id, title, description string, status task.Status, priority task.Priority, tags []string) (*task.Task, error) {
-	for i, t := range a.Tasks.Tasks {
-		if t.ID == id {
-			if title != "" {
-				a.Tasks.Tasks[i].Title = title
-			}
-			if description != "" {
-				a.Tasks.Tasks[i].Description = description
-			}
-			if status != "" {
-				a.Tasks.Tasks[i].Status = status
-				if status == task.StatusDone {
-					now := time.Now()
-					a.Tasks.Tasks[i].CompletedAt = &now
-				} else {
-					a.Tasks.Tasks[i].CompletedAt = nil
-				}
-			}
-			if priority != "" {
-				a.Tasks.Tasks[i].Priority = priority
-			}
-			if tags != nil {
-				a.Tasks.Tasks[i].Tags = tags
-			}
-			a.Tasks.Tasks[i].UpdatedAt = time.Now()
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		if title != "" {
+			t.Title = title
+		}
+		if description != "" {
+			t.Description = description
+		}
+		if status != "" {
+			setStatus(t, status)
+		}
+		if priority != "" {
+			t.Priority = priority
+		}
+		if tags != nil {
+			t.Tags = tags
+		}
+	})
+}
 
-			if err := a.Tasks.Tasks[i].Validate(); err != nil {
-				log.Error("Validation error on update:", err)
-				return nil, NewAppError(ErrTypeValidation, "Invalid task data after update.", err)
-			}
+// UpdateTaskWith は指定された関数でタスクを更新します。
+// 更新後のタスクが検証に失敗した場合、タスクは変更されません。
+func (a *App) UpdateTaskWith(id string, update func(t *task.Task)) (*task.Task, error) {
+	return a.modifyTask(id, SourceUpdate, update)
+}
 
-			if a.Tasks.Settings.AutoSave {
-				if err := store.SaveTasks(a.Tasks); err != nil {
-					log.Error("Failed to save tasks on update:", err)
-					return nil, NewAppError(ErrTypeIO, "Failed to auto-save tasks.", err)
-				}
-			}
-			return &a.Tasks.Tasks[i], nil
+// modifyTask はタスクのコピーに変更を適用して検証し、成功した場合のみ反映して保存します。
+// 変更は source の操作として変更履歴と取り消し用のスタックに記録されます。
+func (a *App) modifyTask(id, source string, update func(t *task.Task)) (*task.Task, error) {
+	i, ok := a.taskPosition(id)
+	if !ok {
+		return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
+	}
+	updated := a.Tasks.Tasks[i].Clone()
+	update(&updated)
+	updated.UpdatedAt = time.Now()
+
+	if err := updated.Validate(); err != nil {
+		log.Error("Validation error on update:", err)
+		return nil, NewAppError(ErrTypeValidation, "Invalid task data after update.", err)
+	}
+
+	before := a.snapshot([]string{id})
+	a.replaceTask(i, updated)
+	a.recordOperation(source, before)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on update:", err)
+			return nil, newSaveError("Failed to auto-save tasks.", err)
 		}
 	}
-	return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
+	return &a.Tasks.Tasks[i], nil
 }
 
-// ExportTasks は現在のタスクデータを指定されたファイルパスにJSON形式でエクスポートします。
-func (a *App) ExportTasks(filePath string) error {
+// setStatus はタスクの状態を変更し、完了日時を状態に合わせて設定します。
+func setStatus(t *task.Task, status task.Status) {
+	t.Status = status
+	if status == task.StatusDone {
+		now := time.Now()
+		t.CompletedAt = &now
+	} else {
+		t.CompletedAt = nil
+	}
+}
+
+// SetDueDate はタスクの期限を設定します。nil を指定すると期限を解除します。
+func (a *App) SetDueDate(id string, due *time.Time) (*task.Task, error) {
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.DueDate = due
+	})
+}
+
+// SetDependencies はタスクが依存するタスクを設定します。
+// 存在しないタスクや自分自身への依存は指定できません。
+func (a *App) SetDependencies(id string, depends []string) (*task.Task, error) {
+	for _, dep := range depends {
+		if dep == id {
+			return nil, NewAppError(ErrTypeValidation, "A task cannot depend on itself.", nil)
+		}
+		if _, err := a.GetTaskByID(dep); err != nil {
+			return nil, err
+		}
+	}
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.Depends = depends
+	})
+}
+
+// checkTransferPath はエクスポート、インポート、復元のファイルのパスが空でなく、この App のデータディレクトリ以下にあるかを確認します。
+// ローカルのタスクファイルやワークスペースでは、既定のデータディレクトリではなくそのディレクトリを基準にします。
+// MemoryStore のようにデータディレクトリのない保存先では、すべてのパスを拒否します。
+func (a *App) checkTransferPath(filePath, action string) error {
 	if filePath == "" {
 		return NewAppError(ErrTypeValidation, "File path cannot be empty.", nil)
 	}
-
-	// パスが安全であることを確認
-	safe, err := store.IsPathSafe(filePath)
+	safe, err := store.IsPathWithin(a.storage.Dir(), filePath)
 	if err != nil {
-		log.Error("Failed to check path safety for export:", err)
-		return NewAppError(ErrTypeInternal, "Failed to validate export path.", err)
+		log.Error("Failed to check path safety for "+action+":", err)
+		return NewAppError(ErrTypeInternal, fmt.Sprintf("Failed to validate %s path.", action), err)
 	}
 	if !safe {
-		return NewAppError(ErrTypeValidation, "Export path is outside of allowed directory.", nil)
+		return NewAppError(ErrTypeValidation, fmt.Sprintf("%s path is outside of allowed directory.", strings.ToUpper(action[:1])+action[1:]), nil)
+	}
+	return nil
+}
+
+// ExportTasks は現在のタスクデータを指定されたファイルパスにJSON形式でエクスポートします。
+func (a *App) ExportTasks(filePath string) error {
+	// パスが空でなく、データディレクトリ以下にあることを確認
+	if err := a.checkTransferPath(filePath, "export"); err != nil {
+		return err
 	}
 
 	// タスクデータをJSON形式でマーシャル
@@ -223,19 +420,53 @@ func (a *App) ExportTasks(filePath string) error {
 
 // DeleteTask は指定されたIDのタスクを削除します。
 func (a *App) DeleteTask(id string) error {
-	for i, t := range a.Tasks.Tasks {
-		if t.ID == id {
-			a.Tasks.Tasks = append(a.Tasks.Tasks[:i], a.Tasks.Tasks[i+1:]...)
-			if a.Tasks.Settings.AutoSave {
-				if err := store.SaveTasks(a.Tasks); err != nil {
-					log.Error("Failed to save tasks on delete:", err)
-					return NewAppError(ErrTypeIO, "Failed to auto-save tasks after deletion.", err)
-				}
-			}
-			return nil
+	if _, ok := a.taskPosition(id); !ok {
+		return NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
+	}
+	before := a.snapshot([]string{id})
+	a.removeTasks(idSet{id: {}})
+	a.recordOperation(SourceDelete, before)
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on delete:", err)
+			return newSaveError("Failed to auto-save tasks after deletion.", err)
 		}
 	}
-	return NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
+	return nil
+}
+
+// DeleteTasks は指定された複数のタスクを一括で削除します。
+// 一括削除は1回の操作として記録されるため、1回の取り消しで全て元に戻せます。
+func (a *App) DeleteTasks(ids []string) error {
+	if len(ids) == 0 {
+		return NewAppError(ErrTypeValidation, "No tasks specified for deletion.", nil)
+	}
+
+	targets := make(idSet, len(ids))
+	for _, id := range ids {
+		if _, ok := a.taskPosition(id); !ok {
+			return NewAppError(ErrTypeNotFound, "Some of the specified tasks were not found.", nil)
+		}
+		targets[id] = struct{}{}
+	}
+
+	if len(ids) > 1 {
+		if err := a.backupBeforeDestructive("deleting tasks"); err != nil {
+			return err
+		}
+	}
+
+	before := a.snapshot(ids)
+	a.removeTasks(targets)
+	a.recordOperation(SourceDelete, before)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on bulk delete:", err)
+			return newSaveError("Failed to auto-save tasks after deletion.", err)
+		}
+	}
+	return nil
 }
 
 // GetAllTasks は全てのタスクを返します。
@@ -246,13 +477,8 @@ func (a *App) GetAllTasks() []task.Task {
 // GetTaskStats はタスクの統計情報を返します。
 func (a *App) GetTaskStats() (total, completed, incomplete int) {
 	total = len(a.Tasks.Tasks)
-	for _, t := range a.Tasks.Tasks {
-		if t.Status == task.StatusDone {
-			completed++
-		} else {
-			incomplete++
-		}
-	}
+	completed = len(a.repo().byStatus[task.StatusDone])
+	incomplete = total - completed
 	return
 }
 
@@ -267,43 +493,68 @@ func (a *App) GetFilteredTasksByStatus(statuses []task.Status) []task.Task {
 		return a.Tasks.Tasks
 	}
 
-	var filteredTasks []task.Task
+	r := a.repo()
 	statusMap := make(map[task.Status]bool)
+	var candidates []idSet
 	for _, s := range statuses {
-		statusMap[s] = true
-	}
-
-	for _, t := range a.Tasks.Tasks {
-		if statusMap[t.Status] {
-			filteredTasks = append(filteredTasks, t)
+		if !statusMap[s] {
+			statusMap[s] = true
+			candidates = append(candidates, r.byStatus[s])
 		}
 	}
-	return filteredTasks
+	return a.selectTasks(candidates, func(t *task.Task) bool {
+		return statusMap[t.Status]
+	})
 }
 
 // GetFilteredTasksByTags は指定されたタグでタスクをフィルタリングして返します。
 // 複数のタグが指定された場合、それら全てのタグを持つタスクを返します (AND検索)。
+// 親のタグ (例: "work") を指定すると、子孫のタグ (例: "work/backend") を持つタスクにも一致します。
 func (a *App) GetFilteredTasksByTags(tags []string) []task.Task {
 	if len(tags) == 0 {
 		return a.Tasks.Tasks
 	}
 
-	var filteredTasks []task.Task
-	for _, t := range a.Tasks.Tasks {
-		matchCount := 0
+	// 候補は、一致するタスクが最も少ないタグから選ぶ
+	r := a.repo()
+	var candidates []idSet
+	for i, filterTag := range tags {
+		sets := r.tagCandidates(filterTag)
+		if i == 0 || countIDs(sets) < countIDs(candidates) {
+			candidates = sets
+		}
+	}
+	return a.selectTasks(candidates, func(t *task.Task) bool {
 		for _, filterTag := range tags {
+			matched := false
 			for _, taskTag := range t.Tags {
-				if strings.EqualFold(strings.TrimSpace(filterTag), strings.TrimSpace(taskTag)) {
-					matchCount++
+				if task.TagMatches(taskTag, filterTag) {
+					matched = true
 					break
 				}
 			}
+			if !matched {
+				return false
+			}
 		}
-		if matchCount == len(tags) {
-			filteredTasks = append(filteredTasks, t)
-		}
+		return true
+	})
+}
+
+// countIDs は集合に含まれるIDの数の合計を返します。
+func countIDs(sets []idSet) int {
+	n := 0
+	for _, set := range sets {
+		n += len(set)
 	}
-	return filteredTasks
+	return n
+}
+
+// priorityOrder は優先度のソート順です。HIGH > MEDIUM > LOW の順になります。
+var priorityOrder = map[task.Priority]int{
+	task.PriorityHigh:   3,
+	task.PriorityMedium: 2,
+	task.PriorityLow:    1,
 }
 
 // SortTasks は指定された基準と順序でタスクをソートします。
@@ -312,6 +563,12 @@ func (a *App) SortTasks(tasks []task.Task, sortBy string, ascending bool) []task
 		return tasks
 	}
 
+	// 緊急度は比較のたびに計算せず、ソート前にまとめて計算する
+	var urgency map[string]float64
+	if sortBy == "urgency" {
+		urgency = a.urgencyScores()
+	}
+
 	sort.Slice(tasks, func(i, j int) bool {
 		switch sortBy {
 		case "created_at":
@@ -325,18 +582,19 @@ func (a *App) SortTasks(tasks []task.Task, sortBy string, ascending bool) []task
 			}
 			return tasks[i].UpdatedAt.After(tasks[j].UpdatedAt)
 		case "priority":
-			// 優先度はHIGH > MEDIUM > LOW の順
-			priorityOrder := map[task.Priority]int{
-				task.PriorityHigh:   3,
-				task.PriorityMedium: 2,
-				task.PriorityLow:    1,
-			}
 			p1 := priorityOrder[tasks[i].Priority]
 			p2 := priorityOrder[tasks[j].Priority]
 			if ascending {
 				return p1 < p2
 			}
 			return p1 > p2
+		case "urgency":
+			u1 := urgency[tasks[i].ID]
+			u2 := urgency[tasks[j].ID]
+			if ascending {
+				return u1 < u2
+			}
+			return u1 > u2
 		default:
 			// デフォルトは作成日時で降順
 			return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
@@ -351,32 +609,26 @@ func (a *App) GetFilteredTasksByPriority(priorities []task.Priority) []task.Task
 		return a.Tasks.Tasks
 	}
 
-	var filteredTasks []task.Task
+	r := a.repo()
 	priorityMap := make(map[task.Priority]bool)
+	var candidates []idSet
 	for _, p := range priorities {
-		priorityMap[p] = true
-	}
-
-	for _, t := range a.Tasks.Tasks {
-		if priorityMap[t.Priority] {
-			filteredTasks = append(filteredTasks, t)
+		if !priorityMap[p] {
+			priorityMap[p] = true
+			candidates = append(candidates, r.byPriority[p])
 		}
 	}
-	return filteredTasks
+	return a.selectTasks(candidates, func(t *task.Task) bool {
+		return priorityMap[t.Priority]
+	})
 }
 
 // GetAllUniqueTags は全てのタスクからユニークなタグのリストを返します。
 func (a *App) GetAllUniqueTags() []string {
-	uniqueTags := make(map[string]bool)
+	// 索引のタグは前後の空白を除いた空でないタグで、タスクに使われているものだけが残っている
 	var tags []string
-	for _, t := range a.Tasks.Tasks {
-		for _, tag := range t.Tags {
-			trimmedTag := strings.TrimSpace(tag)
-			if trimmedTag != "" && !uniqueTags[trimmedTag] {
-				uniqueTags[trimmedTag] = true
-				tags = append(tags, trimmedTag)
-			}
-		}
+	for tag := range a.repo().byTag {
+		tags = append(tags, tag)
 	}
 	sort.Strings(tags) // タグをアルファベット順にソート
 	return tags
@@ -385,18 +637,9 @@ func (a *App) GetAllUniqueTags() []string {
 // ImportTasks は指定されたファイルパスからタスクデータをJSON形式でインポートします。
 // 既存のタスクとの重複をチェックし、重複しないタスクのみを追加します。
 func (a *App) ImportTasks(filePath string) error {
-	if filePath == "" {
-		return NewAppError(ErrTypeValidation, "File path cannot be empty.", nil)
-	}
-
-	// パスが安全であることを確認
-	safe, err := store.IsPathSafe(filePath)
-	if err != nil {
-		log.Error("Failed to check path safety for import:", err)
-		return NewAppError(ErrTypeInternal, "Failed to validate import path.", err)
-	}
-	if !safe {
-		return NewAppError(ErrTypeValidation, "Import path is outside of allowed directory.", nil)
+	// パスが空でなく、データディレクトリ以下にあることを確認
+	if err := a.checkTransferPath(filePath, "import"); err != nil {
+		return err
 	}
 
 	data, err := os.ReadFile(filePath)
@@ -405,28 +648,39 @@ func (a *App) ImportTasks(filePath string) error {
 		return NewAppError(ErrTypeIO, fmt.Sprintf("Failed to read import file %s.", filePath), err)
 	}
 
-	var importedData task.Tasks
-	if err := json.Unmarshal(data, &importedData); err != nil {
+	importedData, err := store.UnmarshalTasks(data)
+	if err != nil {
 		log.Error("Failed to unmarshal import data:", err)
-		return NewAppError(ErrTypeInternal, "Failed to unmarshal imported data.", err)
-	}
-
-	// 重複チェック用のマップ
-	existingTaskIDs := make(map[string]bool)
-	for _, t := range a.Tasks.Tasks {
-		existingTaskIDs[t.ID] = true
+		return newLoadError(ErrTypeInternal, "Failed to unmarshal imported data.", err)
 	}
 
+	// 既存のタスクとの重複は索引で、インポートするファイル内の重複はマップで確認する
+	imported := make(map[string]bool, len(importedData.Tasks))
+	var newTasks []task.Task
 	for _, importedTask := range importedData.Tasks {
-		if _, exists := existingTaskIDs[importedTask.ID]; !exists {
-			// IDが重複しないタスクのみ追加
-			a.Tasks.Tasks = append(a.Tasks.Tasks, importedTask)
+		if _, exists := a.taskPosition(importedTask.ID); exists || imported[importedTask.ID] {
+			continue
 		}
+		// IDが重複しないタスクのみ追加
+		imported[importedTask.ID] = true
+		newTasks = append(newTasks, importedTask)
+	}
+	if len(newTasks) > 0 {
+		if err := a.backupBeforeDestructive("import"); err != nil {
+			return err
+		}
+		ids := make([]string, len(newTasks))
+		for i, t := range newTasks {
+			ids[i] = t.ID
+		}
+		before := a.snapshot(ids)
+		a.appendTasks(newTasks...)
+		a.recordOperation(SourceImport, before)
 	}
 
 	if a.Tasks.Settings.AutoSave {
-		if err := store.SaveTasks(a.Tasks); err != nil {
-			return NewAppError(ErrTypeIO, "Failed to auto-save tasks after import.", err)
+		if err := a.storage.Save(a.Tasks); err != nil {
+			return newSaveError("Failed to auto-save tasks after import.", err)
 		}
 	}
 
@@ -434,43 +688,77 @@ func (a *App) ImportTasks(filePath string) error {
 }
 
 // RestoreBackup は指定されたバックアップファイルからタスクデータを復元します。
+// バックアップと同じく、圧縮されたファイルや暗号化されたファイルも読み込めます。
 func (a *App) RestoreBackup(filePath string) error {
-	if filePath == "" {
-		return NewAppError(ErrTypeValidation, "File path cannot be empty.", nil)
+	// パスが空でなく、データディレクトリ以下にあることを確認
+	if err := a.checkTransferPath(filePath, "restore"); err != nil {
+		return err
 	}
 
-	// パスが安全であることを確認
-	safe, err := store.IsPathSafe(filePath)
+	backupTasks, err := store.ReadTasksFile(filePath)
 	if err != nil {
-		log.Error("Failed to check path safety for restore:", err)
-		return NewAppError(ErrTypeInternal, "Failed to validate restore path.", err)
+		log.Error("Failed to read backup file:", err)
+		return newLoadError(ErrTypeIO, fmt.Sprintf("Failed to read backup file %s.", filePath), err)
 	}
-	if !safe {
-		return NewAppError(ErrTypeValidation, "Restore path is outside of allowed directory.", nil)
+	if err := store.VerifyTasks(backupTasks); err != nil {
+		log.Error("Backup file failed verification:", err)
+		return NewAppError(ErrTypeValidation, fmt.Sprintf("Backup file %s is invalid.", filePath), err)
 	}
+	return a.restoreFrom(backupTasks, SourceRestore)
+}
 
-	data, err := os.ReadFile(filePath)
+// ListBackups は保存先にあるバックアップの一覧を新しい順に返します。
+func (a *App) ListBackups() ([]store.BackupInfo, error) {
+	backups, err := a.storage.ListBackups()
 	if err != nil {
-		log.Error("Failed to read backup file:", err)
-		return NewAppError(ErrTypeIO, fmt.Sprintf("Failed to read backup file %s.", filePath), err)
+		log.Error("Failed to list backups:", err)
+		return nil, NewAppError(ErrTypeIO, "Failed to list backups.", err)
+	}
+	return backups, nil
+}
+
+// RestoreBackupByName は ListBackups が返した名前のバックアップからタスクデータを復元します。
+func (a *App) RestoreBackupByName(name string) error {
+	backupTasks, err := a.loadVerifiedBackup(name)
+	if err != nil {
+		return err
 	}
+	return a.restoreFrom(backupTasks, SourceRestore)
+}
 
-	var backupTasks task.Tasks
-	if err := json.Unmarshal(data, &backupTasks); err != nil {
-		log.Error("Failed to unmarshal backup data:", err)
-		return NewAppError(ErrTypeInternal, "Failed to unmarshal backup data.", err)
+// restoreFrom は現在のタスクデータをバックアップの内容で置き換え、source の操作として記録します。
+// 置き換える前に現在のタスクデータのバックアップを作成します。
+func (a *App) restoreFrom(backupTasks *task.Tasks, source string) error {
+	if err := a.backupBeforeRestore(); err != nil {
+		return err
+	}
+
+	// 復元前後の全タスクを変更履歴と取り消し用のスタックに記録する
+	// 変更履歴と取り消し用のスタック自体はバックアップのものではなく現在のものを引き継ぐ
+	seen := make(map[string]bool, len(a.Tasks.Tasks)+len(backupTasks.Tasks))
+	var ids []string
+	for _, tasks := range [][]task.Task{a.Tasks.Tasks, backupTasks.Tasks} {
+		for _, t := range tasks {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				ids = append(ids, t.ID)
+			}
+		}
 	}
+	before := a.snapshot(ids)
 
 	// 現在のタスクデータをバックアップデータで上書き
 	a.Tasks.Tasks = backupTasks.Tasks
+	a.invalidateIndex()
 	a.Tasks.Version = backupTasks.Version
 	a.Tasks.CreatedAt = backupTasks.CreatedAt
 	a.Tasks.UpdatedAt = time.Now()          // 復元日時を更新日時とする
 	a.Tasks.Settings = backupTasks.Settings // 設定も復元
+	a.recordOperation(source, before)
 
 	if a.Tasks.Settings.AutoSave {
-		if err := store.SaveTasks(a.Tasks); err != nil {
-			return NewAppError(ErrTypeIO, "Failed to auto-save tasks after restore.", err)
+		if err := a.storage.Save(a.Tasks); err != nil {
+			return newSaveError("Failed to auto-save tasks after restore.", err)
 		}
 	}
 
@@ -1,9 +1,13 @@
 package app
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"go-task/internal/store"
 	"go-task/internal/task"
 )
 
@@ -43,14 +47,205 @@ func BenchmarkAddTask(b *testing.B) {
 	}
 }
 
+// largeTaskCount は大量のタスクを扱うベンチマークのタスク数です。
+const largeTaskCount = 100000
+
+// newLargeApp は largeTaskCount 件のタスクを持つ、自動保存が無効な App を作成します。
+// 優先度は均等に分布し、状態は TODO と DONE が半数ずつで PENDING は 100 件だけです。
+// "rare" タグは 10 件のタスクだけが持ちます。
+func newLargeApp(b *testing.B) *App {
+	b.Helper()
+	return newLargeAppWithStore(b, store.NewMemoryStore())
+}
+
+// newLargeAppWithStore は newLargeApp と同じタスクを s に保存し、読み込んだ App を作成します。
+func newLargeAppWithStore(b *testing.B, s store.Store) *App {
+	b.Helper()
+	b.Setenv("GO_TASK_TEST_ENV", "true")
+	tasks := store.NewTasks()
+	tasks.Settings.AutoSave = false
+	priorities := []task.Priority{task.PriorityHigh, task.PriorityMedium, task.PriorityLow}
+	now := time.Now()
+	tasks.Tasks = make([]task.Task, largeTaskCount)
+	for i := range tasks.Tasks {
+		tags := []string{fmt.Sprintf("project/%d", i%100)}
+		if i%(largeTaskCount/10) == 0 {
+			tags = append(tags, "rare")
+		}
+		status := task.StatusTODO
+		switch {
+		case i%(largeTaskCount/100) == 1:
+			status = task.StatusPending
+		case i%2 == 0:
+			status = task.StatusDone
+		}
+		tasks.Tasks[i] = task.Task{
+			ID:        fmt.Sprintf("task-%d", i),
+			Title:     fmt.Sprintf("Task %d", i),
+			Status:    status,
+			Priority:  priorities[i%len(priorities)],
+			Tags:      tags,
+			CreatedAt: now.Add(-time.Duration(i) * time.Minute),
+			UpdatedAt: now,
+		}
+	}
+	if err := s.Save(tasks); err != nil {
+		b.Fatalf("Save() failed: %v", err)
+	}
+	app, err := NewAppWithStore(s)
+	if err != nil {
+		b.Fatalf("NewAppWithStore() failed: %v", err)
+	}
+	app.repo() // 索引の作成はベンチマークの対象外
+	b.ResetTimer()
+	return app
+}
+
+func BenchmarkGetTaskByID100k(b *testing.B) {
+	app := newLargeApp(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := app.GetTaskByID(fmt.Sprintf("task-%d", i%largeTaskCount)); err != nil {
+			b.Fatalf("GetTaskByID() failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkUpdateTask100k(b *testing.B) {
+	app := newLargeApp(b)
+	statuses := []task.Status{task.StatusTODO, task.StatusInProgress, task.StatusDone}
+	for i := 0; i < b.N; i++ {
+		id := fmt.Sprintf("task-%d", (i*7919)%largeTaskCount)
+		if _, err := app.UpdateTask(id, "Updated", "", statuses[i%len(statuses)], task.PriorityHigh, []string{"updated"}); err != nil {
+			b.Fatalf("UpdateTask() failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkDeleteTask100k は末尾近くのタスクを削除します。一覧の順序を保つため、
+// 削除位置より後ろのタスクの移動 (位置に比例する) は索引を使っても残ります。
+func BenchmarkDeleteTask100k(b *testing.B) {
+	app := newLargeApp(b)
+	for i := 0; i < b.N; i++ {
+		n := len(app.Tasks.Tasks)
+		if n < largeTaskCount/2 {
+			b.StopTimer()
+			app = newLargeApp(b)
+			b.StartTimer()
+			n = len(app.Tasks.Tasks)
+		}
+		if err := app.DeleteTask(app.Tasks.Tasks[n-100].ID); err != nil {
+			b.Fatalf("DeleteTask() failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkFilterByTag100k(b *testing.B) {
+	app := newLargeApp(b)
+	for i := 0; i < b.N; i++ {
+		if got := app.GetFilteredTasksByTags([]string{"rare"}); len(got) != 10 {
+			b.Fatalf("GetFilteredTasksByTags() returned %d tasks, want 10", len(got))
+		}
+	}
+}
+
+func BenchmarkFilterByStatus100k(b *testing.B) {
+	app := newLargeApp(b)
+	for i := 0; i < b.N; i++ {
+		if got := app.GetFilteredTasksByStatus([]task.Status{task.StatusPending}); len(got) != 100 {
+			b.Fatalf("GetFilteredTasksByStatus() returned %d tasks, want 100", len(got))
+		}
+	}
+}
+
+func BenchmarkGetTaskStats100k(b *testing.B) {
+	app := newLargeApp(b)
+	for i := 0; i < b.N; i++ {
+		if total, completed, _ := app.GetTaskStats(); total != largeTaskCount || completed == 0 {
+			b.Fatalf("GetTaskStats() = %d, %d", total, completed)
+		}
+	}
+}
+
+func BenchmarkImportTasksDedupe100k(b *testing.B) {
+	tmpDir, err := os.MkdirTemp("", "go-task_bench_import_")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForBench(b, tmpDir)
+
+	// インポートするファイルはデータディレクトリ以下に置く必要があるため、FileStore を使用する
+	dataDir := filepath.Join(tmpDir, "data")
+	app := newLargeAppWithStore(b, store.NewFileStore(dataDir))
+	b.StopTimer()
+	// 全て既存のタスクと重複する 100 件のインポートファイル
+	imported := store.NewTasks()
+	imported.Tasks = append(imported.Tasks, app.Tasks.Tasks[:100]...)
+	data, err := store.MarshalTasks(imported)
+	if err != nil {
+		b.Fatalf("MarshalTasks() failed: %v", err)
+	}
+	path := filepath.Join(dataDir, "import.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		b.Fatalf("Failed to write import file: %v", err)
+	}
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := app.ImportTasks(path); err != nil {
+			b.Fatalf("ImportTasks() failed: %v", err)
+		}
+	}
+	if len(app.Tasks.Tasks) != largeTaskCount {
+		b.Fatalf("ImportTasks() added duplicates: %d tasks", len(app.Tasks.Tasks))
+	}
+}
+
+// BenchmarkSortTasksByPriority100k は比較のたびに割り当てを行わないことを確認します。ソート自体は O(n log n) です。
+func BenchmarkSortTasksByPriority100k(b *testing.B) {
+	app := newLargeApp(b)
+	tasks := make([]task.Task, len(app.Tasks.Tasks))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		copy(tasks, app.Tasks.Tasks)
+		app.SortTasks(tasks, "priority", i%2 == 0)
+	}
+}
+
 // setupTestEnv for benchmark
 func setupTestEnvForBench(b *testing.B, tempDir string) {
 	oldHome := os.Getenv("HOME")
 	oldTestEnv := os.Getenv("GO_TASK_TEST_ENV")
 	os.Setenv("HOME", tempDir)
+	// データディレクトリが HOME 以下になるよう、保存先を変更する環境変数を無効にする
+	for _, env := range []string{"GO_TASK_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME"} {
+		b.Setenv(env, "")
+	}
 	os.Setenv("GO_TASK_TEST_ENV", "true")
 	b.Cleanup(func() {
 		os.Setenv("HOME", oldHome)
 		os.Setenv("GO_TASK_TEST_ENV", oldTestEnv)
 	})
 }
+
+// benchmarkUpdateAndSave100k は 100k 件のタスクのうち1件を変更して保存する時間を計測します。
+// ジャーナル形式では、CompactAfter 回ごとのスナップショットの書き出しも含みます。
+func benchmarkUpdateAndSave100k(b *testing.B, s store.Store) {
+	app := newLargeAppWithStore(b, s)
+	for i := 0; i < b.N; i++ {
+		if _, err := app.UpdateTask(fmt.Sprintf("task-%d", i%largeTaskCount), fmt.Sprintf("Renamed %d", i), "", "", "", nil); err != nil {
+			b.Fatalf("UpdateTask() failed: %v", err)
+		}
+		if err := app.Save(); err != nil {
+			b.Fatalf("Save() failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSaveJSON100k(b *testing.B) {
+	benchmarkUpdateAndSave100k(b, store.NewFileStore(b.TempDir()))
+}
+
+func BenchmarkSaveJournal100k(b *testing.B) {
+	benchmarkUpdateAndSave100k(b, store.NewJournalStore(b.TempDir(), store.DefaultCompactAfter))
+}
@@ -2,13 +2,18 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"go-task/internal/config"
 	"go-task/internal/store"
 	"go-task/internal/task"
 )
@@ -18,6 +23,10 @@ func setupTestEnvForTest(t *testing.T, tempDir string) {
 	oldHome := os.Getenv("HOME")
 	oldTestEnv := os.Getenv("GO_TASK_TEST_ENV")
 	os.Setenv("HOME", tempDir)
+	// データディレクトリが HOME 以下になるよう、保存先を変更する環境変数を無効にする
+	for _, env := range []string{"GO_TASK_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(env, "")
+	}
 	os.Setenv("GO_TASK_TEST_ENV", "true") // テスト環境であることを示す
 	t.Cleanup(func() {
 		os.Setenv("HOME", oldHome)
@@ -696,6 +705,18 @@ func TestSortTasks(t *testing.T) {
 			ascending: false,
 			expected:  []string{"Task A", "Task B", "Task C"}, // High, Medium, Low
 		},
+		{
+			name:      "Sort by Urgency Descending",
+			sortBy:    "urgency",
+			ascending: false,
+			expected:  []string{"Task A", "Task B", "Task C"}, // High, Medium, Low
+		},
+		{
+			name:      "Sort by Urgency Ascending",
+			sortBy:    "urgency",
+			ascending: true,
+			expected:  []string{"Task C", "Task B", "Task A"},
+		},
 		{
 			name:      "Default sort (unknown sortBy, CreatedAt Descending)",
 			sortBy:    "unknown",
@@ -1013,3 +1034,1731 @@ func TestSecurity(t *testing.T) {
 		}
 	}
 }
+
+func TestGetTaskHistory(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_history_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	addedTask, _ := app.AddTask("History Task", "", task.PriorityLow, nil)
+	otherTask, _ := app.AddTask("Other Task", "", task.PriorityLow, nil)
+
+	// 作成時はセットされたフィールドが記録される
+	history := app.GetTaskHistory(addedTask.ID)
+	if len(history) == 0 {
+		t.Fatalf("GetTaskHistory() expected entries after add, got none")
+	}
+	for _, h := range history {
+		if h.Source != SourceAdd || h.OldValue != "" {
+			t.Errorf("GetTaskHistory() unexpected add entry: %+v", h)
+		}
+	}
+
+	// 変更されたフィールドのみ記録される
+	if _, err := app.UpdateTask(addedTask.ID, "", "", "", task.PriorityHigh, nil); err != nil {
+		t.Fatalf("UpdateTask() failed: %v", err)
+	}
+	history = app.GetTaskHistory(addedTask.ID)
+	last := history[len(history)-1]
+	if last.Source != SourceUpdate || last.Field != "priority" || last.OldValue != "LOW" || last.NewValue != "HIGH" {
+		t.Errorf("GetTaskHistory() unexpected update entry: %+v", last)
+	}
+	if last.Timestamp.IsZero() {
+		t.Errorf("GetTaskHistory() timestamp was not recorded")
+	}
+
+	// 削除後も履歴は参照できる
+	if err := app.DeleteTask(addedTask.ID); err != nil {
+		t.Fatalf("DeleteTask() failed: %v", err)
+	}
+	history = app.GetTaskHistory(addedTask.ID)
+	if last := history[len(history)-1]; last.Source != SourceDelete || last.NewValue != "" {
+		t.Errorf("GetTaskHistory() unexpected delete entry: %+v", last)
+	}
+
+	// 他のタスクの履歴は混ざらない
+	for _, h := range app.GetTaskHistory(otherTask.ID) {
+		if h.TaskID != otherTask.ID {
+			t.Errorf("GetTaskHistory() returned entry for another task: %+v", h)
+		}
+	}
+
+	// 保存後に読み込み直しても履歴が保持される
+	reloaded, err := store.LoadTasks()
+	if err != nil {
+		t.Fatalf("LoadTasks() failed: %v", err)
+	}
+	if len(reloaded.History) != len(app.Tasks.History) {
+		t.Errorf("History was not persisted: got %d entries, want %d", len(reloaded.History), len(app.Tasks.History))
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_undo_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	// 取り消す操作がない場合はエラー
+	if _, err := app.Undo(); err == nil {
+		t.Errorf("Undo() expected error with empty stack, got nil")
+	}
+
+	task1, _ := app.AddTask("Task 1", "", task.PriorityLow, nil)
+	task2, _ := app.AddTask("Task 2", "", task.PriorityLow, nil)
+	task3, _ := app.AddTask("Task 3", "", task.PriorityLow, nil)
+
+	// 状態変更の取り消しとやり直し
+	app.UpdateTask(task2.ID, "", "", task.StatusDone, "", nil)
+	if _, err := app.Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	restored, _ := app.GetTaskByID(task2.ID)
+	if restored.Status != task.StatusTODO || restored.CompletedAt != nil {
+		t.Errorf("Undo() did not restore status: %+v", restored)
+	}
+	if _, err := app.Redo(); err != nil {
+		t.Fatalf("Redo() failed: %v", err)
+	}
+	redone, _ := app.GetTaskByID(task2.ID)
+	if redone.Status != task.StatusDone {
+		t.Errorf("Redo() did not reapply status: %+v", redone)
+	}
+
+	// 一括削除は1回の取り消しで元の順序に戻る
+	if err := app.DeleteTasks([]string{task1.ID, task3.ID}); err != nil {
+		t.Fatalf("DeleteTasks() failed: %v", err)
+	}
+	if len(app.Tasks.Tasks) != 1 {
+		t.Fatalf("DeleteTasks() expected 1 remaining task, got %d", len(app.Tasks.Tasks))
+	}
+	if _, err := app.Undo(); err != nil {
+		t.Fatalf("Undo() failed for bulk delete: %v", err)
+	}
+	wantOrder := []string{task1.ID, task2.ID, task3.ID}
+	if len(app.Tasks.Tasks) != len(wantOrder) {
+		t.Fatalf("Undo() expected %d tasks after bulk delete undo, got %d", len(wantOrder), len(app.Tasks.Tasks))
+	}
+	for i, id := range wantOrder {
+		if app.Tasks.Tasks[i].ID != id {
+			t.Errorf("Undo() task at %d got %s, want %s", i, app.Tasks.Tasks[i].ID, id)
+		}
+	}
+
+	// 追加の取り消し
+	if _, err := app.Undo(); err != nil { // 状態変更
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	if _, err := app.Undo(); err != nil { // Task 3 の追加
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	if _, err := app.GetTaskByID(task3.ID); err == nil {
+		t.Errorf("Undo() of add did not remove the task")
+	}
+
+	// 新しい操作を行うとやり直しスタックはクリアされる
+	app.AddTask("Task 4", "", task.PriorityLow, nil)
+	if app.CanRedo() {
+		t.Errorf("CanRedo() expected false after a new operation")
+	}
+
+	// スタックは保存され、次回起動時にも利用できる
+	reopened, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if len(reopened.Tasks.UndoStack) != len(app.Tasks.UndoStack) {
+		t.Errorf("Undo stack was not persisted: got %d, want %d", len(reopened.Tasks.UndoStack), len(app.Tasks.UndoStack))
+	}
+	if _, err := reopened.Undo(); err != nil {
+		t.Errorf("Undo() failed after reopening: %v", err)
+	}
+
+	// 最大件数を超えた操作は古いものから破棄される
+	for i := 0; i < maxUndoOperations+5; i++ {
+		app.UpdateTask(task2.ID, "", "", "", task.PriorityHigh, nil)
+	}
+	if len(app.Tasks.UndoStack) != maxUndoOperations {
+		t.Errorf("Undo stack got %d operations, want %d", len(app.Tasks.UndoStack), maxUndoOperations)
+	}
+}
+
+func TestTaskUrgencyWithDependencies(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_urgency_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	blocker, _ := app.AddTask("Blocker", "", task.PriorityLow, nil)
+	blocked, _ := app.AddTask("Blocked", "", task.PriorityLow, nil)
+	plain, _ := app.AddTask("Plain", "", task.PriorityLow, nil)
+
+	if _, err := app.SetDependencies(blocked.ID, []string{blocker.ID}); err != nil {
+		t.Fatalf("SetDependencies() failed: %v", err)
+	}
+	if _, err := app.SetDependencies(blocked.ID, []string{blocked.ID}); err == nil {
+		t.Errorf("SetDependencies() expected error for self dependency, got nil")
+	}
+	if _, err := app.SetDependencies(blocked.ID, []string{"non-existent-id"}); err == nil {
+		t.Errorf("SetDependencies() expected error for unknown dependency, got nil")
+	}
+
+	blockerScore, _ := app.GetTaskUrgency(blocker.ID)
+	blockedScore, _ := app.GetTaskUrgency(blocked.ID)
+	plainScore, _ := app.GetTaskUrgency(plain.ID)
+	if !(blockerScore > plainScore && plainScore > blockedScore) {
+		t.Errorf("Unexpected urgency order: blocker=%.2f plain=%.2f blocked=%.2f", blockerScore, plainScore, blockedScore)
+	}
+
+	// 依存先が完了すると、ブロックは解除される
+	app.UpdateTask(blocker.ID, "", "", task.StatusDone, "", nil)
+	blockedScore, _ = app.GetTaskUrgency(blocked.ID)
+	if diff := blockedScore - plainScore; diff < -0.01 || diff > 0.01 {
+		t.Errorf("Expected blocked task to be unblocked: got %.2f, want %.2f", blockedScore, plainScore)
+	}
+
+	// 係数は差し替えられる
+	app.UrgencyCoefficients.PriorityLow = 100
+	plainScore, _ = app.GetTaskUrgency(plain.ID)
+	if plainScore < 100 {
+		t.Errorf("Expected custom coefficient to be applied, got %.2f", plainScore)
+	}
+
+	if _, err := app.GetTaskUrgency("non-existent-id"); err == nil {
+		t.Errorf("GetTaskUrgency() expected error for non-existent ID, got nil")
+	}
+}
+
+func TestSnoozeTask(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_snooze_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	snoozed, _ := app.AddTask("Snoozed", "", task.PriorityLow, nil)
+	expired, _ := app.AddTask("Expired", "", task.PriorityLow, nil)
+	app.AddTask("Visible", "", task.PriorityLow, nil)
+
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	if _, err := app.SnoozeTask(snoozed.ID, &future); err != nil {
+		t.Fatalf("SnoozeTask() failed: %v", err)
+	}
+	app.SnoozeTask(expired.ID, &past)
+
+	// 待機中のタスクは一覧から除かれ、待機期間を過ぎたタスクは再び表示される
+	visible := app.GetVisibleTasks()
+	if len(visible) != 2 {
+		t.Errorf("GetVisibleTasks() got %d tasks, want 2", len(visible))
+	}
+	for _, v := range visible {
+		if v.ID == snoozed.ID {
+			t.Errorf("GetVisibleTasks() returned a waiting task")
+		}
+	}
+	waiting := app.GetWaitingTasks()
+	if len(waiting) != 1 || waiting[0].ID != snoozed.ID {
+		t.Errorf("GetWaitingTasks() got %v, want only the snoozed task", waiting)
+	}
+	if len(app.GetAllTasks()) != 3 {
+		t.Errorf("GetAllTasks() should still return waiting tasks")
+	}
+
+	// 待機の解除
+	app.SnoozeTask(snoozed.ID, nil)
+	if len(app.GetWaitingTasks()) != 0 {
+		t.Errorf("SnoozeTask(nil) did not wake up the task")
+	}
+}
+
+func TestSnoozeUntil(t *testing.T) {
+	// 2025-01-01 は水曜日
+	now := time.Date(2025, 1, 1, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		preset  string
+		want    time.Time
+		wantErr bool
+	}{
+		{SnoozeOneHour, now.Add(time.Hour), false},
+		{SnoozeTomorrow, time.Date(2025, 1, 2, 9, 0, 0, 0, time.Local), false},
+		{SnoozeNextWeek, time.Date(2025, 1, 6, 9, 0, 0, 0, time.Local), false},
+		{"2025-02-03", time.Date(2025, 2, 3, 0, 0, 0, 0, time.Local), false},
+		{"someday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			got, err := SnoozeUntil(tt.preset, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SnoozeUntil() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("SnoozeUntil() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 月曜日に「来週」を指定した場合は翌週の月曜日
+	monday := time.Date(2025, 1, 6, 10, 0, 0, 0, time.Local)
+	got, _ := SnoozeUntil(SnoozeNextWeek, monday)
+	if want := time.Date(2025, 1, 13, 9, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("SnoozeUntil() on Monday got %v, want %v", got, want)
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_checklist_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	tk, _ := app.AddTask("Release", "", task.PriorityHigh, nil)
+	for _, text := range []string{"Build", "Test", "Deploy"} {
+		if _, err := app.AddChecklistItem(tk.ID, text); err != nil {
+			t.Fatalf("AddChecklistItem(%q) failed: %v", text, err)
+		}
+	}
+	if _, err := app.AddChecklistItem(tk.ID, ""); err == nil {
+		t.Errorf("AddChecklistItem() with empty text should fail")
+	}
+
+	updated, err := app.ToggleChecklistItem(tk.ID, 1)
+	if err != nil {
+		t.Fatalf("ToggleChecklistItem() failed: %v", err)
+	}
+	if done, total := updated.ChecklistProgress(); done != 1 || total != 3 {
+		t.Errorf("ChecklistProgress() got %d/%d, want 1/3", done, total)
+	}
+
+	// 先頭より上への移動は先頭に留まる
+	updated, _ = app.MoveChecklistItem(tk.ID, 2, -5)
+	got := updated.Checklist[0].Text + "," + updated.Checklist[1].Text + "," + updated.Checklist[2].Text
+	if want := "Deploy,Build,Test"; got != want {
+		t.Errorf("MoveChecklistItem() got %s, want %s", got, want)
+	}
+	if !updated.Checklist[2].Done {
+		t.Errorf("MoveChecklistItem() should keep the done state of moved items")
+	}
+
+	updated, _ = app.RemoveChecklistItem(tk.ID, 0)
+	if len(updated.Checklist) != 2 || updated.Checklist[0].Text != "Build" {
+		t.Errorf("RemoveChecklistItem() got %v", updated.Checklist)
+	}
+	if _, err := app.ToggleChecklistItem(tk.ID, 5); err == nil {
+		t.Errorf("ToggleChecklistItem() with out of range index should fail")
+	}
+
+	// チェックリストの変更は履歴に記録され、取り消しできる
+	if _, err := app.Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	restored, _ := app.GetTaskByID(tk.ID)
+	if len(restored.Checklist) != 3 {
+		t.Errorf("Undo() got %d checklist items, want 3", len(restored.Checklist))
+	}
+	if len(app.GetTaskHistory(tk.ID)) == 0 {
+		t.Errorf("Checklist changes should be recorded in history")
+	}
+
+	if results := app.Search("deploy"); len(results) != 1 {
+		t.Errorf("SearchTasks() should match checklist items, got %d results", len(results))
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_template_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	tpl := task.Template{
+		Name:      "onboarding",
+		Title:     "Onboard {{.name}}",
+		Tags:      []string{"hr"},
+		Checklist: []string{"Create account for {{.name}}", "Order laptop"},
+	}
+	if err := app.SaveTemplate(tpl); err != nil {
+		t.Fatalf("SaveTemplate() failed: %v", err)
+	}
+	if err := app.SaveTemplate(task.Template{Name: "empty"}); err == nil {
+		t.Errorf("SaveTemplate() without title should fail")
+	}
+
+	created, err := app.AddTaskFromTemplate("onboarding", map[string]string{"name": "Alice"})
+	if err != nil {
+		t.Fatalf("AddTaskFromTemplate() failed: %v", err)
+	}
+	if created.Title != "Onboard Alice" || len(created.Checklist) != 2 || created.Priority != app.Tasks.Settings.DefaultPriority {
+		t.Errorf("AddTaskFromTemplate() got %+v", created)
+	}
+	if _, err := app.AddTaskFromTemplate("onboarding", nil); err == nil {
+		t.Errorf("AddTaskFromTemplate() with missing variables should fail")
+	}
+	if _, err := app.AddTaskFromTemplate("unknown", nil); err == nil {
+		t.Errorf("AddTaskFromTemplate() with unknown template should fail")
+	}
+
+	// 既存のタスクから保存すると同じ名前のテンプレートを置き換える
+	if _, err := app.SaveTaskAsTemplate(created.ID, "onboarding"); err != nil {
+		t.Fatalf("SaveTaskAsTemplate() failed: %v", err)
+	}
+	templates, _ := app.GetTemplates()
+	if len(templates) != 1 || templates[0].Title != "Onboard Alice" {
+		t.Errorf("GetTemplates() got %+v", templates)
+	}
+
+	if err := app.DeleteTemplate("onboarding"); err != nil {
+		t.Fatalf("DeleteTemplate() failed: %v", err)
+	}
+	if err := app.DeleteTemplate("onboarding"); err == nil {
+		t.Errorf("DeleteTemplate() of a missing template should fail")
+	}
+}
+
+func TestTagOperations(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_tags_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	api, _ := app.AddTask("API", "", task.PriorityHigh, []string{"work/backend", "urgent"})
+	ui, _ := app.AddTask("UI", "", task.PriorityLow, []string{"work/frontend"})
+	home, _ := app.AddTask("Home", "", task.PriorityLow, []string{"home", "todo"})
+
+	// 親タグでのフィルタリングは子孫のタグにも一致する
+	if got := app.GetFilteredTasksByTags([]string{"work"}); len(got) != 2 {
+		t.Errorf("GetFilteredTasksByTags(work) got %d tasks, want 2", len(got))
+	}
+	if got := app.GetFilteredTasksByTags([]string{"work/backend"}); len(got) != 1 || got[0].ID != api.ID {
+		t.Errorf("GetFilteredTasksByTags(work/backend) got %v", got)
+	}
+
+	undoCount := len(app.Tasks.UndoStack)
+	n, err := app.RenameTag("work", "job")
+	if err != nil || n != 2 {
+		t.Fatalf("RenameTag() got %d, %v, want 2 tasks", n, err)
+	}
+	if len(app.Tasks.UndoStack) != undoCount+1 {
+		t.Errorf("RenameTag() should be recorded as a single operation")
+	}
+	renamed, _ := app.GetTaskByID(ui.ID)
+	if renamed.Tags[0] != "job/frontend" {
+		t.Errorf("RenameTag() got tags %v, want job/frontend", renamed.Tags)
+	}
+
+	// 統合によって重複したタグは1つになる
+	if n, err := app.MergeTags([]string{"todo", "urgent"}, "home"); err != nil || n != 2 {
+		t.Fatalf("MergeTags() got %d, %v, want 2 tasks", n, err)
+	}
+	merged, _ := app.GetTaskByID(home.ID)
+	if len(merged.Tags) != 1 || merged.Tags[0] != "home" {
+		t.Errorf("MergeTags() got tags %v, want [home]", merged.Tags)
+	}
+
+	if n, err := app.DeleteTag("job"); err != nil || n != 2 {
+		t.Fatalf("DeleteTag() got %d, %v, want 2 tasks", n, err)
+	}
+	if got := app.GetAllUniqueTags(); len(got) != 1 || got[0] != "home" {
+		t.Errorf("GetAllUniqueTags() after delete got %v, want [home]", got)
+	}
+	if n, _ := app.DeleteTag("missing"); n != 0 {
+		t.Errorf("DeleteTag() of an unused tag changed %d tasks", n)
+	}
+	if _, err := app.RenameTag("home", ""); err == nil {
+		t.Errorf("RenameTag() to an empty name should fail")
+	}
+
+	// 書き換えたタスクは検証され、失敗した場合は何も変更しない
+	undoCount = len(app.Tasks.UndoStack)
+	i, _ := app.taskPosition(home.ID)
+	app.Tasks.Tasks[i].Status = "BROKEN"
+	if _, err := app.RenameTag("home", "house"); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("RenameTag() of an invalid task error = %v, want Validation", err)
+	}
+	if got, _ := app.GetTaskByID(home.ID); got.Tags[0] != "home" || len(app.Tasks.UndoStack) != undoCount {
+		t.Errorf("a failed RenameTag() changed tags to %v", got.Tags)
+	}
+}
+
+func TestTagRegistry(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_tagregistry_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	if err := app.RegisterTag("work", task.TagInfo{Color: "12", Description: "Day job"}); err != nil {
+		t.Fatalf("RegisterTag() failed: %v", err)
+	}
+	if err := app.RegisterTag("bad", task.TagInfo{Color: "purple"}); err == nil {
+		t.Errorf("RegisterTag() with an invalid color should fail")
+	}
+
+	// 厳格モードが無効な場合は警告しない
+	if unknown := app.UnregisteredTags([]string{"home"}); unknown != nil {
+		t.Errorf("UnregisteredTags() without strict mode got %v", unknown)
+	}
+	app.SetStrictTags(true)
+	unknown := app.UnregisteredTags([]string{"work/backend", "home"})
+	if len(unknown) != 1 || unknown[0] != "home" {
+		t.Errorf("UnregisteredTags() got %v, want [home]", unknown)
+	}
+
+	// レジストリは保存され、再読み込み後も残る
+	reloaded, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if info, ok := reloaded.GetTagInfo("work"); !ok || info.Description != "Day job" || !reloaded.Tasks.Settings.StrictTags {
+		t.Errorf("Tag registry was not persisted, got %+v, %v", info, ok)
+	}
+
+	// タグの名前を変更すると登録も移動する
+	app.AddTask("Deploy", "", task.PriorityHigh, []string{"work"})
+	if _, err := app.RenameTag("work", "job"); err != nil {
+		t.Fatalf("RenameTag() failed: %v", err)
+	}
+	if _, ok := app.Tasks.TagRegistry["job"]; !ok {
+		t.Errorf("RenameTag() did not move the registry entry, got %v", app.Tasks.TagRegistry)
+	}
+
+	// 取り消すと登録も元の名前に戻り、やり直すと再び移動する。記録した登録は保存され、再読み込み後も使える
+	reloaded, err = NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if _, err := reloaded.Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	if _, ok := reloaded.Tasks.TagRegistry["job"]; ok || reloaded.Tasks.TagRegistry["work"].Description != "Day job" {
+		t.Errorf("Undo() of RenameTag() should restore the registry, got %v", reloaded.Tasks.TagRegistry)
+	}
+	if _, err := reloaded.Redo(); err != nil {
+		t.Fatalf("Redo() failed: %v", err)
+	}
+	if _, ok := reloaded.Tasks.TagRegistry["work"]; ok || reloaded.Tasks.TagRegistry["job"].Description != "Day job" {
+		t.Errorf("Redo() of RenameTag() should move the registry entry again, got %v", reloaded.Tasks.TagRegistry)
+	}
+
+	// 登録だけを変更した場合も取り消せる
+	if n, err := reloaded.RenameTag("job", "career"); err != nil || n != 1 {
+		t.Fatalf("RenameTag() got %d, %v", n, err)
+	}
+	if err := reloaded.RegisterTag("unused", task.TagInfo{Color: "3"}); err != nil {
+		t.Fatalf("RegisterTag() failed: %v", err)
+	}
+	if n, err := reloaded.RenameTag("unused", "spare"); err != nil || n != 0 {
+		t.Fatalf("RenameTag() of a registered but unused tag got %d, %v", n, err)
+	}
+	if _, err := reloaded.Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	if _, ok := reloaded.Tasks.TagRegistry["unused"]; !ok {
+		t.Errorf("Undo() of a registry-only rename should restore the entry, got %v", reloaded.Tasks.TagRegistry)
+	}
+	app = reloaded
+	if _, err := app.Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	if err := app.UnregisterTag("job"); err != nil {
+		t.Fatalf("UnregisterTag() failed: %v", err)
+	}
+	if err := app.UnregisterTag("job"); err == nil {
+		t.Errorf("UnregisterTag() of an unregistered tag should fail")
+	}
+}
+
+func TestMergeTagsRegistryIsDeterministic(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	// マップの走査順に左右されないことを確かめるため、同じ統合を繰り返す
+	for i := 0; i < 20; i++ {
+		app, err := NewAppWithStore(store.NewMemoryStore())
+		if err != nil {
+			t.Fatalf("NewAppWithStore() error = %v", err)
+		}
+		app.Tasks.TagRegistry = map[string]task.TagInfo{
+			"beta":       {Color: "2"},
+			"alpha":      {Color: "1"},
+			"beta/x":     {Color: "4"},
+			"alpha/x":    {Color: "3"},
+			"target":     {Color: "9"},
+			"gamma":      {Color: "5"},
+			"target/sub": {Color: "8"},
+		}
+		if _, err := app.MergeTags([]string{"beta", "alpha"}, "merged"); err != nil {
+			t.Fatalf("MergeTags() error = %v", err)
+		}
+		if got := app.Tasks.TagRegistry["merged"].Color; got != "1" {
+			t.Fatalf("MergeTags() kept color %q for merged, want the first name in order (alpha)", got)
+		}
+		if got := app.Tasks.TagRegistry["merged/x"].Color; got != "3" {
+			t.Fatalf("MergeTags() kept color %q for merged/x, want alpha/x", got)
+		}
+
+		// 統合先が登録済みの場合は、統合先自身が統合元に含まれていても統合先の登録を優先する
+		if _, err := app.MergeTags([]string{"gamma", "target"}, "target"); err != nil {
+			t.Fatalf("MergeTags() error = %v", err)
+		}
+		if got := app.Tasks.TagRegistry["target"].Color; got != "9" {
+			t.Fatalf("MergeTags() into a registered tag kept color %q, want its own", got)
+		}
+		if _, ok := app.Tasks.TagRegistry["gamma"]; ok || app.Tasks.TagRegistry["target/sub"].Color != "8" {
+			t.Fatalf("MergeTags() left registry %v", app.Tasks.TagRegistry)
+		}
+	}
+}
+
+func TestNewAppWithStore(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	s := store.NewMemoryStore()
+	app, err := NewAppWithStore(s)
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+
+	added, err := app.AddTask("In memory", "", task.PriorityLow, nil)
+	if err != nil {
+		t.Fatalf("AddTask() failed: %v", err)
+	}
+	if err := s.CreateBackup(app.Tasks); err != nil {
+		t.Fatalf("CreateBackup() failed: %v", err)
+	}
+
+	// 自動保存は注入された保存先に書き込まれる
+	reopened, err := NewAppWithStore(s)
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	if _, err := reopened.GetTaskByID(added.ID); err != nil {
+		t.Errorf("Task was not saved to the injected store: %v", err)
+	}
+
+	// バックアップの一覧と名前を指定した復元
+	app.DeleteTask(added.ID)
+	backups, err := app.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups() got %v, %v", backups, err)
+	}
+	if err := app.RestoreBackupByName(backups[0].Name); err != nil {
+		t.Fatalf("RestoreBackupByName() failed: %v", err)
+	}
+	if _, err := app.GetTaskByID(added.ID); err != nil {
+		t.Errorf("RestoreBackupByName() did not restore the task: %v", err)
+	}
+	if err := app.RestoreBackupByName("missing"); err == nil {
+		t.Errorf("RestoreBackupByName() of a missing backup should fail")
+	}
+}
+
+func TestConcurrentInstancesConflict(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_concurrent_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+
+	// 2つの端末で go-task を起動した状態
+	first, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	second, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+
+	if _, err := first.AddTask("From first", "", task.PriorityLow, nil); err != nil {
+		t.Fatalf("AddTask() failed: %v", err)
+	}
+	_, err = second.AddTask("From second", "", task.PriorityLow, nil)
+	if appErr, ok := err.(*AppError); !ok || appErr.Type != ErrTypeConflict {
+		t.Fatalf("AddTask() on a stale instance error = %v, want a conflict", err)
+	}
+
+	// 先に保存された変更は失われない
+	reloaded, _ := NewAppWithStore(store.NewFileStore(tmpDir))
+	if tasks := reloaded.GetAllTasks(); len(tasks) != 1 || tasks[0].Title != "From first" {
+		t.Errorf("Expected only the first instance's task on disk, got %v", tasks)
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_workspaces_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	if _, err := SwitchWorkspace("missing"); err == nil || err.(*AppError).Type != ErrTypeNotFound {
+		t.Errorf("SwitchWorkspace() of a missing workspace error = %v, want NotFound", err)
+	}
+	if err := CreateWorkspace("bad name"); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("CreateWorkspace() with an invalid name error = %v, want Validation", err)
+	}
+	if err := CreateWorkspace("work"); err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+
+	// 切り替えたワークスペースは次回の NewApp でも選択される
+	work, err := SwitchWorkspace("work")
+	if err != nil {
+		t.Fatalf("SwitchWorkspace() error = %v", err)
+	}
+	if _, err := work.AddTask("Work task", "", task.PriorityHigh, nil); err != nil {
+		t.Fatalf("AddTask() error = %v", err)
+	}
+	reopened, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if reopened.Workspace() != "work" || len(reopened.Tasks.Tasks) != 1 {
+		t.Errorf("NewApp() opened %q with %d tasks, want work with 1 task", reopened.Workspace(), len(reopened.Tasks.Tasks))
+	}
+
+	// 選択中のワークスペースの名前を変更すると選択も追従する
+	if err := RenameWorkspace("work", "job"); err != nil {
+		t.Fatalf("RenameWorkspace() error = %v", err)
+	}
+	if current, _ := CurrentWorkspace(); current != "job" {
+		t.Errorf("CurrentWorkspace() after rename = %q, want job", current)
+	}
+
+	// 選択中のワークスペースを削除すると既定のワークスペースに戻る
+	if err := DeleteWorkspace("job"); err != nil {
+		t.Fatalf("DeleteWorkspace() error = %v", err)
+	}
+	if current, _ := CurrentWorkspace(); current != store.DefaultWorkspace {
+		t.Errorf("CurrentWorkspace() after delete = %q, want %q", current, store.DefaultWorkspace)
+	}
+	def, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if def.Workspace() != store.DefaultWorkspace || len(def.Tasks.Tasks) != 0 {
+		t.Errorf("NewApp() after delete opened %q with %d tasks", def.Workspace(), len(def.Tasks.Tasks))
+	}
+}
+
+func TestLocalTaskFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_local_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	global, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	global.AddTask("Global task", "", task.PriorityLow, nil)
+
+	repo := filepath.Join(tmpDir, "repo")
+	os.MkdirAll(filepath.Join(repo, "sub"), 0700)
+	localDir, err := InitLocal(repo)
+	if err != nil {
+		t.Fatalf("InitLocal() error = %v", err)
+	}
+
+	// サブディレクトリから起動してもリポジトリのタスクファイルが使われる
+	wd, _ := os.Getwd()
+	if err := os.Chdir(filepath.Join(repo, "sub")); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	defer os.Chdir(wd)
+	local, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if local.LocalDir() != localDir || len(local.Tasks.Tasks) != 0 {
+		t.Fatalf("NewApp() in a repository opened %q with %d tasks", local.LocalDir(), len(local.Tasks.Tasks))
+	}
+	local.AddTask("Local task", "", task.PriorityHigh, nil)
+
+	global, err = NewGlobalApp()
+	if err != nil {
+		t.Fatalf("NewGlobalApp() error = %v", err)
+	}
+	if global.LocalDir() != "" || len(global.Tasks.Tasks) != 1 || global.Tasks.Tasks[0].Title != "Global task" {
+		t.Errorf("NewGlobalApp() should not see local tasks, got %+v", global.Tasks.Tasks)
+	}
+}
+
+func TestEncryptionPassphraseErrors(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_encryption_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+	defer store.SetPassphrase("")
+
+	a, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	a.AddTask("Secret task", "", task.PriorityHigh, nil)
+	if err := a.SetEncryption("pass"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+	if encrypted, _ := a.Encrypted(); !encrypted {
+		t.Errorf("Encrypted() = false after SetEncryption()")
+	}
+	// 暗号化後も同じ App で保存を続けられる
+	if _, err := a.AddTask("Another", "", task.PriorityLow, nil); err != nil {
+		t.Errorf("AddTask() after enabling encryption error = %v", err)
+	}
+
+	for _, passphrase := range []string{"", "wrong"} {
+		store.SetPassphrase(passphrase)
+		if _, err := NewApp(); err == nil || err.(*AppError).Type != ErrTypePassphrase {
+			t.Errorf("NewApp() with passphrase %q error = %v, want Passphrase", passphrase, err)
+		}
+	}
+	store.SetPassphrase("pass")
+	reopened, err := NewApp()
+	if err != nil || len(reopened.Tasks.Tasks) != 2 {
+		t.Errorf("NewApp() with the right passphrase = %v", err)
+	}
+
+	memory, _ := NewAppWithStore(store.NewMemoryStore())
+	if err := memory.SetEncryption("x"); err == nil {
+		t.Errorf("SetEncryption() on a memory store should fail")
+	}
+}
+
+func TestBackupBeforeDestructiveOperations(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	s := store.NewMemoryStore()
+	settings := config.DefaultBackupSettings()
+	app, err := newApp(s, settings)
+	if err != nil {
+		t.Fatalf("newApp() error = %v", err)
+	}
+	a, _ := app.AddTask("First", "", task.PriorityLow, nil)
+	b, _ := app.AddTask("Second", "", task.PriorityLow, nil)
+	countBackups := func() int {
+		backups, err := s.ListBackups()
+		if err != nil {
+			t.Fatalf("ListBackups() error = %v", err)
+		}
+		return len(backups)
+	}
+
+	// 1件の削除ではバックアップを作成しない
+	c, _ := app.AddTask("Third", "", task.PriorityLow, nil)
+	app.DeleteTask(c.ID)
+	if n := countBackups(); n != 0 {
+		t.Errorf("DeleteTask() created %d backups, want 0", n)
+	}
+
+	// 一括削除の前にバックアップを作成する
+	if err := app.DeleteTasks([]string{a.ID, b.ID}); err != nil {
+		t.Fatalf("DeleteTasks() error = %v", err)
+	}
+	if n := countBackups(); n != 1 {
+		t.Fatalf("DeleteTasks() created %d backups, want 1", n)
+	}
+	backups, _ := s.ListBackups()
+	if backup, _ := s.LoadBackup(backups[0].Name); len(backup.Tasks) != 2 {
+		t.Errorf("backup before DeleteTasks() has %d tasks, want 2", len(backup.Tasks))
+	}
+
+	// 復元の前にもバックアップを作成する
+	if err := app.RestoreBackupByName(backups[0].Name); err != nil {
+		t.Fatalf("RestoreBackupByName() error = %v", err)
+	}
+	if n := countBackups(); n != 2 {
+		t.Errorf("RestoreBackupByName() should create a backup first, got %d backups", n)
+	}
+
+	// 終了時のバックアップ
+	if err := app.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if n := countBackups(); n != 3 {
+		t.Errorf("Close() should create a backup, got %d backups", n)
+	}
+
+	// 無効にした場合は作成しない
+	settings.BeforeDestructive = false
+	settings.OnExit = false
+	app.backup = settings
+	if err := app.DeleteTasks([]string{a.ID, b.ID}); err != nil {
+		t.Fatalf("DeleteTasks() error = %v", err)
+	}
+	app.Close()
+	if n := countBackups(); n != 3 {
+		t.Errorf("disabled backups still created backups, got %d", n)
+	}
+}
+
+func TestCloseStopsPeriodicBackups(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	s := store.NewMemoryStore()
+	settings := config.DefaultBackupSettings()
+	settings.Interval = "10ms"
+	settings.OnExit = false
+	app, err := newApp(s, settings)
+	if err != nil {
+		t.Fatalf("newApp() error = %v", err)
+	}
+	if app.backupsDone == nil {
+		t.Fatalf("newApp() with auto-save enabled should start periodic backups")
+	}
+	if err := app.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-app.backupsDone:
+	default:
+		t.Fatalf("Close() returned before the periodic backups stopped")
+	}
+
+	// 終了した後はバックアップが増えない。2回目の Close も安全に呼び出せる
+	backups, _ := s.ListBackups()
+	time.Sleep(50 * time.Millisecond)
+	if after, _ := s.ListBackups(); len(after) != len(backups) {
+		t.Errorf("periodic backups continued after Close(): %d -> %d", len(backups), len(after))
+	}
+	if err := app.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestRestoreBackupFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_restore_file_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	defer store.SetPassphrase("")
+
+	s := store.NewFileStore(tmpDir)
+	a, err := NewAppWithStore(s)
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	a.AddTask("Backed up", "", task.PriorityLow, nil)
+	// 既定の設定ではバックアップは gzip で圧縮される
+	if err := s.CreateBackup(a.Tasks); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	backups, _ := s.ListBackups()
+	path := filepath.Join(s.BackupDirPath(), backups[0].Name)
+	if !strings.HasSuffix(path, ".gz") {
+		t.Fatalf("backup %s is not compressed", path)
+	}
+
+	for _, name := range []string{"compressed", "encrypted"} {
+		if name == "encrypted" {
+			if err := a.SetEncryption("pass"); err != nil {
+				t.Fatalf("SetEncryption() error = %v", err)
+			}
+		}
+		a.AddTask("Added after the backup", "", task.PriorityLow, nil)
+		if err := a.RestoreBackup(path); err != nil {
+			t.Fatalf("RestoreBackup() of the %s backup error = %v", name, err)
+		}
+		if len(a.Tasks.Tasks) != 1 || a.Tasks.Tasks[0].Title != "Backed up" {
+			t.Errorf("tasks after restoring the %s backup = %+v", name, a.Tasks.Tasks)
+		}
+	}
+}
+
+func TestBackupInspectDiffAndRestoreTask(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	s := store.NewMemoryStore()
+	app, err := NewAppWithStore(s)
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	kept, _ := app.AddTask("Kept", "", task.PriorityLow, nil)
+	deleted, _ := app.AddTask("Deleted", "", task.PriorityLow, nil)
+	if err := s.CreateBackup(app.Tasks); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	backups, _ := s.ListBackups()
+	name := backups[0].Name
+
+	app.UpdateTask(kept.ID, "Kept (renamed)", "", task.StatusTODO, task.PriorityHigh, nil)
+	app.DeleteTask(deleted.ID)
+	added, _ := app.AddTask("Added", "", task.PriorityLow, nil)
+
+	statuses, err := app.InspectBackups()
+	if err != nil || len(statuses) != 1 || statuses[0].Err != nil || statuses[0].TaskCount != 2 {
+		t.Fatalf("InspectBackups() = %+v, %v", statuses, err)
+	}
+
+	diff, err := app.DiffBackup(name)
+	if err != nil {
+		t.Fatalf("DiffBackup() error = %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].ID != added.ID {
+		t.Errorf("DiffBackup() Added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != deleted.ID {
+		t.Errorf("DiffBackup() Removed = %+v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 || len(diff.Modified[0].Changes) != 2 {
+		t.Fatalf("DiffBackup() Modified = %+v, want title and priority changes", diff.Modified)
+	}
+	if c := diff.Modified[0].Changes[0]; c.Field != "title" || c.OldValue != "Kept" || c.NewValue != "Kept (renamed)" {
+		t.Errorf("DiffBackup() change = %+v", c)
+	}
+
+	// 1件だけ復元しても他の変更はそのまま残り、復元前のバックアップが作成される
+	if _, err := app.RestoreTaskFromBackup(name, kept.ID); err != nil {
+		t.Fatalf("RestoreTaskFromBackup() error = %v", err)
+	}
+	if got, _ := app.GetTaskByID(kept.ID); got.Title != "Kept" {
+		t.Errorf("RestoreTaskFromBackup() title = %s, want Kept", got.Title)
+	}
+	if _, err := app.GetTaskByID(added.ID); err != nil {
+		t.Errorf("RestoreTaskFromBackup() should keep other tasks: %v", err)
+	}
+	if _, err := app.RestoreTaskFromBackup(name, deleted.ID); err != nil {
+		t.Fatalf("RestoreTaskFromBackup() of a deleted task error = %v", err)
+	}
+	if _, err := app.GetTaskByID(deleted.ID); err != nil {
+		t.Errorf("RestoreTaskFromBackup() did not add the deleted task: %v", err)
+	}
+	if backups, _ := s.ListBackups(); len(backups) != 3 {
+		t.Errorf("expected a backup before each restore, got %d backups", len(backups))
+	}
+	if _, err := app.RestoreTaskFromBackup(name, "missing"); err == nil {
+		t.Errorf("RestoreTaskFromBackup() of a missing task should fail")
+	}
+}
+
+func TestRecoverCorruptedTaskFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_recover_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	a, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	kept, _ := a.AddTask("Survivor", "", task.PriorityHigh, nil)
+	path := filepath.Join(tmpDir, ".go-task", "tasks.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read task file: %v", err)
+	}
+	// 末尾が失われたファイル
+	if err := os.WriteFile(path, data[:len(data)-20], 0600); err != nil {
+		t.Fatalf("Failed to write task file: %v", err)
+	}
+
+	if _, err := NewApp(); err == nil || err.(*AppError).Type != ErrTypeCorrupted {
+		t.Fatalf("NewApp() error = %v, want Corrupted", err)
+	}
+	report, err := Recover(true)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if len(report.Salvaged) != 1 || report.Salvaged[0].ID != kept.ID {
+		t.Errorf("Recover() Salvaged = %+v", report.Salvaged)
+	}
+	reopened, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() after Recover() error = %v", err)
+	}
+	if _, err := reopened.GetTaskByID(kept.ID); err != nil {
+		t.Errorf("recovered task file is missing the salvaged task: %v", err)
+	}
+}
+
+func TestRepositoryIndex(t *testing.T) {
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	a, err := NewAppWithStore(store.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+
+	// checkIndex は変更操作で更新された索引が、一覧から作り直した索引と一致することを確認する
+	checkIndex := func(step string) {
+		t.Helper()
+		want := newRepository(a.Tasks.Tasks)
+		got := a.repo()
+		if !reflect.DeepEqual(got.byID, want.byID) || !reflect.DeepEqual(got.byStatus, want.byStatus) ||
+			!reflect.DeepEqual(got.byPriority, want.byPriority) || !reflect.DeepEqual(got.byTag, want.byTag) {
+			t.Errorf("%s: index is out of date\ngot  %+v\nwant %+v", step, got, want)
+		}
+	}
+
+	var ids []string
+	for i := 0; i < 6; i++ {
+		added, err := a.AddTask(fmt.Sprintf("Task %d", i), "", task.PriorityLow, []string{"work/" + strconv.Itoa(i%2), " spaced "})
+		if err != nil {
+			t.Fatalf("AddTask() error = %v", err)
+		}
+		ids = append(ids, added.ID)
+	}
+	checkIndex("AddTask")
+
+	if _, err := a.UpdateTask(ids[1], "", "", task.StatusDone, task.PriorityHigh, []string{"home"}); err != nil {
+		t.Fatalf("UpdateTask() error = %v", err)
+	}
+	checkIndex("UpdateTask")
+
+	if err := a.DeleteTask(ids[0]); err != nil {
+		t.Fatalf("DeleteTask() error = %v", err)
+	}
+	checkIndex("DeleteTask")
+
+	if err := a.DeleteTasks([]string{ids[4], ids[2]}); err != nil {
+		t.Fatalf("DeleteTasks() error = %v", err)
+	}
+	checkIndex("DeleteTasks")
+
+	if _, err := a.RenameTag("work", "job"); err != nil {
+		t.Fatalf("RenameTag() error = %v", err)
+	}
+	checkIndex("RenameTag")
+
+	if _, err := a.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	checkIndex("Undo")
+
+	// 索引を使った絞り込みも一覧の順序で返す
+	var got []string
+	for _, tk := range a.GetFilteredTasksByTags([]string{"work"}) {
+		got = append(got, tk.ID)
+	}
+	if want := []string{ids[3], ids[5]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilteredTasksByTags(work) = %v, want %v", got, want)
+	}
+	if got := a.GetFilteredTasksByTags([]string{"spaced"}); len(got) != 2 {
+		t.Errorf("GetFilteredTasksByTags(spaced) got %d tasks, want 2", len(got))
+	}
+	if got := a.GetFilteredTasksByStatus([]task.Status{task.StatusDone}); len(got) != 1 || got[0].ID != ids[1] {
+		t.Errorf("GetFilteredTasksByStatus(DONE) = %v", got)
+	}
+
+	// App の外で一覧が置き換えられた場合は索引を作り直す
+	a.Tasks.Tasks = append([]task.Task(nil), a.Tasks.Tasks[:1]...)
+	if _, err := a.GetTaskByID(ids[3]); err == nil {
+		t.Errorf("GetTaskByID() found a task that is no longer in the list")
+	}
+	checkIndex("replaced list")
+}
+
+func TestJournalStorageFormat(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_journal_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	cfg.Settings.Storage.Format = store.FormatJournal
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	a, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	a.AddTask("First", "", task.PriorityHigh, nil)
+	added, _ := a.AddTask("Second", "", task.PriorityLow, nil)
+	journal := filepath.Join(tmpDir, ".go-task", "tasks.journal")
+	if _, err := os.Stat(journal); err != nil {
+		t.Fatalf("AddTask() should append to the journal: %v", err)
+	}
+	reopened, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	if _, err := reopened.GetTaskByID(added.ID); err != nil {
+		t.Errorf("task saved to the journal is missing after reload: %v", err)
+	}
+
+	if err := reopened.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("Compact() should remove the journal")
+	}
+
+	cfg.Settings.Storage.Format = "xml"
+	cfg.SaveConfig()
+	if _, err := NewApp(); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("NewApp() with an unknown storage format error = %v, want Validation", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_reload_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+
+	theirs, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	a, _ := theirs.AddTask("A", "", task.PriorityLow, nil)
+	b, _ := theirs.AddTask("B", "", task.PriorityLow, nil)
+	c, _ := theirs.AddTask("C", "", task.PriorityLow, nil)
+
+	mine, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	if mine.ExternalChanges() {
+		t.Errorf("ExternalChanges() right after loading = true, want false")
+	}
+	mine.Tasks.Settings.AutoSave = false
+	mine.UpdateTask(a.ID, "A (mine)", "", "", "", nil)
+	d, _ := mine.AddTask("D", "", task.PriorityLow, nil)
+	// 変更して元に戻したタスクは他のプロセスの変更をそのまま使う
+	mine.UpdateTask(b.ID, "B (mine)", "", "", "", nil)
+	mine.UpdateTask(b.ID, "B", "", "", "", nil)
+
+	theirs.UpdateTask(a.ID, "A (theirs)", "", "", "", nil)
+	theirs.UpdateTask(b.ID, "B (theirs)", "", "", "", nil)
+	theirs.DeleteTask(c.ID)
+	if !mine.ExternalChanges() {
+		t.Fatalf("ExternalChanges() after another instance saved = false, want true")
+	}
+
+	result, err := mine.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if !result.Changed || len(result.Kept) != 2 || !reflect.DeepEqual(result.Conflicts, []string{a.ID}) {
+		t.Errorf("Reload() = %+v, want changed with A and D kept and A conflicting", result)
+	}
+	titles := make(map[string]string)
+	for _, tk := range mine.GetAllTasks() {
+		titles[tk.ID] = tk.Title
+	}
+	want := map[string]string{a.ID: "A (mine)", b.ID: "B (theirs)", d.ID: "D"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("tasks after Reload() = %v, want %v", titles, want)
+	}
+	if mine.ExternalChanges() {
+		t.Errorf("ExternalChanges() after Reload() = true, want false")
+	}
+	if len(mine.GetTaskHistory(d.ID)) == 0 {
+		t.Errorf("history of unsaved changes should be kept after Reload()")
+	}
+
+	// 読み込み直した内容の上に保存でき、保存後は未保存の変更がない
+	if err := mine.Save(); err != nil {
+		t.Fatalf("Save() after Reload() error = %v", err)
+	}
+	if mine.HasUnsavedChanges() {
+		t.Errorf("HasUnsavedChanges() after Save() = true, want false")
+	}
+	result, err = theirs.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if !result.Changed || len(result.Kept) != 0 || len(theirs.GetAllTasks()) != 3 {
+		t.Errorf("Reload() of the other instance = %+v with %d tasks, want 3 tasks and nothing kept", result, len(theirs.GetAllTasks()))
+	}
+
+	// 読み込みの間にタスクを変更した場合は、読み込んだ内容を適用せずに次の確認で読み込み直す
+	theirs.UpdateTask(a.ID, "A (theirs again)", "", "", "", nil)
+	load := mine.PrepareReload()
+	mine.UpdateTask(d.ID, "D (edited while loading)", "", "", "", nil)
+	reloaded, err := load()
+	if err != nil {
+		t.Fatalf("PrepareReload() error = %v", err)
+	}
+	if result, err := mine.ApplyReload(reloaded); err != nil || !result.Discarded {
+		t.Errorf("ApplyReload() after a local edit = %+v, %v, want it discarded", result, err)
+	}
+	if got, _ := mine.GetTaskByID(d.ID); got.Title != "D (edited while loading)" || !mine.ExternalChanges() {
+		t.Errorf("a discarded reload should keep the local edit and the external change pending")
+	}
+	if result, err := mine.Reload(); err != nil || result.Discarded || !result.Changed {
+		t.Errorf("Reload() after a discarded reload = %+v, %v", result, err)
+	}
+	if got, _ := mine.GetTaskByID(a.ID); got.Title != "A (theirs again)" {
+		t.Errorf("title of A after Reload() = %q, want the other instance's title", got.Title)
+	}
+}
+
+func TestGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := os.MkdirTemp("", "go-task_test_git_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+
+	a, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	if _, err := a.GitLog(0); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("GitLog() before InitGitHistory() error = %v, want Validation", err)
+	}
+	added, _ := a.AddTask("Buy groceries", "", task.PriorityLow, nil)
+	if err := a.InitGitHistory(); err != nil {
+		t.Fatalf("InitGitHistory() error = %v", err)
+	}
+	if err := a.InitGitHistory(); err == nil {
+		t.Errorf("InitGitHistory() twice should fail")
+	}
+	a.UpdateTask(added.ID, "", "", task.StatusDone, "", nil)
+
+	commits, err := a.GitLog(0)
+	if err != nil {
+		t.Fatalf("GitLog() error = %v", err)
+	}
+	if len(commits) != 2 || !strings.HasPrefix(commits[0].Subject, "update: Buy groceries status TODO→DONE") {
+		t.Fatalf("GitLog() = %+v, want the status change on top of the initial commit", commits)
+	}
+
+	if err := a.RevertToCommit(commits[1].Hash); err != nil {
+		t.Fatalf("RevertToCommit() error = %v", err)
+	}
+	if got, _ := a.GetTaskByID(added.ID); got.Status != task.StatusTODO {
+		t.Errorf("status after RevertToCommit() = %s, want TODO", got.Status)
+	}
+	commits, _ = a.GitLog(0)
+	if len(commits) != 3 || !strings.HasPrefix(commits[0].Subject, "revert: Buy groceries status DONE→TODO") {
+		t.Errorf("RevertToCommit() should be recorded as a new commit, got %+v", commits)
+	}
+	if err := a.RevertToCommit("no-such-commit"); err == nil || err.(*AppError).Type != ErrTypeNotFound {
+		t.Errorf("RevertToCommit() of an unknown commit error = %v, want NotFound", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_sync_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	shared := filepath.Join(tmpDir, "shared")
+
+	laptop, err := NewAppWithStore(store.NewFileStore(filepath.Join(tmpDir, "laptop")))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	desktop, err := NewAppWithStore(store.NewFileStore(filepath.Join(tmpDir, "desktop")))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	sync := func(a *App) *SyncResult {
+		t.Helper()
+		result, err := a.Sync(shared)
+		if err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		return result
+	}
+	titles := func(a *App) map[string]string {
+		m := make(map[string]string)
+		for _, tk := range a.GetAllTasks() {
+			m[tk.ID] = tk.Title
+		}
+		return m
+	}
+
+	a, _ := laptop.AddTask("A", "", task.PriorityLow, nil)
+	b, _ := laptop.AddTask("B", "", task.PriorityLow, nil)
+	c, _ := laptop.AddTask("C", "", task.PriorityLow, nil)
+	d, _ := laptop.AddTask("D", "", task.PriorityLow, nil)
+	if result := sync(laptop); result.RemoteChanged != 4 || result.Added != 0 {
+		t.Errorf("first Sync() = %+v, want 4 tasks written to the remote", result)
+	}
+	if result := sync(desktop); result.Added != 4 {
+		t.Errorf("Sync() on the other machine = %+v, want 4 tasks added", result)
+	}
+
+	// 異なるフィールドの変更はマージされ、同じフィールドの変更は新しい側が優先されて競合になる
+	laptop.UpdateTask(a.ID, "A (laptop)", "", "", "", nil)
+	laptop.UpdateTask(b.ID, "B (laptop)", "", "", "", nil)
+	laptop.DeleteTask(c.ID)
+	desktop.UpdateTask(a.ID, "", "", "", task.PriorityHigh, nil)
+	desktop.UpdateTask(b.ID, "B (desktop)", "", "", "", nil)
+	sync(laptop)
+	result := sync(desktop)
+	if result.Deleted != 1 || len(result.Conflicts) != 1 || result.Conflicts[0].TaskID != b.ID {
+		t.Errorf("Sync() = %+v, want C deleted and a conflict on B", result)
+	}
+	want := map[string]string{a.ID: "A (laptop)", b.ID: "B (desktop)", d.ID: "D"}
+	if got := titles(desktop); !reflect.DeepEqual(got, want) {
+		t.Errorf("tasks after Sync() = %v, want %v", got, want)
+	}
+	if got, _ := desktop.GetTaskByID(a.ID); got.Priority != task.PriorityHigh {
+		t.Errorf("priority of A after Sync() = %s, want HIGH", got.Priority)
+	}
+
+	// 削除したタスクは削除を知らない側から復活しない
+	sync(laptop)
+	if got := titles(laptop); !reflect.DeepEqual(got, want) {
+		t.Errorf("tasks after Sync() on the other machine = %v, want %v", got, want)
+	}
+
+	// 削除と変更の競合は変更した側の内容を残す
+	laptop.DeleteTask(d.ID)
+	desktop.UpdateTask(d.ID, "D (desktop)", "", "", "", nil)
+	sync(laptop)
+	result = sync(desktop)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].TaskID != d.ID || result.Conflicts[0].Local == nil || result.Conflicts[0].Remote != nil {
+		t.Fatalf("Sync() conflicts = %+v, want a delete/edit conflict on D", result.Conflicts)
+	}
+	if len(desktop.Conflicts()) != 2 {
+		t.Fatalf("Conflicts() = %d, want 2", len(desktop.Conflicts()))
+	}
+
+	// 競合の解決は次の同期で他方に反映される
+	if resolved, err := desktop.ResolveConflict(b.ID, true); err != nil || resolved.Title != "B (laptop)" {
+		t.Errorf("ResolveConflict(remote) = %v, %v, want the laptop title", resolved, err)
+	}
+	if resolved, err := desktop.ResolveConflict(d.ID, true); err != nil || resolved != nil {
+		t.Errorf("ResolveConflict(remote) of a deleted task = %v, %v, want the task deleted", resolved, err)
+	}
+	if _, err := desktop.ResolveConflict(d.ID, false); err == nil {
+		t.Errorf("ResolveConflict() of a resolved conflict should fail")
+	}
+	if len(desktop.Conflicts()) != 0 {
+		t.Errorf("Conflicts() after resolving = %v, want none", desktop.Conflicts())
+	}
+	sync(desktop)
+	sync(laptop)
+	want = map[string]string{a.ID: "A (laptop)", b.ID: "B (laptop)"}
+	for name, app := range map[string]*App{"laptop": laptop, "desktop": desktop} {
+		if got := titles(app); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s tasks after resolving = %v, want %v", name, got, want)
+		}
+	}
+
+	if _, err := desktop.Sync(filepath.Join(tmpDir, "desktop")); err == nil {
+		t.Errorf("Sync() with its own data directory should fail")
+	}
+
+	// 同期先のデータファイルを指定できるが、別の名前のファイルと同期先を取り違えない
+	if result, err := laptop.Sync(filepath.Join(shared, "tasks.json")); err != nil || result.Remote != shared {
+		t.Errorf("Sync() with the remote data file = %+v, %v, want %s", result, err, shared)
+	}
+	other := filepath.Join(shared, "mytasks.json")
+	os.WriteFile(other, []byte("{}"), 0600)
+	before, _ := os.ReadFile(filepath.Join(shared, "tasks.json"))
+	if _, err := laptop.Sync(other); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("Sync() with %s error = %v, want a validation error", other, err)
+	}
+	if after, _ := os.ReadFile(filepath.Join(shared, "tasks.json")); string(before) != string(after) {
+		t.Errorf("Sync() with another file should not write to tasks.json")
+	}
+}
+
+func TestSyncEncrypted(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_sync_encrypted_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+	defer store.SetPassphrase("")
+
+	a, err := NewAppWithStore(store.NewFileStore(filepath.Join(tmpDir, "local")))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	a.AddTask("Call ACME customer", "", task.PriorityLow, nil)
+	if err := a.SetEncryption("pass"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+
+	// 新しい同期先はローカルと同じパスフレーズで暗号化される
+	shared := filepath.Join(tmpDir, "shared")
+	if _, err := a.Sync(shared); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(shared, "tasks.json"))
+	if strings.Contains(string(data), "ACME") {
+		t.Errorf("Sync() wrote plaintext tasks to the remote: %s", data)
+	}
+	remote, err := store.NewFileStore(shared).Load()
+	if err != nil || len(remote.Tasks) != 1 {
+		t.Errorf("Load() of the remote with the passphrase = %+v, %v", remote, err)
+	}
+
+	// 暗号化の状態が異なる同期先とは同期しない
+	plain := filepath.Join(tmpDir, "plain")
+	os.MkdirAll(plain, 0700)
+	os.WriteFile(filepath.Join(plain, "tasks.json"), []byte(`{"version":"`+store.CurrentVersion+`","tasks":[]}`), 0600)
+	if _, err := a.Sync(plain); err == nil || err.(*AppError).Type != ErrTypeValidation {
+		t.Errorf("Sync() with a plaintext remote error = %v, want a validation error", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(plain, "tasks.json")); strings.Contains(string(data), "ACME") {
+		t.Errorf("Sync() wrote tasks to a plaintext remote: %s", data)
+	}
+}
+
+func TestCSVExportImport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_csv_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	app.AddTask("Write, then \"quote\"", "Line 1\nLine 2", task.PriorityHigh, []string{"work", "q3"})
+	configDir, err := store.GetConfigDirPath()
+	if err != nil {
+		t.Fatalf("Failed to get config dir path: %v", err)
+	}
+
+	// 選択した列だけを指定した順に書き出す
+	exportPath := filepath.Join(configDir, "tasks.csv")
+	if err := app.ExportTasksCSV(exportPath, []string{"title", "priority", "tags"}); err != nil {
+		t.Fatalf("ExportTasksCSV() error = %v", err)
+	}
+	data, _ := os.ReadFile(exportPath)
+	want := "title,priority,tags\n\"Write, then \"\"quote\"\"\",HIGH,\"work,q3\"\n"
+	if string(data) != want {
+		t.Errorf("exported CSV = %q, want %q", data, want)
+	}
+	if err := app.ExportTasksCSV(exportPath, []string{"title", "owner"}); err == nil {
+		t.Errorf("ExportTasksCSV() with an unknown column should fail")
+	}
+	if err := app.ExportTasksCSV(filepath.Join(tmpDir, "outside.csv"), nil); err == nil {
+		t.Errorf("ExportTasksCSV() outside of the data directory should fail")
+	}
+
+	importPath := filepath.Join(configDir, "import.csv")
+	csvData := "\ufeffName,Prio,State,Labels,Deadline,Owner\n" +
+		"Plan budget,high,in progress,finance; q3,2024-05-01,alice\n" +
+		"Review,M,Done,,,bob\n" +
+		",low,todo,,,carol\n" +
+		"Urgent,urgent,todo,,,dave\n" +
+		"Late,low,todo,,next week,erin\n" +
+		",,,,,\n"
+	if err := os.WriteFile(importPath, []byte(csvData), 0600); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	// 列名から対応を推測し、タスクは変更しない
+	report, err := app.PreviewCSVImport(importPath, nil)
+	if err != nil {
+		t.Fatalf("PreviewCSVImport() error = %v", err)
+	}
+	wantMapping := CSVMapping{"title": "Name", "priority": "Prio", "status": "State", "tags": "Labels", "due_date": "Deadline"}
+	if !reflect.DeepEqual(report.Mapping, wantMapping) {
+		t.Errorf("PreviewCSVImport() mapping = %v, want %v", report.Mapping, wantMapping)
+	}
+	if len(report.Tasks) != 2 || len(app.GetAllTasks()) != 1 {
+		t.Errorf("PreviewCSVImport() = %d tasks with %d tasks stored, want 2 and 1", len(report.Tasks), len(app.GetAllTasks()))
+	}
+	var rows []int
+	for _, e := range report.Errors {
+		rows = append(rows, e.Row)
+	}
+	if !reflect.DeepEqual(rows, []int{4, 5, 6}) {
+		t.Errorf("PreviewCSVImport() error rows = %v (%v), want [4 5 6]", rows, report.Errors)
+	}
+	if len(report.Errors) == 3 && !strings.Contains(report.Errors[1].Error(), `unknown priority "urgent"`) {
+		t.Errorf("error for row 5 = %q, want an unknown priority", report.Errors[1].Error())
+	}
+
+	report, err = app.ImportTasksCSV(importPath, nil)
+	if err != nil {
+		t.Fatalf("ImportTasksCSV() error = %v", err)
+	}
+	if len(report.Tasks) != 2 || len(app.GetAllTasks()) != 3 {
+		t.Fatalf("ImportTasksCSV() = %d tasks with %d tasks stored, want 2 and 3", len(report.Tasks), len(app.GetAllTasks()))
+	}
+	plan, _ := app.GetTaskByID(report.Tasks[0].ID)
+	if plan.Priority != task.PriorityHigh || plan.Status != task.StatusInProgress || !reflect.DeepEqual(plan.Tags, []string{"finance", "q3"}) ||
+		plan.DueDate == nil || plan.DueDate.Format("2006-01-02") != "2024-05-01" {
+		t.Errorf("imported task = %+v, want normalised priority, status, tags and due date", plan)
+	}
+	if review, _ := app.GetTaskByID(report.Tasks[1].ID); review.Status != task.StatusDone || review.CompletedAt == nil || review.Priority != task.PriorityMedium {
+		t.Errorf("imported done task = %+v, want DONE with a completion time and MEDIUM", review)
+	}
+
+	// 明示した対応を使用し、取り込み済みのIDは重複として報告する
+	if err := app.ExportTasksCSV(exportPath, []string{"id", "title"}); err != nil {
+		t.Fatalf("ExportTasksCSV() error = %v", err)
+	}
+	report, err = app.ImportTasksCSV(exportPath, CSVMapping{"id": "id", "title": "title"})
+	if err != nil || len(report.Tasks) != 0 || len(report.Errors) != 3 {
+		t.Errorf("ImportTasksCSV() of existing tasks = %+v, %v, want 3 duplicate rows", report, err)
+	}
+	if _, err := app.ImportTasksCSV(importPath, CSVMapping{"priority": "Prio"}); err == nil {
+		t.Errorf("ImportTasksCSV() without a title column should fail")
+	}
+	if _, err := app.ImportTasksCSV(importPath, CSVMapping{"title": "Missing"}); err == nil {
+		t.Errorf("ImportTasksCSV() with a missing column should fail")
+	}
+}
+
+func TestTransferPathUsesStorageDir(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_transfer_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnvForTest(t, tmpDir)
+
+	// ローカルのタスクファイルやワークスペースと同じく、既定とは別のディレクトリに保存する App
+	dir := filepath.Join(tmpDir, "project", ".go-task")
+	a, err := NewAppWithStore(store.NewFileStore(dir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	a.AddTask("Local task", "", task.PriorityLow, nil)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+
+	if err := a.ExportTasks(filepath.Join(dir, "export.json")); err != nil {
+		t.Errorf("ExportTasks() into the App's own directory error = %v", err)
+	}
+	if err := a.ExportTasksCSV(filepath.Join(dir, "export.csv"), nil); err != nil {
+		t.Errorf("ExportTasksCSV() into the App's own directory error = %v", err)
+	}
+	if _, err := a.PreviewCSVImport(filepath.Join(dir, "export.csv"), nil); err != nil {
+		t.Errorf("PreviewCSVImport() from the App's own directory error = %v", err)
+	}
+
+	// 既定のデータディレクトリは、この App にとっては外部のディレクトリ
+	configDir, err := store.GetConfigDirPath()
+	if err != nil {
+		t.Fatalf("Failed to get config dir path: %v", err)
+	}
+	outside := filepath.Join(configDir, "export.json")
+	if err := a.ExportTasks(outside); err == nil {
+		t.Errorf("ExportTasks() into the default data directory should fail for a local App")
+	}
+	if err := a.ImportTasks(outside); err == nil {
+		t.Errorf("ImportTasks() from the default data directory should fail for a local App")
+	}
+	if err := a.RestoreBackup(outside); err == nil {
+		t.Errorf("RestoreBackup() from the default data directory should fail for a local App")
+	}
+
+	// ファイルに保存しない App ではどのパスも許可しない
+	memory, _ := NewAppWithStore(store.NewMemoryStore())
+	if err := memory.ExportTasks(filepath.Join(dir, "memory.json")); err == nil {
+		t.Errorf("ExportTasks() should fail for an App without a data directory")
+	}
+}
+
+func TestGitCommitFailure(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := os.MkdirTemp("", "go-task_test_git_fail_app_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("GO_TASK_TEST_ENV", "true")
+
+	a, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	if err := a.InitGitHistory(); err != nil {
+		t.Fatalf("InitGitHistory() error = %v", err)
+	}
+
+	// 常に失敗する git コマンドを PATH の先頭に置く
+	fakeDir := t.TempDir()
+	script := "#!/bin/sh\necho 'fatal: simulated failure' >&2\nexit 128\n"
+	if err := os.WriteFile(filepath.Join(fakeDir, "git"), []byte(script), 0700); err != nil {
+		t.Fatalf("Failed to write fake git: %v", err)
+	}
+	t.Setenv("PATH", fakeDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, err = a.AddTask("Saved without a commit", "", task.PriorityLow, nil)
+	appErr, ok := err.(*AppError)
+	if !ok || appErr.Type != ErrTypeGit || !strings.Contains(appErr.Message, "saved") {
+		t.Fatalf("AddTask() with a failing git error = %v, want a Git error saying the tasks were saved", err)
+	}
+	reopened, err := NewAppWithStore(store.NewFileStore(tmpDir))
+	if err != nil {
+		t.Fatalf("NewAppWithStore() error = %v", err)
+	}
+	if tasks := reopened.GetAllTasks(); len(tasks) != 1 || tasks[0].Title != "Saved without a commit" {
+		t.Errorf("tasks after a failed commit = %v, want the added task saved", tasks)
+	}
+}
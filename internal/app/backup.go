@@ -0,0 +1,221 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"go-task/internal/config"
+	"go-task/internal/log"
+	"go-task/internal/store"
+	"go-task/internal/task"
+)
+
+// loadBackupSettings は設定ファイルのバックアップ設定を返します。読み込めない場合は既定の設定を使用します。
+func loadBackupSettings() config.BackupSettings {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Error("Failed to load config, using default backup settings:", err)
+		return config.DefaultBackupSettings()
+	}
+	return cfg.Settings.Backup
+}
+
+// backupBeforeDestructive は設定に応じて、インポートや一括削除などの大きな変更の前にバックアップを作成します。
+// バックアップを作成できない場合は操作を中止するためにエラーを返します。
+func (a *App) backupBeforeDestructive(operation string) error {
+	if !a.backup.BeforeDestructive {
+		return nil
+	}
+	if err := a.createBackup(); err != nil {
+		log.Error("Failed to create backup before "+operation+":", err)
+		return NewAppError(ErrTypeIO, fmt.Sprintf("Failed to create a backup before %s.", operation), err)
+	}
+	return nil
+}
+
+// backupBeforeRestore はバックアップからの復元の前に、設定に関わらず現在のタスクデータのバックアップを作成します。
+// 復元を誤った場合でも、このバックアップから復元前の状態に戻せます。
+func (a *App) backupBeforeRestore() error {
+	if err := a.createBackup(); err != nil {
+		log.Error("Failed to create backup before restore:", err)
+		return NewAppError(ErrTypeIO, "Failed to create a backup before restore.", err)
+	}
+	return nil
+}
+
+// Close は定期バックアップを終了し、設定に応じて終了時のバックアップを作成します。
+// TUI の終了時と、ワークスペースの切り替えなどで App を使い終えた時に呼び出します。2回目以降の呼び出しでは定期バックアップの終了を省きます。
+func (a *App) Close() error {
+	if a.stopBackups != nil {
+		// 実行中の定期バックアップが終わるのを待ち、終了時のバックアップと重ならないようにする
+		close(a.stopBackups)
+		<-a.backupsDone
+		a.stopBackups = nil
+	}
+	if !a.backup.OnExit {
+		return nil
+	}
+	if err := a.createBackup(); err != nil {
+		log.Error("Failed to create backup on exit:", err)
+		return NewAppError(ErrTypeIO, "Failed to create a backup on exit.", err)
+	}
+	return nil
+}
+
+// createBackup はバックアップを作成し、保持ルールに該当しない古いバックアップを削除します。
+func (a *App) createBackup() error {
+	if err := a.storage.CreateBackup(a.Tasks); err != nil {
+		return err
+	}
+	if err := a.storage.CleanOldBackups(); err != nil {
+		log.Error("Failed to clean old backups after new backup:", err)
+	}
+	return nil
+}
+
+// BackupStatus はバックアップの内容を検証した結果です。
+type BackupStatus struct {
+	store.BackupInfo
+	TaskCount int   // バックアップに含まれるタスクの数
+	Err       error // 読み込みまたは検証に失敗した場合のエラー。正常な場合は nil
+}
+
+// InspectBackups はすべてのバックアップを読み込んで検証し、新しい順に結果を返します。
+// 個々のバックアップの検証に失敗してもエラーにはせず、BackupStatus.Err に記録します。
+func (a *App) InspectBackups() ([]BackupStatus, error) {
+	backups, err := a.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]BackupStatus, len(backups))
+	for i, b := range backups {
+		statuses[i].BackupInfo = b
+		tasks, err := a.storage.LoadBackup(b.Name)
+		if err == nil {
+			err = store.VerifyTasks(tasks)
+		}
+		if err != nil {
+			statuses[i].Err = err
+			continue
+		}
+		statuses[i].TaskCount = len(tasks.Tasks)
+	}
+	return statuses, nil
+}
+
+// loadVerifiedBackup は指定された名前のバックアップを読み込み、内容を検証します。
+func (a *App) loadVerifiedBackup(name string) (*task.Tasks, error) {
+	tasks, err := a.storage.LoadBackup(name)
+	if err != nil {
+		log.Error("Failed to load backup:", err)
+		return nil, newLoadError(ErrTypeIO, fmt.Sprintf("Failed to load backup %s.", name), err)
+	}
+	if err := store.VerifyTasks(tasks); err != nil {
+		log.Error("Backup failed verification:", err)
+		return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Backup %s is invalid.", name), err)
+	}
+	return tasks, nil
+}
+
+// TaskDiff はバックアップと現在のデータの両方に存在し、内容が異なるタスクです。
+type TaskDiff struct {
+	Backup  task.Task
+	Current task.Task
+	// Changes はフィールド単位の差分です。OldValue がバックアップの値、NewValue が現在の値です。
+	Changes []task.HistoryEntry
+}
+
+// BackupDiff はバックアップと現在のタスクデータの差分です。
+type BackupDiff struct {
+	Added           []task.Task // 現在のデータにのみ存在するタスク
+	Removed         []task.Task // バックアップにのみ存在するタスク
+	Modified        []TaskDiff  // 両方に存在し、内容が異なるタスク
+	SettingsChanged bool        // 設定が異なるか
+}
+
+// Empty は差分がないかを返します。
+func (d *BackupDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && !d.SettingsChanged
+}
+
+// RestorableTasks は RestoreTaskFromBackup で現在のデータに戻せるバックアップのタスクを返します。
+// バックアップにのみ存在するタスクの後に、内容が異なるタスクのバックアップ時点の内容が続きます。
+func (d *BackupDiff) RestorableTasks() []task.Task {
+	tasks := append([]task.Task(nil), d.Removed...)
+	for _, m := range d.Modified {
+		tasks = append(tasks, m.Backup)
+	}
+	return tasks
+}
+
+// DiffBackup は指定された名前のバックアップと現在のタスクデータの差分を返します。
+func (a *App) DiffBackup(name string) (*BackupDiff, error) {
+	backupTasks, err := a.loadVerifiedBackup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BackupDiff{SettingsChanged: backupTasks.Settings != a.Tasks.Settings}
+	backupByID := make(map[string]task.Task, len(backupTasks.Tasks))
+	for _, t := range backupTasks.Tasks {
+		backupByID[t.ID] = t
+	}
+	currentIDs := make(map[string]bool, len(a.Tasks.Tasks))
+	for _, current := range a.Tasks.Tasks {
+		currentIDs[current.ID] = true
+		backup, ok := backupByID[current.ID]
+		if !ok {
+			diff.Added = append(diff.Added, current)
+			continue
+		}
+		if changes := task.DiffTask(&backup, &current, time.Time{}, SourceRestore); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, TaskDiff{Backup: backup, Current: current, Changes: changes})
+		}
+	}
+	for _, t := range backupTasks.Tasks {
+		if !currentIDs[t.ID] {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+	return diff, nil
+}
+
+// RestoreTaskFromBackup はバックアップから1件のタスクだけを復元します。
+// 現在のデータに同じIDのタスクがある場合はバックアップ時点の内容で置き換え、ない場合は追加します。
+// 他のタスクと設定は変更しません。復元の前に現在のタスクデータのバックアップを作成します。
+func (a *App) RestoreTaskFromBackup(name, id string) (*task.Task, error) {
+	backupTasks, err := a.loadVerifiedBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	var restored *task.Task
+	for i := range backupTasks.Tasks {
+		if backupTasks.Tasks[i].ID == id {
+			restored = &backupTasks.Tasks[i]
+			break
+		}
+	}
+	if restored == nil {
+		return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found in backup %s.", id, name), nil)
+	}
+
+	if err := a.backupBeforeRestore(); err != nil {
+		return nil, err
+	}
+
+	before := a.snapshot([]string{id})
+	if i, ok := a.taskPosition(id); ok {
+		a.replaceTask(i, *restored)
+	} else {
+		a.appendTasks(*restored)
+	}
+	a.Tasks.UpdatedAt = time.Now()
+	a.recordOperation(SourceRestore, before)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			return nil, newSaveError("Failed to auto-save tasks after restore.", err)
+		}
+	}
+	return restored, nil
+}
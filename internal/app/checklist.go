@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+
+	"go-task/internal/task"
+)
+
+// AddChecklistItem はタスクのチェックリストの末尾に項目を追加します。
+func (a *App) AddChecklistItem(id, text string) (*task.Task, error) {
+	if text == "" {
+		return nil, NewAppError(ErrTypeValidation, "Checklist item text cannot be empty.", nil)
+	}
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.Checklist = append(t.Checklist, task.ChecklistItem{Text: text})
+	})
+}
+
+// ToggleChecklistItem はチェックリストの指定された位置の項目の完了状態を切り替えます。
+func (a *App) ToggleChecklistItem(id string, index int) (*task.Task, error) {
+	if err := a.checkChecklistIndex(id, index); err != nil {
+		return nil, err
+	}
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.Checklist[index].Done = !t.Checklist[index].Done
+	})
+}
+
+// MoveChecklistItem はチェックリストの項目を delta だけ移動します (負の値で上、正の値で下)。
+// 移動先がリストの範囲外の場合は先頭または末尾に移動します。
+func (a *App) MoveChecklistItem(id string, index, delta int) (*task.Task, error) {
+	if err := a.checkChecklistIndex(id, index); err != nil {
+		return nil, err
+	}
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		to := index + delta
+		if to < 0 {
+			to = 0
+		}
+		if to > len(t.Checklist)-1 {
+			to = len(t.Checklist) - 1
+		}
+		item := t.Checklist[index]
+		t.Checklist = append(t.Checklist[:index], t.Checklist[index+1:]...)
+		t.Checklist = append(t.Checklist[:to], append([]task.ChecklistItem{item}, t.Checklist[to:]...)...)
+	})
+}
+
+// RemoveChecklistItem はチェックリストの指定された位置の項目を削除します。
+func (a *App) RemoveChecklistItem(id string, index int) (*task.Task, error) {
+	if err := a.checkChecklistIndex(id, index); err != nil {
+		return nil, err
+	}
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.Checklist = append(t.Checklist[:index], t.Checklist[index+1:]...)
+	})
+}
+
+// checkChecklistIndex は指定された位置がタスクのチェックリストの範囲内かを確認します。
+func (a *App) checkChecklistIndex(id string, index int) error {
+	t, err := a.GetTaskByID(id)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.Checklist) {
+		return NewAppError(ErrTypeValidation, fmt.Sprintf("Checklist item %d does not exist.", index+1), nil)
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-task/internal/log"
+	"go-task/internal/task"
+)
+
+// csvColumn は CSV の列として入出力できるタスクのフィールドです。
+type csvColumn struct {
+	name string
+	// aliases は列名の推測 (guessCSVMapping) で、このフィールドとみなす列名です。
+	aliases []string
+	value   func(t *task.Task) string
+	// set は CSV の値を正規化してタスクに設定します。空の値では呼び出されません。
+	set func(t *task.Task, value string) error
+}
+
+// csvColumns はエクスポートの既定の列と、その順序です。
+var csvColumns = []csvColumn{
+	{"id", []string{"uuid", "task_id"},
+		func(t *task.Task) string { return t.ID },
+		func(t *task.Task, v string) error { t.ID = v; return nil }},
+	{"title", []string{"name", "task", "summary", "subject"},
+		func(t *task.Task) string { return t.Title },
+		func(t *task.Task, v string) error { t.Title = v; return nil }},
+	{"description", []string{"desc", "details", "notes", "note"},
+		func(t *task.Task) string { return t.Description },
+		func(t *task.Task, v string) error { t.Description = v; return nil }},
+	{"status", []string{"state"},
+		func(t *task.Task) string { return string(t.Status) },
+		func(t *task.Task, v string) (err error) { t.Status, err = parseCSVStatus(v); return err }},
+	{"priority", []string{"prio", "importance"},
+		func(t *task.Task) string { return string(t.Priority) },
+		func(t *task.Task, v string) (err error) { t.Priority, err = parseCSVPriority(v); return err }},
+	{"tags", []string{"tag", "labels", "label"},
+		func(t *task.Task) string { return strings.Join(t.Tags, ",") },
+		func(t *task.Task, v string) error { t.Tags = splitCSVList(v); return nil }},
+	{"due_date", []string{"due", "deadline", "due_at"},
+		func(t *task.Task) string { return formatCSVTime(t.DueDate) },
+		func(t *task.Task, v string) (err error) { t.DueDate, err = parseCSVTime(v); return err }},
+	{"created_at", []string{"created", "created_on"},
+		func(t *task.Task) string { return formatCSVTime(&t.CreatedAt) },
+		func(t *task.Task, v string) error {
+			created, err := parseCSVTime(v)
+			if err == nil {
+				t.CreatedAt = *created
+			}
+			return err
+		}},
+	{"updated_at", []string{"updated", "modified", "updated_on"},
+		func(t *task.Task) string { return formatCSVTime(&t.UpdatedAt) },
+		func(t *task.Task, v string) error {
+			updated, err := parseCSVTime(v)
+			if err == nil {
+				t.UpdatedAt = *updated
+			}
+			return err
+		}},
+	{"completed_at", []string{"completed", "done_at", "completed_on"},
+		func(t *task.Task) string { return formatCSVTime(t.CompletedAt) },
+		func(t *task.Task, v string) (err error) { t.CompletedAt, err = parseCSVTime(v); return err }},
+	{"wait_until", []string{"wait", "snooze_until", "snoozed_until"},
+		func(t *task.Task) string { return formatCSVTime(t.WaitUntil) },
+		func(t *task.Task, v string) (err error) { t.WaitUntil, err = parseCSVTime(v); return err }},
+}
+
+// CSVColumns は CSV として入出力できる列 (タスクのフィールド名) をエクスポートの既定の順序で返します。
+func CSVColumns() []string {
+	names := make([]string, len(csvColumns))
+	for i, c := range csvColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// findCSVColumn は名前に対応する列を返します。
+func findCSVColumn(name string) (csvColumn, bool) {
+	for _, c := range csvColumns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+// ExportTasksCSV はタスクを CSV ファイルに書き出します。columns は出力する列とその順序で、空の場合はすべての列を出力します。
+// 1行目は列名のヘッダーです。日時は RFC 3339 形式、タグはカンマ区切りで出力します。
+func (a *App) ExportTasksCSV(filePath string, columns []string) error {
+	if err := a.checkTransferPath(filePath, "export"); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		columns = CSVColumns()
+	}
+	selected := make([]csvColumn, len(columns))
+	for i, name := range columns {
+		c, ok := findCSVColumn(name)
+		if !ok {
+			return NewAppError(ErrTypeValidation, fmt.Sprintf("Unknown CSV column %q. Available columns: %s.", name, strings.Join(CSVColumns(), ", ")), nil)
+		}
+		selected[i] = c
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(columns)
+	for i := range a.Tasks.Tasks {
+		record := make([]string, len(selected))
+		for j, c := range selected {
+			record[j] = c.value(&a.Tasks.Tasks[i])
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Error("Failed to write CSV for export:", err)
+		return NewAppError(ErrTypeInternal, "Failed to write tasks as CSV.", err)
+	}
+
+	if err := os.WriteFile(filePath, buf.Bytes(), 0600); err != nil {
+		log.Error("Failed to write export file:", err)
+		return NewAppError(ErrTypeIO, fmt.Sprintf("Failed to write exported data to file %s.", filePath), err)
+	}
+	return nil
+}
+
+// CSVMapping は CSV のインポートで、タスクのフィールド名 → そのフィールドの値を読み込む CSV の列名の対応です。
+// 対応のないフィールドは既定値 (状態は TODO、優先度は設定の既定の優先度) になり、対応のない列は無視されます。
+type CSVMapping map[string]string
+
+// CSVRowError は CSV のインポートで読み込めなかった行とその理由です。
+type CSVRowError struct {
+	// Row はスプレッドシートと同じく、ヘッダーを1行目とした行番号です。
+	Row int
+	Err error
+}
+
+func (e CSVRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// CSVImportReport は CSV のインポート、またはその確認の結果です。
+type CSVImportReport struct {
+	// Header は CSV の1行目の列名です。
+	Header []string
+	// Mapping は使用したフィールドと列の対応です。
+	Mapping CSVMapping
+	// Tasks は読み込めたタスクです。インポートした場合は追加されたタスクです。
+	Tasks []task.Task
+	// Errors は読み込めなかった行です。これらの行はインポートされません。
+	Errors []CSVRowError
+}
+
+// PreviewCSVImport は CSV ファイルを読み込み、インポートされるタスクと読み込めない行を返します。タスクは変更しません。
+// mapping が nil の場合は、ヘッダーの列名からフィールドとの対応を推測します。
+func (a *App) PreviewCSVImport(filePath string, mapping CSVMapping) (*CSVImportReport, error) {
+	return a.readCSVImport(filePath, mapping)
+}
+
+// ImportTasksCSV は CSV ファイルのタスクを追加します。mapping は PreviewCSVImport と同じです。
+// 各行の値は正規化 ("high" → HIGH、"in progress" → IN_PROGRESS など) した後に Task.Validate で検証し、
+// 読み込めない行や既存のタスクとIDが重複する行は追加せずに Errors として返します。追加は1回の操作として取り消せます。
+func (a *App) ImportTasksCSV(filePath string, mapping CSVMapping) (*CSVImportReport, error) {
+	report, err := a.readCSVImport(filePath, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Tasks) > 0 {
+		if err := a.backupBeforeDestructive("import"); err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(report.Tasks))
+		for i, t := range report.Tasks {
+			ids[i] = t.ID
+		}
+		before := a.snapshot(ids)
+		a.appendTasks(cloneTasks(report.Tasks)...)
+		a.recordOperation(SourceImport, before)
+	}
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			return nil, newSaveError("Failed to auto-save tasks after import.", err)
+		}
+	}
+	return report, nil
+}
+
+// readCSVImport は CSV ファイルを読み込み、各行をタスクに変換します。
+func (a *App) readCSVImport(filePath string, mapping CSVMapping) (*CSVImportReport, error) {
+	if err := a.checkTransferPath(filePath, "import"); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Error("Failed to read import file:", err)
+		return nil, NewAppError(ErrTypeIO, fmt.Sprintf("Failed to read import file %s.", filePath), err)
+	}
+	// 表計算ソフトが先頭に付ける BOM を取り除く
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		log.Error("Failed to parse CSV import file:", err)
+		return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Failed to parse CSV file %s.", filePath), err)
+	}
+	if len(records) == 0 {
+		return nil, NewAppError(ErrTypeValidation, "The CSV file has no header row.", nil)
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	if mapping == nil {
+		mapping = guessCSVMapping(header)
+	}
+
+	// フィールド → 列の位置
+	positions := make(map[string]int, len(mapping))
+	for field, column := range mapping {
+		if _, ok := findCSVColumn(field); !ok {
+			return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Unknown task field %q. Available fields: %s.", field, strings.Join(CSVColumns(), ", ")), nil)
+		}
+		pos := -1
+		for i, name := range header {
+			if strings.EqualFold(name, column) {
+				pos = i
+				break
+			}
+		}
+		if pos < 0 {
+			return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("The CSV file has no column %q for %s.", column, field), nil)
+		}
+		positions[field] = pos
+	}
+	if _, ok := positions["title"]; !ok {
+		return nil, NewAppError(ErrTypeValidation, "No CSV column is mapped to the task title.", nil)
+	}
+
+	report := &CSVImportReport{Header: header, Mapping: mapping}
+	seen := make(idSet)
+	now := time.Now()
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		t, err := a.csvTask(record, positions, now)
+		if err == nil {
+			_, exists := a.taskPosition(t.ID)
+			_, dup := seen[t.ID]
+			if exists || dup {
+				err = fmt.Errorf("duplicate task ID %s", t.ID)
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, CSVRowError{Row: i + 2, Err: err})
+			continue
+		}
+		seen[t.ID] = struct{}{}
+		report.Tasks = append(report.Tasks, t)
+	}
+	return report, nil
+}
+
+// csvTask は CSV の1行をタスクに変換し、Task.Validate で検証します。
+func (a *App) csvTask(record []string, positions map[string]int, now time.Time) (task.Task, error) {
+	t := task.Task{Status: task.StatusTODO, Priority: a.Tasks.Settings.DefaultPriority, CreatedAt: now, UpdatedAt: now}
+	// フィールドの定義順に設定し、エラーの報告順を安定させる
+	for _, c := range csvColumns {
+		pos, ok := positions[c.name]
+		if !ok || pos >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(record[pos])
+		if value == "" {
+			continue
+		}
+		if err := c.set(&t, value); err != nil {
+			return task.Task{}, fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	switch {
+	case t.Status == task.StatusDone && t.CompletedAt == nil:
+		completed := t.UpdatedAt
+		t.CompletedAt = &completed
+	case t.Status != task.StatusDone:
+		t.CompletedAt = nil
+	}
+	if err := t.Validate(); err != nil {
+		return task.Task{}, err
+	}
+	return t, nil
+}
+
+// guessCSVMapping はヘッダーの列名から、フィールドとの対応を推測します。
+// 大文字と小文字、空白とハイフンの違いを無視して、フィールド名またはその別名と一致する最初の列を対応させます。
+func guessCSVMapping(header []string) CSVMapping {
+	mapping := make(CSVMapping)
+	for _, c := range csvColumns {
+		for _, column := range header {
+			key := normalizeCSVKey(column)
+			if key == c.name || containsString(c.aliases, key) {
+				mapping[c.name] = column
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// normalizeCSVKey は列名や値を小文字にし、空白とハイフンをアンダースコアにします。
+func normalizeCSVKey(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(s)
+}
+
+// containsString はスライスに文字列が含まれるかを返します。
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// csvStatuses と csvPriorities は CSV の値 (normalizeCSVKey で正規化したもの) と状態、優先度の対応です。
+var (
+	csvStatuses = map[string]task.Status{
+		"todo": task.StatusTODO, "to_do": task.StatusTODO, "open": task.StatusTODO, "new": task.StatusTODO,
+		"in_progress": task.StatusInProgress, "inprogress": task.StatusInProgress, "doing": task.StatusInProgress, "started": task.StatusInProgress,
+		"done": task.StatusDone, "complete": task.StatusDone, "completed": task.StatusDone, "closed": task.StatusDone,
+		"pending": task.StatusPending, "waiting": task.StatusPending, "blocked": task.StatusPending, "on_hold": task.StatusPending,
+	}
+	csvPriorities = map[string]task.Priority{
+		"high": task.PriorityHigh, "h": task.PriorityHigh, "1": task.PriorityHigh,
+		"medium": task.PriorityMedium, "med": task.PriorityMedium, "m": task.PriorityMedium, "normal": task.PriorityMedium, "2": task.PriorityMedium,
+		"low": task.PriorityLow, "l": task.PriorityLow, "3": task.PriorityLow,
+	}
+)
+
+// parseCSVStatus は "Done" や "in progress" などの値をタスクの状態に変換します。
+func parseCSVStatus(value string) (task.Status, error) {
+	if s, ok := csvStatuses[normalizeCSVKey(value)]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown status %q", value)
+}
+
+// parseCSVPriority は "high" や "M" などの値をタスクの優先度に変換します。
+func parseCSVPriority(value string) (task.Priority, error) {
+	if p, ok := csvPriorities[normalizeCSVKey(value)]; ok {
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown priority %q", value)
+}
+
+// splitCSVList はカンマまたはセミコロンで区切られた値を、空の要素を除いて分割します。
+func splitCSVList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// csvTimeLayouts は CSV の日時として受け付ける形式です。タイムゾーンのない形式はローカル時刻とみなします。
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006/01/02 15:04", "2006/01/02"}
+
+// parseCSVTime は CSV の日時を変換します。
+func parseCSVTime(value string) (*time.Time, error) {
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", value)
+}
+
+// formatCSVTime は日時を RFC 3339 形式にします。nil またはゼロ値の場合は空文字を返します。
+func formatCSVTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
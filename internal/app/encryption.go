@@ -0,0 +1,34 @@
+package app
+
+import (
+	"go-task/internal/log"
+	"go-task/internal/store"
+)
+
+// Encrypted はタスクデータが暗号化されているかを返します。暗号化に対応していない保存先では常に false です。
+func (a *App) Encrypted() (bool, error) {
+	e, ok := a.storage.(store.Encrypter)
+	if !ok {
+		return false, nil
+	}
+	encrypted, err := e.Encrypted()
+	if err != nil {
+		log.Error("Failed to check encryption:", err)
+		return false, NewAppError(ErrTypeIO, "Failed to check encryption.", err)
+	}
+	return encrypted, nil
+}
+
+// SetEncryption はタスクデータとバックアップを新しいパスフレーズで暗号化し直します。
+// 暗号化の有効化とパスフレーズの変更 (鍵のローテーション) に使用し、空文字列を指定すると暗号化を解除します。
+func (a *App) SetEncryption(passphrase string) error {
+	e, ok := a.storage.(store.Encrypter)
+	if !ok {
+		return NewAppError(ErrTypeValidation, "This storage does not support encryption.", nil)
+	}
+	if err := e.SetEncryption(passphrase); err != nil {
+		log.Error("Failed to change encryption:", err)
+		return newLoadError(ErrTypeIO, "Failed to change encryption.", err)
+	}
+	return nil
+}
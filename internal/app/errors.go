@@ -1,6 +1,11 @@
 package app
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"go-task/internal/store"
+)
 
 // ErrorType はエラーの種類を表します。
 type ErrorType string
@@ -14,6 +19,16 @@ const (
 	ErrTypeIO ErrorType = "IO"
 	// ErrTypeInternal は予期せぬ内部エラーを表します。
 	ErrTypeInternal ErrorType = "Internal"
+	// ErrTypeConflict は他のプロセスによる更新との競合を表します。
+	ErrTypeConflict ErrorType = "Conflict"
+	// ErrTypeVersion はデータファイルが新しいバージョンの go-task で書かれていることを表します。
+	ErrTypeVersion ErrorType = "Version"
+	// ErrTypePassphrase は暗号化されたタスクデータのパスフレーズが未入力または誤っていることを表します。
+	ErrTypePassphrase ErrorType = "Passphrase"
+	// ErrTypeCorrupted はデータファイルが破損していて読み込めないことを表します。
+	ErrTypeCorrupted ErrorType = "Corrupted"
+	// ErrTypeGit はタスクデータの保存は完了したが、git へのコミットに失敗したことを表します。
+	ErrTypeGit ErrorType = "Git"
 )
 
 // AppError はアプリケーション固有のエラーを表す構造体です。
@@ -44,3 +59,38 @@ func NewAppError(errType ErrorType, message string, original error) *AppError {
 		original: original,
 	}
 }
+
+// newSaveError はタスクデータの保存に失敗したエラーを AppError に変換します。
+// 他のプロセスがデータファイルを更新していた場合は ErrTypeConflict、git へのコミットだけに失敗した場合は
+// 保存は完了しているため ErrTypeGit、それ以外は ErrTypeIO になります。
+func newSaveError(message string, err error) *AppError {
+	if errors.Is(err, store.ErrConflict) {
+		return NewAppError(ErrTypeConflict, "Tasks were changed by another go-task process. Restart go-task to load the latest data.", err)
+	}
+	if errors.Is(err, store.ErrPassphraseRequired) || errors.Is(err, store.ErrWrongPassphrase) {
+		return newLoadError(ErrTypeIO, message, err)
+	}
+	if errors.Is(err, store.ErrGitCommit) {
+		return NewAppError(ErrTypeGit, "Tasks were saved, but the git commit failed.", err)
+	}
+	return NewAppError(ErrTypeIO, message, err)
+}
+
+// newLoadError はタスクデータの読み込みに失敗したエラーを AppError に変換します。
+// データファイルが新しいスキーマや不明なスキーマで書かれている場合は ErrTypeVersion、パスフレーズの問題は ErrTypePassphrase、
+// データファイルの破損は ErrTypeCorrupted、それ以外は errType になります。
+func newLoadError(errType ErrorType, message string, err error) *AppError {
+	switch {
+	case errors.Is(err, store.ErrUnsupportedVersion):
+		return NewAppError(ErrTypeVersion, "The data file was written by a newer version of go-task and cannot be opened.", err)
+	case errors.Is(err, store.ErrUnknownVersion):
+		return NewAppError(ErrTypeVersion, "The data file has an unknown schema version and cannot be opened.", err)
+	case errors.Is(err, store.ErrPassphraseRequired):
+		return NewAppError(ErrTypePassphrase, "The task file is encrypted. A passphrase is required.", err)
+	case errors.Is(err, store.ErrWrongPassphrase):
+		return NewAppError(ErrTypePassphrase, "Wrong passphrase.", err)
+	case errors.Is(err, store.ErrCorrupted):
+		return NewAppError(ErrTypeCorrupted, "The task file is corrupted and cannot be read.", err)
+	}
+	return NewAppError(errType, message, err)
+}
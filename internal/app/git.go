@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+
+	"go-task/internal/log"
+	"go-task/internal/store"
+)
+
+// gitHistory は git の履歴が有効な保存先を返します。有効でない場合は ErrTypeValidation を返します。
+func (a *App) gitHistory() (store.GitHistory, error) {
+	g, ok := a.storage.(store.GitHistory)
+	if !ok || !g.GitEnabled() {
+		return nil, NewAppError(ErrTypeValidation, "Git history is not enabled. Run 'go-task git init' first.", nil)
+	}
+	return g, nil
+}
+
+// GitEnabled はタスクデータの保存が git のコミットとして記録されるかを返します。
+func (a *App) GitEnabled() bool {
+	g, ok := a.storage.(store.GitHistory)
+	return ok && g.GitEnabled()
+}
+
+// InitGitHistory はデータディレクトリを git リポジトリにし、以降の保存をコミットとして記録するようにします。
+// 現在のタスクデータを保存してから、最初のコミットとして記録します。
+func (a *App) InitGitHistory() error {
+	g, ok := a.storage.(store.GitHistory)
+	if !ok {
+		return NewAppError(ErrTypeValidation, "This storage does not support git history.", nil)
+	}
+	if g.GitEnabled() {
+		return NewAppError(ErrTypeValidation, "Git history is already enabled.", nil)
+	}
+	if err := a.storage.Save(a.Tasks); err != nil {
+		log.Error("Failed to save tasks before enabling git history:", err)
+		return newSaveError("Failed to save tasks before enabling git history.", err)
+	}
+	if err := g.InitGit(); err != nil {
+		log.Error("Failed to initialize git history:", err)
+		return NewAppError(ErrTypeIO, "Failed to initialize the git repository. Make sure git is installed.", err)
+	}
+	return nil
+}
+
+// GitLog はタスクデータのコミットを新しい順に最大 limit 件返します。limit が 0 以下の場合はすべて返します。
+func (a *App) GitLog(limit int) ([]store.GitCommit, error) {
+	g, err := a.gitHistory()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := g.GitLog(limit)
+	if err != nil {
+		log.Error("Failed to read git history:", err)
+		return nil, NewAppError(ErrTypeIO, "Failed to read the git history.", err)
+	}
+	return commits, nil
+}
+
+// RevertToCommit はタスクと設定を指定されたコミットの時点の内容に戻します。
+// バックアップからの復元と同じく、戻す前に現在のタスクデータのバックアップを作成し、変更履歴と取り消し用のスタックは引き継ぎます。
+// 戻した内容は新しいコミットとして記録されるため、過去のコミットは失われません。
+func (a *App) RevertToCommit(rev string) error {
+	g, err := a.gitHistory()
+	if err != nil {
+		return err
+	}
+	tasks, err := g.LoadGitCommit(rev)
+	if err != nil {
+		log.Error("Failed to load tasks from git commit:", err)
+		return newLoadError(ErrTypeNotFound, fmt.Sprintf("Failed to load tasks from commit %s.", rev), err)
+	}
+	if err := store.VerifyTasks(tasks); err != nil {
+		log.Error("Tasks in git commit failed verification:", err)
+		return NewAppError(ErrTypeValidation, fmt.Sprintf("Tasks in commit %s are invalid.", rev), err)
+	}
+	return a.restoreFrom(tasks, SourceRevert)
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"time"
+
+	"go-task/internal/task"
+)
+
+// 変更履歴に記録する操作の種類です。
+const (
+	SourceAdd     = "add"
+	SourceUpdate  = "update"
+	SourceDelete  = "delete"
+	SourceImport  = "import"
+	SourceRestore = "restore"
+	SourceRevert  = "revert"
+	SourceSync    = "sync"
+	SourceResolve = "resolve"
+)
+
+// maxHistoryEntries は保持する変更履歴の最大件数です。超過分は古いものから破棄します。
+const maxHistoryEntries = 10000
+
+// recordHistory は変更前後のタスクを比較し、差分を変更履歴に追加します。
+func (a *App) recordHistory(source string, before, after *task.Task) {
+	a.updateTombstones(before, after)
+	entries := task.DiffTask(before, after, time.Now(), source)
+	if len(entries) == 0 {
+		return
+	}
+	a.Tasks.History = append(a.Tasks.History, entries...)
+	u := a.unsaved()
+	u.history = append(u.history, entries...)
+	if over := len(a.Tasks.History) - maxHistoryEntries; over > 0 {
+		a.Tasks.History = append([]task.HistoryEntry(nil), a.Tasks.History[over:]...)
+	}
+}
+
+// GetTaskHistory は指定されたIDのタスクの変更履歴を古い順に返します。
+// 削除済みのタスクの履歴も返します。
+func (a *App) GetTaskHistory(id string) []task.HistoryEntry {
+	var entries []task.HistoryEntry
+	for _, e := range a.Tasks.History {
+		if e.TaskID == id {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
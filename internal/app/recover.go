@@ -0,0 +1,53 @@
+package app
+
+import (
+	"go-task/internal/log"
+	"go-task/internal/store"
+)
+
+// currentRecoverer は NewApp が開くタスクファイルの保存先を返します。
+// 破損したタスクファイルは App を作成できないため、App を経由せずに保存先を直接開きます。
+func currentRecoverer() (store.Recoverer, error) {
+	if dir, ok := findLocalDir(); ok {
+		return store.NewFileStore(dir), nil
+	}
+	name, err := openWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	s, err := store.WorkspaceStore(name)
+	if err != nil {
+		return nil, newWorkspaceError("Failed to open workspace.", err)
+	}
+	return s, nil
+}
+
+// PlanRecovery は破損したタスクファイルをバックアップと読み込めたタスクから復旧した場合の内容を、ファイルを変更せずに返します。
+func PlanRecovery() (*store.RecoveryReport, error) {
+	r, err := currentRecoverer()
+	if err != nil {
+		return nil, err
+	}
+	report, err := r.PlanRecovery()
+	if err != nil {
+		log.Error("Failed to plan recovery:", err)
+		return nil, newLoadError(ErrTypeIO, "Failed to read the corrupted task file.", err)
+	}
+	return report, nil
+}
+
+// Recover は破損したタスクファイルを退避し、読み込めたタスクから復旧します。
+// useBackup が true の場合は最新の有効なバックアップも使用します。復旧後は NewApp で読み込み直してください。
+func Recover(useBackup bool) (*store.RecoveryReport, error) {
+	r, err := currentRecoverer()
+	if err != nil {
+		return nil, err
+	}
+	report, err := r.Recover(useBackup)
+	if err != nil {
+		log.Error("Failed to recover the task file:", err)
+		return nil, newLoadError(ErrTypeIO, "Failed to recover the task file.", err)
+	}
+	log.Info("Recovered the corrupted task file; quarantined the original at", report.QuarantinePath)
+	return report, nil
+}
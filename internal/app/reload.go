@@ -0,0 +1,242 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"go-task/internal/log"
+	"go-task/internal/store"
+	"go-task/internal/task"
+)
+
+// unsavedChanges は最後の保存以降に行い、まだ保存していないタスクの変更の記録です。
+// Reload で他のプロセスが保存したタスクデータとマージするために使用します。
+type unsavedChanges struct {
+	// revision は変更を行った時点の Tasks.Revision です。保存するとリビジョンが進むため、一致しない記録は保存済みです。
+	revision int64
+	// base はタスクID → 最後に保存された時点の内容です。その時点で存在しなかったタスクは nil です。
+	base map[string]*task.Task
+	// history は記録した変更履歴です。
+	history []task.HistoryEntry
+}
+
+// unsaved は現在のリビジョンの未保存の変更の記録を返します。保存済みの古い記録は破棄します。
+func (a *App) unsaved() *unsavedChanges {
+	if a.pending == nil || a.pending.revision != a.Tasks.Revision {
+		a.pending = &unsavedChanges{revision: a.Tasks.Revision, base: make(map[string]*task.Task)}
+	}
+	return a.pending
+}
+
+// markUnsaved は変更前のスナップショットを保存前の内容として記録します。既に記録されているタスクは最初の内容を保ちます。
+func (a *App) markUnsaved(before []task.TaskSnapshot) {
+	a.edits++
+	u := a.unsaved()
+	for _, s := range before {
+		if _, ok := u.base[s.ID]; !ok {
+			u.base[s.ID] = s.Task
+		}
+	}
+}
+
+// HasUnsavedChanges は保存していないタスクの変更があるかを返します。
+func (a *App) HasUnsavedChanges() bool {
+	return a.pending != nil && a.pending.revision == a.Tasks.Revision && len(a.pending.base) > 0
+}
+
+// ExternalChanges は最後の読み込みまたは保存の後に、他のプロセスや同期ツールがタスクデータを変更したかを返します。
+// ファイルの更新日時とサイズだけを確認するため、定期的に呼び出せます。保存先が変更の検出に対応していない場合は常に false です。
+func (a *App) ExternalChanges() bool {
+	w, ok := a.storage.(store.Watcher)
+	return ok && w.Modified()
+}
+
+// ReloadResult は Reload で他のプロセスが保存したタスクデータを読み込んだ結果です。
+type ReloadResult struct {
+	// Changed は読み込んだタスクデータが、このプロセスが最後に読み込んだ、または保存したものから変わっていたかです。
+	Changed bool
+	// Kept は保存していないローカルの変更を、読み込んだタスクデータの上に適用し直したタスクのIDです。
+	Kept []string
+	// Conflicts は Kept のうち、他のプロセスも変更していたタスクのIDです。
+	// ローカルの変更が優先されるため、保存すると他のプロセスによるそのタスクの変更は上書きされます。
+	Conflicts []string
+	// Discarded は読み込みの間にタスクが変更されたため、読み込んだタスクデータを適用しなかったかです (ApplyReload を参照)。
+	Discarded bool
+}
+
+// Reload は保存先からタスクデータを読み込み直し、保存していないローカルのタスクの変更をその上に適用します。
+// 設定とタグの登録は読み込んだ内容を使用します。ローカルの変更が残る場合、取り消し用のスタックはローカルのものを引き継ぎ、
+// 自動保存が有効であればマージした結果を保存します。TUI のように読み込みを別の goroutine で行う場合は PrepareReload を使用します。
+func (a *App) Reload() (*ReloadResult, error) {
+	reloaded, err := a.PrepareReload()()
+	if err != nil {
+		return nil, err
+	}
+	return a.ApplyReload(reloaded)
+}
+
+// ReloadedTasks は PrepareReload で読み込み、保存していないローカルの変更とマージしたタスクデータです。
+type ReloadedTasks struct {
+	tasks  *task.Tasks
+	result *ReloadResult
+	kept   *unsavedChanges
+	// adopt は読み込んだタスクデータを保存先に読み込んだものとして記録します。
+	adopt func()
+	// revision と edits は PrepareReload を呼び出した時点の Tasks.Revision と変更の回数です。
+	revision int64
+	edits    int
+}
+
+// PrepareReload は Reload のうち、タスクデータの読み込み (復号を含む) と保存していないローカルの変更のマージを行う関数を返します。
+// 返す関数は App を参照も変更もしないため、別の goroutine で実行できます。結果は ApplyReload で App に適用します。
+func (a *App) PrepareReload() func() (*ReloadedTasks, error) {
+	// マージに必要なローカルの状態は、ここで写しを取っておく
+	var pending *unsavedChanges
+	mine := make(map[string]*task.Task)
+	if a.HasUnsavedChanges() {
+		pending = &unsavedChanges{base: make(map[string]*task.Task, len(a.pending.base)),
+			history: append([]task.HistoryEntry(nil), a.pending.history...)}
+		for id, base := range a.pending.base {
+			pending.base[id] = base
+			if i, ok := a.taskPosition(id); ok {
+				t := a.Tasks.Tasks[i].Clone()
+				mine[id] = &t
+			}
+		}
+	}
+	revision, edits, storage := a.Tasks.Revision, a.edits, a.storage
+
+	return func() (*ReloadedTasks, error) {
+		r := &ReloadedTasks{revision: revision, edits: edits}
+		var err error
+		if w, ok := storage.(store.Watcher); ok {
+			var snap *store.Snapshot
+			if snap, err = w.Peek(); err == nil {
+				r.tasks, r.adopt = snap.Tasks, func() { w.Adopt(snap) }
+			}
+		} else {
+			r.tasks, err = storage.Load()
+		}
+		if err != nil {
+			log.Error("Failed to reload tasks:", err)
+			return nil, newLoadError(ErrTypeIO, "Failed to reload tasks.", err)
+		}
+		r.result = &ReloadResult{Changed: r.tasks.Revision != revision}
+		if pending != nil {
+			r.kept = mergeUnsaved(pending, mine, r.tasks, r.result)
+		}
+		return r, nil
+	}
+}
+
+// ApplyReload は PrepareReload で読み込んだタスクデータを App に適用し、自動保存が有効であればマージした結果を保存します。
+// 読み込みの間にタスクを変更または保存していた場合は適用せず、Discarded を true にした結果を返します。
+// 保存先の変更は残ったままのため、次の確認 (ExternalChanges) で読み込み直せます。
+func (a *App) ApplyReload(r *ReloadedTasks) (*ReloadResult, error) {
+	if r.revision != a.Tasks.Revision || r.edits != a.edits {
+		return &ReloadResult{Discarded: true}, nil
+	}
+	remote, result, kept := r.tasks, r.result, r.kept
+	if len(result.Kept) > 0 {
+		remote.UndoStack, remote.RedoStack = a.Tasks.UndoStack, a.Tasks.RedoStack
+		remote.History = append(remote.History, kept.history...)
+		if over := len(remote.History) - maxHistoryEntries; over > 0 {
+			remote.History = append([]task.HistoryEntry(nil), remote.History[over:]...)
+		}
+	}
+	a.Tasks = remote
+	a.invalidateIndex()
+	a.pending = nil
+	if r.adopt != nil {
+		r.adopt()
+	}
+	if len(result.Kept) == 0 {
+		return result, nil
+	}
+
+	// 次の読み込み直しでは、今回読み込んだ内容を保存前の内容としてマージする
+	kept.revision = a.Tasks.Revision
+	a.pending = kept
+	if len(result.Conflicts) > 0 {
+		log.Info("Kept unsaved changes to tasks also changed by another process:", result.Conflicts)
+	}
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save merged tasks:", err)
+			return result, newSaveError("Failed to auto-save merged tasks.", err)
+		}
+	}
+	return result, nil
+}
+
+// mergeUnsaved は保存していないローカルのタスクの変更 pending を remote に適用し、適用したタスクと競合を result に記録します。
+// current はタスクID → 変更したタスクの現在の内容で、削除したタスクは含みません。
+// 適用した変更について、remote の内容を保存前の内容とした新しい記録を返します。
+func mergeUnsaved(pending *unsavedChanges, current map[string]*task.Task, remote *task.Tasks, result *ReloadResult) *unsavedChanges {
+	ids := make([]string, 0, len(pending.base))
+	for id := range pending.base {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	theirsIndex := newRepository(remote.Tasks)
+	kept := &unsavedChanges{base: make(map[string]*task.Task), history: pending.history}
+	removed := make(idSet)
+	for _, id := range ids {
+		mine := current[id]
+		var theirs *task.Task
+		i, inRemote := theirsIndex.byID[id]
+		if inRemote {
+			theirs = &remote.Tasks[i]
+		}
+		base := pending.base[id]
+		// 変更後に元に戻したタスクは、他のプロセスの内容をそのまま使う
+		if sameContent(base, mine) {
+			continue
+		}
+		if !sameContent(base, theirs) && !sameContent(mine, theirs) {
+			result.Conflicts = append(result.Conflicts, id)
+		}
+		result.Kept = append(result.Kept, id)
+		if theirs != nil {
+			t := theirs.Clone()
+			kept.base[id] = &t
+		} else {
+			kept.base[id] = nil
+		}
+
+		switch {
+		case mine == nil && inRemote:
+			removed[id] = struct{}{}
+		case mine != nil && inRemote:
+			remote.Tasks[i] = mine.Clone()
+		case mine != nil:
+			remote.Tasks = append(remote.Tasks, mine.Clone())
+		}
+	}
+	if len(removed) > 0 {
+		rest := remote.Tasks[:0]
+		for _, t := range remote.Tasks {
+			if _, ok := removed[t.ID]; !ok {
+				rest = append(rest, t)
+			}
+		}
+		remote.Tasks = rest
+	}
+	return kept
+}
+
+// sameContent は2つのタスクの内容が同じかを返します。変更して元に戻したタスクを変更なしとみなすため、更新日時は比較しません。
+// 読み込んだタスクとメモリ上のタスクでは日時の内部表現が異なるため、JSON 形式で比較します。
+func sameContent(a, b *task.Task) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ca, cb := *a, *b
+	ca.UpdatedAt, cb.UpdatedAt = time.Time{}, time.Time{}
+	ja, errA := json.Marshal(ca)
+	jb, errB := json.Marshal(cb)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"sort"
+	"strings"
+
+	"go-task/internal/task"
+)
+
+// idSet はタスクIDの集合です。
+type idSet map[string]struct{}
+
+// repository は a.Tasks.Tasks の索引です。ID、状態、優先度、タグからタスクを一覧の走査なしに引けるようにします。
+// タスク一覧そのものは a.Tasks.Tasks が保持し、索引は App の変更操作のたびに一緒に更新されます。
+type repository struct {
+	byID       map[string]int // タスクID → a.Tasks.Tasks 内の位置
+	byStatus   map[task.Status]idSet
+	byPriority map[task.Priority]idSet
+	byTag      map[string]idSet // 前後の空白を除いたタグ → そのタグを持つタスク
+	// base と n は索引を作成した時点のスライスの先頭要素と長さです。
+	// App の外でスライスが置き換えられた場合に、索引を作り直すために使用します。
+	base *task.Task
+	n    int
+}
+
+// newRepository は tasks の索引を作成します。
+func newRepository(tasks []task.Task) *repository {
+	r := &repository{
+		byID:       make(map[string]int, len(tasks)),
+		byStatus:   make(map[task.Status]idSet),
+		byPriority: make(map[task.Priority]idSet),
+		byTag:      make(map[string]idSet),
+	}
+	for i := range tasks {
+		r.addAttrs(&tasks[i])
+	}
+	r.reposition(tasks, 0)
+	return r
+}
+
+// matches は索引が tasks に対して作成されたものかを返します。
+func (r *repository) matches(tasks []task.Task) bool {
+	if len(tasks) != r.n {
+		return false
+	}
+	return len(tasks) == 0 || &tasks[0] == r.base
+}
+
+// reposition は tasks の from 以降のタスクの位置を索引に記録し直します。
+func (r *repository) reposition(tasks []task.Task, from int) {
+	for i := from; i < len(tasks); i++ {
+		r.byID[tasks[i].ID] = i
+	}
+	r.n = len(tasks)
+	r.base = nil
+	if len(tasks) > 0 {
+		r.base = &tasks[0]
+	}
+}
+
+// addAttrs はタスクの状態、優先度、タグを索引に追加します。
+func (r *repository) addAttrs(t *task.Task) {
+	addID(r.byStatus, t.Status, t.ID)
+	addID(r.byPriority, t.Priority, t.ID)
+	for _, tag := range t.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			addID(r.byTag, tag, t.ID)
+		}
+	}
+}
+
+// removeAttrs はタスクの状態、優先度、タグを索引から取り除きます。
+func (r *repository) removeAttrs(t *task.Task) {
+	removeID(r.byStatus, t.Status, t.ID)
+	removeID(r.byPriority, t.Priority, t.ID)
+	for _, tag := range t.Tags {
+		removeID(r.byTag, strings.TrimSpace(tag), t.ID)
+	}
+}
+
+// addID は key の集合に id を追加します。
+func addID[K comparable](index map[K]idSet, key K, id string) {
+	set, ok := index[key]
+	if !ok {
+		set = make(idSet)
+		index[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+// removeID は key の集合から id を取り除きます。空になった集合は削除し、タグの一覧に残らないようにします。
+func removeID[K comparable](index map[K]idSet, key K, id string) {
+	set, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(set, id)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
+
+// repo は現在のタスク一覧の索引を返します。索引がない場合や一覧が置き換えられている場合は作り直します。
+func (a *App) repo() *repository {
+	if a.index == nil || !a.index.matches(a.Tasks.Tasks) {
+		a.index = newRepository(a.Tasks.Tasks)
+	}
+	return a.index
+}
+
+// invalidateIndex は索引を破棄します。一覧全体を書き換える操作の後に呼び出し、次の参照時に作り直させます。
+func (a *App) invalidateIndex() {
+	a.index = nil
+}
+
+// taskPosition は指定されたIDのタスクの a.Tasks.Tasks 内の位置を返します。
+func (a *App) taskPosition(id string) (int, bool) {
+	i, ok := a.repo().byID[id]
+	return i, ok
+}
+
+// appendTasks はタスクを一覧の末尾に追加し、索引に登録します。
+func (a *App) appendTasks(tasks ...task.Task) {
+	r := a.repo()
+	from := len(a.Tasks.Tasks)
+	a.Tasks.Tasks = append(a.Tasks.Tasks, tasks...)
+	for i := from; i < len(a.Tasks.Tasks); i++ {
+		r.addAttrs(&a.Tasks.Tasks[i])
+	}
+	r.reposition(a.Tasks.Tasks, from)
+}
+
+// replaceTask は指定された位置のタスクを置き換え、索引を更新します。
+func (a *App) replaceTask(i int, t task.Task) {
+	r := a.repo()
+	r.removeAttrs(&a.Tasks.Tasks[i])
+	delete(r.byID, a.Tasks.Tasks[i].ID)
+	a.Tasks.Tasks[i] = t
+	r.addAttrs(&a.Tasks.Tasks[i])
+	r.byID[t.ID] = i
+}
+
+// removeTasks は指定されたIDのタスクを一覧の順序を保ったまま取り除き、索引を更新します。
+// 索引の位置の更新は、最初に取り除いたタスク以降だけで済ませます。
+func (a *App) removeTasks(ids idSet) {
+	r := a.repo()
+	first := len(a.Tasks.Tasks)
+	for id := range ids {
+		if i, ok := r.byID[id]; ok {
+			if i < first {
+				first = i
+			}
+			r.removeAttrs(&a.Tasks.Tasks[i])
+			delete(r.byID, id)
+		}
+	}
+	if first == len(a.Tasks.Tasks) {
+		return
+	}
+
+	kept := a.Tasks.Tasks[:first]
+	for _, t := range a.Tasks.Tasks[first:] {
+		if _, ok := ids[t.ID]; !ok {
+			kept = append(kept, t)
+		}
+	}
+	// 末尾に残った要素を消去し、削除したタスクのデータを参照し続けないようにする
+	clear(a.Tasks.Tasks[len(kept):])
+	a.Tasks.Tasks = kept
+	r.reposition(a.Tasks.Tasks, first)
+}
+
+// selectTasks は候補の集合に含まれ、match を満たすタスクを一覧の順序で返します。
+// 候補が一覧の大部分を占める場合は、位置の並べ替えより一覧の走査の方が速いため走査で絞り込みます。
+func (a *App) selectTasks(candidates []idSet, match func(t *task.Task) bool) []task.Task {
+	r := a.repo()
+	total := 0
+	for _, set := range candidates {
+		total += len(set)
+	}
+
+	var selected []task.Task
+	if total*4 >= len(a.Tasks.Tasks) {
+		for i := range a.Tasks.Tasks {
+			if match(&a.Tasks.Tasks[i]) {
+				selected = append(selected, a.Tasks.Tasks[i])
+			}
+		}
+		return selected
+	}
+
+	positions := make([]int, 0, total)
+	seen := make(map[int]bool, total)
+	for _, set := range candidates {
+		for id := range set {
+			if i, ok := r.byID[id]; ok && !seen[i] {
+				seen[i] = true
+				positions = append(positions, i)
+			}
+		}
+	}
+	sort.Ints(positions)
+	for _, i := range positions {
+		if match(&a.Tasks.Tasks[i]) {
+			selected = append(selected, a.Tasks.Tasks[i])
+		}
+	}
+	return selected
+}
+
+// tagCandidates はフィルタのタグ (子孫のタグを含む) を持つタスクの集合を返します。
+func (r *repository) tagCandidates(filter string) []idSet {
+	var sets []idSet
+	for tag, set := range r.byTag {
+		if task.TagMatches(tag, filter) {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
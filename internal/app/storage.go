@@ -0,0 +1,43 @@
+package app
+
+import (
+	"go-task/internal/config"
+	"go-task/internal/log"
+	"go-task/internal/store"
+)
+
+// loadStorageSettings は設定ファイルの保存形式の設定を返します。読み込めない場合は既定の設定を使用します。
+func loadStorageSettings() config.StorageSettings {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Error("Failed to load config, using default storage settings:", err)
+		return config.DefaultStorageSettings()
+	}
+	return cfg.Settings.Storage
+}
+
+// openStore は dir のタスクデータを設定ファイルの保存形式で開きます。
+func openStore(dir string) (store.Store, error) {
+	s, err := store.OpenStore(dir, loadStorageSettings().Options())
+	if err != nil {
+		log.Error("Failed to open storage:", err)
+		return nil, NewAppError(ErrTypeValidation, "Invalid storage format in the config file.", err)
+	}
+	return s, nil
+}
+
+// Compact はタスクデータ全体を tasks.json に書き出し、ジャーナル形式で追記された変更 (tasks.journal) を削除します。
+// json 形式の保存先では通常の保存と同じです。
+func (a *App) Compact() error {
+	var err error
+	if c, ok := a.storage.(store.Compacter); ok {
+		err = c.Compact(a.Tasks)
+	} else {
+		err = a.storage.Save(a.Tasks)
+	}
+	if err != nil {
+		log.Error("Failed to compact tasks:", err)
+		return newSaveError("Failed to compact the task file.", err)
+	}
+	return nil
+}
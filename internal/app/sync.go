@@ -0,0 +1,386 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go-task/internal/log"
+	"go-task/internal/store"
+	"go-task/internal/task"
+)
+
+// maxTombstones は保持する削除済みタスクの記録の最大件数です。超過分は古いものから破棄します。
+const maxTombstones = 10000
+
+// updateTombstones はタスクの削除を記録し、削除したタスクを元に戻した場合は記録を取り除きます。
+// 変更履歴と同じく、すべての変更の記録 (recordHistory) から呼び出されます。
+func (a *App) updateTombstones(before, after *task.Task) {
+	switch {
+	case before != nil && after == nil:
+		for _, ts := range a.Tasks.Tombstones {
+			if ts.ID == before.ID {
+				return
+			}
+		}
+		a.Tasks.Tombstones = append(a.Tasks.Tombstones, task.Tombstone{ID: before.ID, DeletedAt: time.Now()})
+		if over := len(a.Tasks.Tombstones) - maxTombstones; over > 0 {
+			a.Tasks.Tombstones = append([]task.Tombstone(nil), a.Tasks.Tombstones[over:]...)
+		}
+	case before == nil && after != nil:
+		for i, ts := range a.Tasks.Tombstones {
+			if ts.ID == after.ID {
+				// 保存先と配列を共有しないよう新しいスライスにする
+				a.Tasks.Tombstones = append(a.Tasks.Tombstones[:i:i], a.Tasks.Tombstones[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SyncResult は Sync の結果です。
+type SyncResult struct {
+	// Remote は同期先のディレクトリの絶対パスです。
+	Remote string
+	// Added、Updated、Deleted は同期によって追加、変更、削除されたローカルのタスクの数です。
+	Added, Updated, Deleted int
+	// RemoteChanged は同期先で追加、変更、削除されたタスクの数です。
+	RemoteChanged int
+	// Conflicts は今回の同期で検出した競合です。ResolveConflict で解決するまで Conflicts にも残ります。
+	Conflicts []task.SyncConflict
+}
+
+// Sync はローカルのタスクデータと、別のディレクトリ (同期フォルダなど) にあるタスクデータを3者間マージし、両方に書き込みます。
+// 共通の祖先として前回の同期の結果を同期先ごとに保存し、一方だけで変更されたフィールドはその値を、
+// 両方で異なる値に変更されたフィールドは更新日時が新しい側の値を使用して競合として記録します。
+// 削除したタスクは削除の記録 (tombstone) によって他方から復活しません。
+// ローカルの変更は1回の操作として記録されるため、取り消せます。同期先のディレクトリにデータファイルがない場合は作成します。
+// ローカルのタスクデータが暗号化されている場合は同期先も同じパスフレーズで暗号化し、暗号化の状態が異なるファイルとは同期しません。
+// path には同期先のディレクトリか、そのデータファイル (tasks.json) を指定します。それ以外のファイルはエラーになります。
+func (a *App) Sync(path string) (*SyncResult, error) {
+	bs, ok := a.storage.(store.SyncBaseStore)
+	if !ok {
+		return nil, NewAppError(ErrTypeValidation, "This storage does not support sync.", nil)
+	}
+	remoteDir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Invalid sync path %s.", path), err)
+	}
+	// データファイルのパスを指定された場合はそのディレクトリと同期する。別の名前のファイルを同期先にはできない
+	if info, err := os.Stat(remoteDir); (err == nil && !info.IsDir()) || (os.IsNotExist(err) && filepath.Base(remoteDir) == store.DataFileName) {
+		if filepath.Base(remoteDir) != store.DataFileName {
+			return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Cannot sync with %s. Specify a directory or its %s file.", path, store.DataFileName), nil)
+		}
+		remoteDir = filepath.Dir(remoteDir)
+	}
+	if localDir, err := filepath.Abs(a.storage.Dir()); err == nil && localDir == remoteDir {
+		return nil, NewAppError(ErrTypeValidation, "Cannot sync the task file with itself.", nil)
+	}
+
+	// 暗号化したタスクを平文の同期先に書き出さないよう、同期先の暗号化の状態をローカルに合わせる
+	remoteStore := store.NewFileStore(remoteDir)
+	localEncrypted, err := a.Encrypted()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(remoteStore.DataFilePath()); os.IsNotExist(err) {
+		remoteStore.SetEncryptNew(localEncrypted)
+	} else if remoteEncrypted, err := remoteStore.Encrypted(); err != nil {
+		log.Error("Failed to check encryption of remote tasks:", err)
+		return nil, NewAppError(ErrTypeIO, fmt.Sprintf("Failed to read tasks from %s.", remoteDir), err)
+	} else if remoteEncrypted != localEncrypted {
+		return nil, NewAppError(ErrTypeValidation, fmt.Sprintf("Cannot sync with %s because only one of the task files is encrypted. Encrypt or decrypt both with the same passphrase first.", remoteDir), nil)
+	}
+	remote, err := remoteStore.Load()
+	if err != nil {
+		log.Error("Failed to load remote tasks:", err)
+		return nil, newLoadError(ErrTypeIO, fmt.Sprintf("Failed to load tasks from %s.", remoteDir), err)
+	}
+	base, err := bs.LoadSyncBase(remoteDir)
+	if err != nil {
+		log.Error("Failed to load sync base:", err)
+		return nil, newLoadError(ErrTypeIO, "Failed to load the state of the last sync.", err)
+	}
+	if err := a.backupBeforeDestructive("sync"); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	merged := mergeSync(base, a.Tasks, remote, now)
+	result := &SyncResult{Remote: remoteDir, Conflicts: merged.conflicts}
+
+	// ローカルに適用する
+	ids := make([]string, 0, len(a.Tasks.Tasks)+len(merged.tasks))
+	seen := make(idSet, cap(ids))
+	for _, tasks := range [][]task.Task{a.Tasks.Tasks, merged.tasks} {
+		for _, t := range tasks {
+			if _, ok := seen[t.ID]; !ok {
+				seen[t.ID] = struct{}{}
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+	before := a.snapshot(ids)
+	a.Tasks.Tasks = cloneTasks(merged.tasks)
+	a.invalidateIndex()
+	a.Tasks.UpdatedAt = now
+	a.recordOperation(SourceSync, before)
+	a.Tasks.Tombstones = append([]task.Tombstone(nil), merged.tombstones...)
+	a.Tasks.Conflicts = addConflicts(a.Tasks.Conflicts, merged.conflicts)
+	for _, s := range before {
+		i, ok := a.taskPosition(s.ID)
+		switch {
+		case s.Task == nil:
+			result.Added++
+		case !ok:
+			result.Deleted++
+		case !sameContent(s.Task, &a.Tasks.Tasks[i]):
+			result.Updated++
+		}
+	}
+	if err := a.storage.Save(a.Tasks); err != nil {
+		log.Error("Failed to save tasks after sync:", err)
+		return nil, newSaveError("Failed to save tasks after sync.", err)
+	}
+
+	// 同期先に書き込む。同期先の設定、変更履歴、取り消し用のスタックはそのまま残す
+	result.RemoteChanged = countChanges(remote.Tasks, merged.tasks)
+	remote.Tasks = cloneTasks(merged.tasks)
+	remote.Tombstones = append([]task.Tombstone(nil), merged.tombstones...)
+	if err := remoteStore.Save(remote); err != nil {
+		log.Error("Failed to save remote tasks after sync:", err)
+		if errors.Is(err, store.ErrConflict) {
+			return nil, NewAppError(ErrTypeConflict, "The remote task file was changed during sync. Run sync again.", err)
+		}
+		return nil, newSaveError(fmt.Sprintf("Failed to write tasks to %s.", remoteDir), err)
+	}
+
+	err = bs.SaveSyncBase(remoteDir, &task.Tasks{Version: store.CurrentVersion, Tasks: merged.tasks, Tombstones: merged.tombstones})
+	if err != nil {
+		// 次の同期は前回の祖先との比較になり、両方で変更されたフィールドが競合として残るだけで内容は失われない
+		log.Error("Failed to save sync base:", err)
+		return result, NewAppError(ErrTypeIO, "Synced, but failed to save the state of the sync.", err)
+	}
+	return result, nil
+}
+
+// syncMerge は mergeSync の結果です。
+type syncMerge struct {
+	tasks      []task.Task
+	tombstones []task.Tombstone
+	conflicts  []task.SyncConflict
+}
+
+// mergeSync は共通の祖先 base (まだ同期していない場合は nil) から、ローカルとリモートのタスクを3者間マージします。
+// 並び順はローカルのタスクの後に、リモートにだけあるタスクをリモートの順に続けます。
+func mergeSync(base, local, remote *task.Tasks, now time.Time) *syncMerge {
+	baseByID := make(map[string]*task.Task)
+	if base != nil {
+		for i := range base.Tasks {
+			baseByID[base.Tasks[i].ID] = &base.Tasks[i]
+		}
+	}
+	localIndex, remoteIndex := newRepository(local.Tasks), newRepository(remote.Tasks)
+	tombstones := make(map[string]task.Tombstone)
+	localDeleted, remoteDeleted := make(map[string]*task.Tombstone), make(map[string]*task.Tombstone)
+	for _, side := range []struct {
+		tombstones []task.Tombstone
+		deleted    map[string]*task.Tombstone
+	}{{local.Tombstones, localDeleted}, {remote.Tombstones, remoteDeleted}} {
+		for i, ts := range side.tombstones {
+			side.deleted[ts.ID] = &side.tombstones[i]
+			if prev, ok := tombstones[ts.ID]; !ok || ts.DeletedAt.After(prev.DeletedAt) {
+				tombstones[ts.ID] = ts
+			}
+		}
+	}
+
+	m := &syncMerge{}
+	// survive は一方にだけあるタスク t を残すかを返します。もう一方で削除されていて t が変更されていない場合は削除し、
+	// t も変更されていた場合は残して競合にします。
+	survive := func(t, b *task.Task, deleted *task.Tombstone) (keep, conflict bool) {
+		if b == nil && deleted == nil {
+			return true, false // この側で追加されたタスク
+		}
+		unchanged := b != nil && len(task.DiffTask(b, t, now, "")) == 0
+		if unchanged || (b == nil && !t.UpdatedAt.After(deleted.DeletedAt)) {
+			if _, ok := tombstones[t.ID]; !ok {
+				tombstones[t.ID] = task.Tombstone{ID: t.ID, DeletedAt: now}
+			}
+			return false, false
+		}
+		return true, true
+	}
+	for _, t := range local.Tasks {
+		l, b := t, baseByID[t.ID]
+		if i, ok := remoteIndex.byID[t.ID]; ok {
+			r := remote.Tasks[i]
+			merged, fields := task.MergeTask(b, &l, &r)
+			m.tasks = append(m.tasks, merged)
+			if len(fields) > 0 {
+				m.conflicts = append(m.conflicts, newConflict(&l, &r, fields, now))
+			}
+			continue
+		}
+		if keep, conflict := survive(&l, b, remoteDeleted[t.ID]); keep {
+			m.tasks = append(m.tasks, l.Clone())
+			if conflict {
+				m.conflicts = append(m.conflicts, newConflict(&l, nil, nil, now))
+			}
+		}
+	}
+	for _, t := range remote.Tasks {
+		if _, ok := localIndex.byID[t.ID]; ok {
+			continue
+		}
+		r := t
+		if keep, conflict := survive(&r, baseByID[t.ID], localDeleted[t.ID]); keep {
+			m.tasks = append(m.tasks, r.Clone())
+			if conflict {
+				m.conflicts = append(m.conflicts, newConflict(nil, &r, nil, now))
+			}
+		}
+	}
+
+	// 残ったタスクの記録は取り除き、古い順に並べる
+	for _, t := range m.tasks {
+		delete(tombstones, t.ID)
+	}
+	for _, ts := range tombstones {
+		m.tombstones = append(m.tombstones, ts)
+	}
+	sort.Slice(m.tombstones, func(i, j int) bool {
+		if !m.tombstones[i].DeletedAt.Equal(m.tombstones[j].DeletedAt) {
+			return m.tombstones[i].DeletedAt.Before(m.tombstones[j].DeletedAt)
+		}
+		return m.tombstones[i].ID < m.tombstones[j].ID
+	})
+	if over := len(m.tombstones) - maxTombstones; over > 0 {
+		m.tombstones = m.tombstones[over:]
+	}
+	return m
+}
+
+// newConflict はローカルとリモートのタスク (削除された側は nil) の写しを持つ競合を返します。
+func newConflict(local, remote *task.Task, fields []string, now time.Time) task.SyncConflict {
+	c := task.SyncConflict{Fields: fields, DetectedAt: now}
+	if local != nil {
+		l := local.Clone()
+		c.TaskID, c.Local = l.ID, &l
+	}
+	if remote != nil {
+		r := remote.Clone()
+		c.TaskID, c.Remote = r.ID, &r
+	}
+	return c
+}
+
+// addConflicts は未解決の競合に新しい競合を加えます。同じタスクの古い競合は新しい競合で置き換えます。
+func addConflicts(current, added []task.SyncConflict) []task.SyncConflict {
+	replaced := make(idSet, len(added))
+	for _, c := range added {
+		replaced[c.TaskID] = struct{}{}
+	}
+	var conflicts []task.SyncConflict
+	for _, c := range current {
+		if _, ok := replaced[c.TaskID]; !ok {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return append(conflicts, added...)
+}
+
+// cloneTasks はタスクのスライスのディープコピーを返します。
+func cloneTasks(tasks []task.Task) []task.Task {
+	c := make([]task.Task, len(tasks))
+	for i, t := range tasks {
+		c[i] = t.Clone()
+	}
+	return c
+}
+
+// countChanges は before から after で追加、変更、削除されたタスクの数を返します。
+func countChanges(before, after []task.Task) int {
+	index := newRepository(before)
+	n := len(before)
+	for i := range after {
+		j, ok := index.byID[after[i].ID]
+		if !ok {
+			n++
+			continue
+		}
+		n-- // before にあったタスクは削除ではない
+		if !sameContent(&before[j], &after[i]) {
+			n++
+		}
+	}
+	return n
+}
+
+// Conflicts は同期で検出した未解決の競合を返します。
+func (a *App) Conflicts() []task.SyncConflict {
+	return a.Tasks.Conflicts
+}
+
+// ResolveConflict は同期の競合を、ローカル (useRemote が false) またはリモートの内容を選んで解決します。
+// フィールドの競合では競合したフィールドだけを選んだ側の値にし、削除と変更の競合では選んだ側に合わせてタスクを削除または復元します。
+// 解決後のタスクは次の同期で他方に反映されるよう、更新日時を現在時刻にします。解決したタスクを返します (削除した場合は nil)。
+func (a *App) ResolveConflict(taskID string, useRemote bool) (*task.Task, error) {
+	idx := -1
+	for i, c := range a.Tasks.Conflicts {
+		if c.TaskID == taskID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("No sync conflict for task %s.", taskID), nil)
+	}
+	c := a.Tasks.Conflicts[idx]
+	chosen := c.Local
+	if useRemote {
+		chosen = c.Remote
+	}
+
+	now := time.Now()
+	before := a.snapshot([]string{taskID})
+	pos, exists := a.taskPosition(taskID)
+	switch {
+	case chosen == nil && exists:
+		a.removeTasks(idSet{taskID: {}})
+	case chosen != nil && exists:
+		t := a.Tasks.Tasks[pos].Clone()
+		if len(c.Fields) > 0 {
+			task.CopyFields(&t, chosen, c.Fields)
+		} else {
+			t = chosen.Clone()
+		}
+		t.UpdatedAt = now
+		a.replaceTask(pos, t)
+	case chosen != nil:
+		t := chosen.Clone()
+		t.UpdatedAt = now
+		a.appendTasks(t)
+	}
+	a.Tasks.Conflicts = append(a.Tasks.Conflicts[:idx:idx], a.Tasks.Conflicts[idx+1:]...)
+	if len(a.Tasks.Conflicts) == 0 {
+		a.Tasks.Conflicts = nil
+	}
+	a.Tasks.UpdatedAt = now
+	a.recordOperation(SourceResolve, before)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks after resolving a conflict:", err)
+			return nil, newSaveError("Failed to auto-save tasks after resolving a conflict.", err)
+		}
+	}
+	if i, ok := a.taskPosition(taskID); ok {
+		t := a.Tasks.Tasks[i]
+		return &t, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-task/internal/log"
+	"go-task/internal/task"
+)
+
+// RegisterTag はタグをタグレジストリに登録します。登録済みの場合はメタデータを置き換えます。
+func (a *App) RegisterTag(name string, info task.TagInfo) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return NewAppError(ErrTypeValidation, "Tag name cannot be empty.", nil)
+	}
+	if err := info.Validate(); err != nil {
+		return NewAppError(ErrTypeValidation, "Invalid tag settings.", err)
+	}
+	if a.Tasks.TagRegistry == nil {
+		a.Tasks.TagRegistry = make(map[string]task.TagInfo)
+	}
+	a.Tasks.TagRegistry[name] = info
+	return a.saveTagRegistry()
+}
+
+// UnregisterTag はタグをタグレジストリから削除します。タスクに付けられたタグはそのまま残ります。
+func (a *App) UnregisterTag(name string) error {
+	if _, ok := a.Tasks.TagRegistry[name]; !ok {
+		return NewAppError(ErrTypeNotFound, fmt.Sprintf("Tag %q is not registered.", name), nil)
+	}
+	delete(a.Tasks.TagRegistry, name)
+	return a.saveTagRegistry()
+}
+
+// GetTagInfo はタグのメタデータを返します。タグが登録されていない場合は親のタグのメタデータを返します。
+func (a *App) GetTagInfo(tag string) (task.TagInfo, bool) {
+	return task.LookupTag(a.Tasks.TagRegistry, tag)
+}
+
+// SetStrictTags は未登録のタグを警告する厳格モードの有効・無効を設定します。
+func (a *App) SetStrictTags(strict bool) error {
+	a.Tasks.Settings.StrictTags = strict
+	return a.saveTagRegistry()
+}
+
+// UnregisteredTags は厳格モードが有効な場合に、タグレジストリに登録されていないタグを返します。
+// 親のタグが登録されている子孫のタグは登録済みとみなします。厳格モードが無効な場合は常に nil です。
+func (a *App) UnregisteredTags(tags []string) []string {
+	if !a.Tasks.Settings.StrictTags {
+		return nil
+	}
+	var unknown []string
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		if _, ok := a.GetTagInfo(tag); !ok {
+			unknown = append(unknown, strings.TrimSpace(tag))
+		}
+	}
+	return unknown
+}
+
+// rewriteTagRegistry はタグの一括変更に合わせてタグレジストリの登録名を書き換えます。
+// 空文字に書き換えられた登録は削除します。書き換え先の名前に複数の登録が重なる場合は、書き換えていない登録、
+// 名前の変わらない登録、名前の順で最初の登録の順に優先し、結果がマップの走査順に左右されないようにします。
+// 登録を書き換えた場合は true を返します。
+func (a *App) rewriteTagRegistry(rewrite func(tag string) (string, bool)) bool {
+	renamed := make(map[string]string)
+	var names []string
+	for name := range a.Tasks.TagRegistry {
+		if newName, ok := rewrite(name); ok {
+			renamed[name] = newName
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if keepI, keepJ := renamed[names[i]] == names[i], renamed[names[j]] == names[j]; keepI != keepJ {
+			return keepI
+		}
+		return names[i] < names[j]
+	})
+
+	// 書き換える登録を全て取り除いてから、優先順に書き換え先へ登録し直す
+	infos := make([]task.TagInfo, len(names))
+	for i, name := range names {
+		infos[i] = a.Tasks.TagRegistry[name]
+		delete(a.Tasks.TagRegistry, name)
+	}
+	for i, name := range names {
+		newName := renamed[name]
+		if _, exists := a.Tasks.TagRegistry[newName]; newName != "" && !exists {
+			a.Tasks.TagRegistry[newName] = infos[i]
+		}
+	}
+	return len(renamed) > 0
+}
+
+// saveTagRegistry は自動保存が有効な場合にタグレジストリの変更を保存します。
+func (a *App) saveTagRegistry() error {
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on tag registry update:", err)
+			return newSaveError("Failed to auto-save tag registry.", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"maps"
+	"strings"
+
+	"go-task/internal/log"
+	"go-task/internal/task"
+)
+
+// タグの一括操作を変更履歴に記録する際の操作の種類です。
+const (
+	SourceRenameTag = "rename-tag"
+	SourceMergeTags = "merge-tags"
+	SourceDeleteTag = "delete-tag"
+)
+
+// RenameTag は全てのタスクのタグ old (子孫のタグを含む) を new に変更し、変更したタスク数を返します。
+// 例えば "work" を "job" に変更すると "work/backend" は "job/backend" になります。
+func (a *App) RenameTag(old, new string) (int, error) {
+	if strings.TrimSpace(old) == "" || strings.TrimSpace(new) == "" {
+		return 0, NewAppError(ErrTypeValidation, "Tag names cannot be empty.", nil)
+	}
+	return a.rewriteTags(SourceRenameTag, func(tag string) (string, bool) {
+		return task.RenameTag(tag, old, new)
+	})
+}
+
+// MergeTags は全てのタスクのタグ sources (子孫のタグを含む) を target にまとめ、変更したタスク数を返します。
+func (a *App) MergeTags(sources []string, target string) (int, error) {
+	if len(sources) == 0 || strings.TrimSpace(target) == "" {
+		return 0, NewAppError(ErrTypeValidation, "Tags to merge and the target tag are required.", nil)
+	}
+	return a.rewriteTags(SourceMergeTags, func(tag string) (string, bool) {
+		for _, source := range sources {
+			if renamed, ok := task.RenameTag(tag, source, target); ok {
+				return renamed, true
+			}
+		}
+		return tag, false
+	})
+}
+
+// DeleteTag は全てのタスクからタグ (子孫のタグを含む) を取り除き、変更したタスク数を返します。
+func (a *App) DeleteTag(tag string) (int, error) {
+	if strings.TrimSpace(tag) == "" {
+		return 0, NewAppError(ErrTypeValidation, "Tag name cannot be empty.", nil)
+	}
+	return a.rewriteTags(SourceDeleteTag, func(t string) (string, bool) {
+		if task.TagMatches(t, tag) {
+			return "", true
+		}
+		return t, false
+	})
+}
+
+// rewriteTags は全てのタスクのタグを rewrite で書き換えます。空文字に書き換えられたタグは削除し、
+// 書き換えによって重複したタグは1つにまとめます。変更は1つの操作として記録し、保存も1回だけ行います。
+// タグレジストリの登録名も同様に書き換え、取り消すと登録も元に戻ります。
+// 書き換えたタスクが検証に失敗した場合は、何も変更せずにエラーを返します。
+func (a *App) rewriteTags(source string, rewrite func(tag string) (string, bool)) (int, error) {
+	var ids []string
+	updated := make(map[string]task.Task)
+	for _, t := range a.Tasks.Tasks {
+		changed := false
+		seen := make(map[string]bool, len(t.Tags))
+		var tags []string
+		for _, tag := range t.Tags {
+			newTag, ok := rewrite(tag)
+			changed = changed || ok
+			key := strings.ToLower(newTag)
+			if newTag == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			tags = append(tags, newTag)
+		}
+		if !changed {
+			continue
+		}
+		rewritten := t.Clone()
+		rewritten.Tags = tags
+		if err := rewritten.Validate(); err != nil {
+			log.Error("Validation error on tag update:", err)
+			return 0, NewAppError(ErrTypeValidation, "Invalid task data after updating tags.", err)
+		}
+		ids = append(ids, t.ID)
+		updated[t.ID] = rewritten
+	}
+
+	registryBefore := maps.Clone(a.Tasks.TagRegistry)
+	registryChanged := a.rewriteTagRegistry(rewrite)
+	if len(ids) == 0 && !registryChanged {
+		return 0, nil
+	}
+
+	before := a.snapshot(ids)
+	for _, id := range ids {
+		i, _ := a.taskPosition(id)
+		a.replaceTask(i, updated[id])
+	}
+	a.recordOperation(source, before)
+	if registryChanged {
+		// 取り消し・やり直しで登録も戻せるよう、操作の前後の登録を記録する
+		op := &a.Tasks.UndoStack[len(a.Tasks.UndoStack)-1]
+		op.TagRegistry = &task.TagRegistryChange{Before: registryBefore, After: maps.Clone(a.Tasks.TagRegistry)}
+	}
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on tag update:", err)
+			return 0, newSaveError("Failed to auto-save tasks after updating tags.", err)
+		}
+	}
+	return len(ids), nil
+}
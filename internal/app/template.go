@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"go-task/internal/log"
+	"go-task/internal/task"
+)
+
+// GetTemplates は保存されているタスクテンプレートを返します。
+func (a *App) GetTemplates() ([]task.Template, error) {
+	templates, err := a.storage.LoadTemplates()
+	if err != nil {
+		log.Error("Failed to load templates:", err)
+		return nil, NewAppError(ErrTypeIO, "Failed to load templates.", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate は指定された名前のタスクテンプレートを返します。
+func (a *App) GetTemplate(name string) (*task.Template, error) {
+	templates, err := a.GetTemplates()
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		if templates[i].Name == name {
+			return &templates[i], nil
+		}
+	}
+	return nil, NewAppError(ErrTypeNotFound, fmt.Sprintf("Template %q not found.", name), nil)
+}
+
+// SaveTemplate はタスクテンプレートを保存します。同じ名前のテンプレートがある場合は置き換えます。
+func (a *App) SaveTemplate(tpl task.Template) error {
+	if err := tpl.Validate(); err != nil {
+		return NewAppError(ErrTypeValidation, "Invalid template.", err)
+	}
+
+	templates, err := a.GetTemplates()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range templates {
+		if templates[i].Name == tpl.Name {
+			templates[i] = tpl
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		templates = append(templates, tpl)
+	}
+
+	if err := a.storage.SaveTemplates(templates); err != nil {
+		log.Error("Failed to save templates:", err)
+		return NewAppError(ErrTypeIO, "Failed to save templates.", err)
+	}
+	return nil
+}
+
+// SaveTaskAsTemplate は既存のタスクの内容から指定された名前のテンプレートを作成します。
+// チェックリストは未完了の状態でテンプレートに含まれます。
+func (a *App) SaveTaskAsTemplate(id, name string) (*task.Template, error) {
+	t, err := a.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+	tpl := task.Template{
+		Name:        name,
+		Title:       t.Title,
+		Description: t.Description,
+		Priority:    t.Priority,
+		Tags:        append([]string(nil), t.Tags...),
+	}
+	for _, item := range t.Checklist {
+		tpl.Checklist = append(tpl.Checklist, item.Text)
+	}
+	if err := a.SaveTemplate(tpl); err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// DeleteTemplate は指定された名前のタスクテンプレートを削除します。
+func (a *App) DeleteTemplate(name string) error {
+	templates, err := a.GetTemplates()
+	if err != nil {
+		return err
+	}
+	for i := range templates {
+		if templates[i].Name == name {
+			templates = append(templates[:i], templates[i+1:]...)
+			if err := a.storage.SaveTemplates(templates); err != nil {
+				log.Error("Failed to save templates on delete:", err)
+				return NewAppError(ErrTypeIO, "Failed to save templates.", err)
+			}
+			return nil
+		}
+	}
+	return NewAppError(ErrTypeNotFound, fmt.Sprintf("Template %q not found.", name), nil)
+}
+
+// AddTaskFromTemplate はテンプレートのプレースホルダを置き換えて新しいタスクを作成します。
+// vars にはテンプレートの Variables が返すすべての変数の値を指定する必要があります。
+func (a *App) AddTaskFromTemplate(name string, vars map[string]string) (*task.Task, error) {
+	tpl, err := a.GetTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := tpl.Render(vars, time.Now())
+	if err != nil {
+		return nil, NewAppError(ErrTypeValidation, "Failed to fill in the template.", err)
+	}
+	return a.AddTaskFrom(t)
+}
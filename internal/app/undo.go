@@ -0,0 +1,165 @@
+package app
+
+import (
+	"maps"
+	"sort"
+	"time"
+
+	"go-task/internal/log"
+	"go-task/internal/task"
+)
+
+// 取り消し・やり直しによる変更を変更履歴に記録する際の操作の種類です。
+const (
+	SourceUndo = "undo"
+	SourceRedo = "redo"
+)
+
+// maxUndoOperations は取り消し可能な操作の最大保持件数です。
+// 取り消し・やり直しのスタックはデータファイルに保存され、次回起動時にも利用できます。
+const maxUndoOperations = 50
+
+// snapshot は指定されたIDのタスクの現在の状態を返します。
+func (a *App) snapshot(ids []string) []task.TaskSnapshot {
+	snaps := make([]task.TaskSnapshot, 0, len(ids))
+	for _, id := range ids {
+		s := task.TaskSnapshot{ID: id, Index: -1}
+		if i, ok := a.taskPosition(id); ok {
+			t := a.Tasks.Tasks[i].Clone()
+			s.Index = i
+			s.Task = &t
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps
+}
+
+// snapshotIDs はスナップショットに含まれるタスクIDを返します。
+func snapshotIDs(snaps []task.TaskSnapshot) []string {
+	ids := make([]string, len(snaps))
+	for i, s := range snaps {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+// recordOperation は操作前のスナップショットと現在の状態を比較し、
+// 変更履歴と取り消し用のスタックに操作を記録します。
+func (a *App) recordOperation(name string, before []task.TaskSnapshot) {
+	a.markUnsaved(before)
+	after := a.snapshot(snapshotIDs(before))
+	for i := range before {
+		a.recordHistory(name, before[i].Task, after[i].Task)
+	}
+
+	a.Tasks.UndoStack = append(a.Tasks.UndoStack, task.Operation{
+		Name:      name,
+		Timestamp: time.Now(),
+		Before:    before,
+		After:     after,
+	})
+	if over := len(a.Tasks.UndoStack) - maxUndoOperations; over > 0 {
+		a.Tasks.UndoStack = append([]task.Operation(nil), a.Tasks.UndoStack[over:]...)
+	}
+	a.Tasks.RedoStack = nil
+}
+
+// applySnapshots はタスクリストをスナップショットの状態に戻し、変更を履歴に記録します。
+func (a *App) applySnapshots(source string, target []task.TaskSnapshot) {
+	current := a.snapshot(snapshotIDs(target))
+	a.markUnsaved(current)
+
+	// 対象のタスクを一旦取り除き (後ろから)、元の位置に挿入し直す (前から)
+	remove := make(map[string]bool, len(target))
+	for _, s := range target {
+		remove[s.ID] = true
+	}
+	kept := a.Tasks.Tasks[:0]
+	for _, t := range a.Tasks.Tasks {
+		if !remove[t.ID] {
+			kept = append(kept, t)
+		}
+	}
+	a.Tasks.Tasks = kept
+
+	inserts := make([]task.TaskSnapshot, 0, len(target))
+	for _, s := range target {
+		if s.Task != nil {
+			inserts = append(inserts, s)
+		}
+	}
+	sort.SliceStable(inserts, func(i, j int) bool {
+		return inserts[i].Index < inserts[j].Index
+	})
+	for _, s := range inserts {
+		idx := s.Index
+		if idx < 0 || idx > len(a.Tasks.Tasks) {
+			idx = len(a.Tasks.Tasks)
+		}
+		a.Tasks.Tasks = append(a.Tasks.Tasks, task.Task{})
+		copy(a.Tasks.Tasks[idx+1:], a.Tasks.Tasks[idx:])
+		a.Tasks.Tasks[idx] = s.Task.Clone()
+	}
+
+	a.invalidateIndex()
+
+	for i := range target {
+		a.recordHistory(source, current[i].Task, target[i].Task)
+	}
+}
+
+// CanUndo は取り消し可能な操作があるかを返します。
+func (a *App) CanUndo() bool {
+	return len(a.Tasks.UndoStack) > 0
+}
+
+// CanRedo はやり直し可能な操作があるかを返します。
+func (a *App) CanRedo() bool {
+	return len(a.Tasks.RedoStack) > 0
+}
+
+// Undo は直前の操作を取り消し、取り消した操作を返します。
+func (a *App) Undo() (*task.Operation, error) {
+	n := len(a.Tasks.UndoStack)
+	if n == 0 {
+		return nil, NewAppError(ErrTypeNotFound, "Nothing to undo.", nil)
+	}
+	op := a.Tasks.UndoStack[n-1]
+	a.Tasks.UndoStack = a.Tasks.UndoStack[:n-1]
+	a.applySnapshots(SourceUndo, op.Before)
+	if op.TagRegistry != nil {
+		a.Tasks.TagRegistry = maps.Clone(op.TagRegistry.Before)
+	}
+	a.Tasks.RedoStack = append(a.Tasks.RedoStack, op)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on undo:", err)
+			return nil, newSaveError("Failed to auto-save tasks after undo.", err)
+		}
+	}
+	return &op, nil
+}
+
+// Redo は直前に取り消した操作をやり直し、やり直した操作を返します。
+func (a *App) Redo() (*task.Operation, error) {
+	n := len(a.Tasks.RedoStack)
+	if n == 0 {
+		return nil, NewAppError(ErrTypeNotFound, "Nothing to redo.", nil)
+	}
+	op := a.Tasks.RedoStack[n-1]
+	a.Tasks.RedoStack = a.Tasks.RedoStack[:n-1]
+	a.applySnapshots(SourceRedo, op.After)
+	if op.TagRegistry != nil {
+		a.Tasks.TagRegistry = maps.Clone(op.TagRegistry.After)
+	}
+	a.Tasks.UndoStack = append(a.Tasks.UndoStack, op)
+
+	if a.Tasks.Settings.AutoSave {
+		if err := a.storage.Save(a.Tasks); err != nil {
+			log.Error("Failed to save tasks on redo:", err)
+			return nil, newSaveError("Failed to auto-save tasks after redo.", err)
+		}
+	}
+	return &op, nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"go-task/internal/task"
+)
+
+// urgencyScores は全タスクの緊急度スコアをタスクIDごとに計算します。
+// 依存関係 (他タスクをブロックしているか、ブロックされているか) もここで判定します。
+func (a *App) urgencyScores() map[string]float64 {
+	statuses := make(map[string]task.Status, len(a.Tasks.Tasks))
+	for _, t := range a.Tasks.Tasks {
+		statuses[t.ID] = t.Status
+	}
+
+	// 未完了のタスクから依存されている未完了のタスクは、他のタスクをブロックしている
+	blocking := make(map[string]bool)
+	for _, t := range a.Tasks.Tasks {
+		if t.Status == task.StatusDone {
+			continue
+		}
+		for _, dep := range t.Depends {
+			if s, ok := statuses[dep]; ok && s != task.StatusDone {
+				blocking[dep] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	scores := make(map[string]float64, len(a.Tasks.Tasks))
+	for i := range a.Tasks.Tasks {
+		t := &a.Tasks.Tasks[i]
+		blocked := false
+		for _, dep := range t.Depends {
+			if s, ok := statuses[dep]; ok && s != task.StatusDone {
+				blocked = true
+				break
+			}
+		}
+		scores[t.ID] = t.Urgency(a.UrgencyCoefficients, now, blocking[t.ID], blocked)
+	}
+	return scores
+}
+
+// GetTaskUrgency は指定されたIDのタスクの緊急度スコアを返します。
+func (a *App) GetTaskUrgency(id string) (float64, error) {
+	score, ok := a.urgencyScores()[id]
+	if !ok {
+		return 0, NewAppError(ErrTypeNotFound, fmt.Sprintf("Task with ID %s not found.", id), nil)
+	}
+	return score, nil
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"go-task/internal/task"
+)
+
+// 待機 (スヌーズ) 期間のプリセットです。
+const (
+	SnoozeOneHour  = "1h"
+	SnoozeTomorrow = "tomorrow"
+	SnoozeNextWeek = "next-week"
+)
+
+// snoozeStartHour は「明日」「来週」のプリセットでタスクを再表示する時刻です。
+const snoozeStartHour = 9
+
+// SnoozeUntil はプリセット名または日付 (YYYY-MM-DD) から待機終了日時を計算します。
+func SnoozeUntil(preset string, now time.Time) (time.Time, error) {
+	startOfDay := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), snoozeStartHour, 0, 0, 0, t.Location())
+	}
+
+	switch preset {
+	case SnoozeOneHour:
+		return now.Add(time.Hour), nil
+	case SnoozeTomorrow:
+		return startOfDay(now.AddDate(0, 0, 1)), nil
+	case SnoozeNextWeek:
+		// 次の月曜日 (今日が月曜日の場合は翌週の月曜日)
+		days := (int(time.Monday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return startOfDay(now.AddDate(0, 0, days)), nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", preset, now.Location())
+	if err != nil {
+		return time.Time{}, NewAppError(ErrTypeValidation, fmt.Sprintf("Invalid snooze period %q. Use %s, %s, %s or YYYY-MM-DD.", preset, SnoozeOneHour, SnoozeTomorrow, SnoozeNextWeek), err)
+	}
+	return date, nil
+}
+
+// SnoozeTask は指定された日時までタスクを待機状態にし、一覧から隠します。
+// nil を指定すると待機を解除し、すぐに一覧へ戻します。
+func (a *App) SnoozeTask(id string, until *time.Time) (*task.Task, error) {
+	return a.UpdateTaskWith(id, func(t *task.Task) {
+		t.WaitUntil = until
+	})
+}
+
+// GetVisibleTasks は待機中のタスクを除いた全てのタスクを返します。
+// 待機期間を過ぎたタスクは自動的に含まれるようになります。
+func (a *App) GetVisibleTasks() []task.Task {
+	return excludeWaiting(a.Tasks.Tasks, time.Now())
+}
+
+// GetWaitingTasks は現在待機中のタスクを返します。
+func (a *App) GetWaitingTasks() []task.Task {
+	now := time.Now()
+	var waiting []task.Task
+	for i := range a.Tasks.Tasks {
+		if a.Tasks.Tasks[i].IsWaiting(now) {
+			waiting = append(waiting, a.Tasks.Tasks[i])
+		}
+	}
+	return waiting
+}
+
+// ExcludeWaiting は指定されたタスクから待機中のものを除いて返します。
+// フィルタや検索の結果を一覧に表示する際に使用します。
+func (a *App) ExcludeWaiting(tasks []task.Task) []task.Task {
+	return excludeWaiting(tasks, time.Now())
+}
+
+// excludeWaiting は指定された日時の時点で待機中でないタスクのみを返します。
+func excludeWaiting(tasks []task.Task, now time.Time) []task.Task {
+	visible := make([]task.Task, 0, len(tasks))
+	for i := range tasks {
+		if !tasks[i].IsWaiting(now) {
+			visible = append(visible, tasks[i])
+		}
+	}
+	return visible
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"errors"
+
+	"go-task/internal/config"
+	"go-task/internal/log"
+	"go-task/internal/store"
+)
+
+// Workspace はタスクデータを読み込んだワークスペースの名前を返します。
+// ローカルのタスクファイルを使用している場合や NewAppWithStore で作成した場合は空文字列です。
+func (a *App) Workspace() string {
+	return a.workspace
+}
+
+// LocalDir はローカルのタスクファイルを使用している場合にその .go-task ディレクトリのパスを返します。
+// グローバルなデータディレクトリを使用している場合は空文字列です。
+func (a *App) LocalDir() string {
+	return a.localDir
+}
+
+// InitLocal は dir にローカルのタスクファイル (.go-task/tasks.json) を作成し、.go-task ディレクトリのパスを返します。
+func InitLocal(dir string) (string, error) {
+	localDir, err := store.InitLocal(dir)
+	if err != nil {
+		log.Error("Failed to create local task file:", err)
+		return "", NewAppError(ErrTypeIO, "Failed to create local task file.", err)
+	}
+	return localDir, nil
+}
+
+// CurrentWorkspace は設定ファイルで選択されているワークスペースの名前を返します。
+func CurrentWorkspace() (string, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Error("Failed to load config:", err)
+		return "", NewAppError(ErrTypeIO, "Failed to load config.", err)
+	}
+	if cfg.CurrentWorkspace == "" {
+		return store.DefaultWorkspace, nil
+	}
+	return cfg.CurrentWorkspace, nil
+}
+
+// ListWorkspaces はワークスペースの名前の一覧を返します。先頭は既定のワークスペースです。
+func ListWorkspaces() ([]string, error) {
+	names, err := store.ListWorkspaces()
+	if err != nil {
+		log.Error("Failed to list workspaces:", err)
+		return nil, NewAppError(ErrTypeIO, "Failed to list workspaces.", err)
+	}
+	return names, nil
+}
+
+// CreateWorkspace は空のワークスペースを作成します。
+func CreateWorkspace(name string) error {
+	if err := store.ValidateWorkspaceName(name); err != nil {
+		return NewAppError(ErrTypeValidation, "Invalid workspace name.", err)
+	}
+	if err := store.CreateWorkspace(name); err != nil {
+		return newWorkspaceError("Failed to create workspace.", err)
+	}
+	return nil
+}
+
+// SwitchWorkspace は指定されたワークスペースを選択して設定ファイルに保存し、そのワークスペースの App を返します。
+func SwitchWorkspace(name string) (*App, error) {
+	a, err := NewAppForWorkspace(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := setCurrentWorkspace(name); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteWorkspace はワークスペースとそのタスクデータ、バックアップを削除します。
+// 選択中のワークスペースを削除した場合は既定のワークスペースが選択されます。
+func DeleteWorkspace(name string) error {
+	if name == store.DefaultWorkspace {
+		return NewAppError(ErrTypeValidation, "The default workspace cannot be deleted.", nil)
+	}
+	current, err := CurrentWorkspace()
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteWorkspace(name); err != nil {
+		return newWorkspaceError("Failed to delete workspace.", err)
+	}
+	if current == name {
+		return setCurrentWorkspace(store.DefaultWorkspace)
+	}
+	return nil
+}
+
+// RenameWorkspace はワークスペースの名前を変更します。選択中のワークスペースの場合は選択も新しい名前に変更します。
+func RenameWorkspace(oldName, newName string) error {
+	if oldName == store.DefaultWorkspace || newName == store.DefaultWorkspace {
+		return NewAppError(ErrTypeValidation, "The default workspace cannot be renamed.", nil)
+	}
+	if err := store.ValidateWorkspaceName(newName); err != nil {
+		return NewAppError(ErrTypeValidation, "Invalid workspace name.", err)
+	}
+	current, err := CurrentWorkspace()
+	if err != nil {
+		return err
+	}
+	if err := store.RenameWorkspace(oldName, newName); err != nil {
+		return newWorkspaceError("Failed to rename workspace.", err)
+	}
+	if current == oldName {
+		return setCurrentWorkspace(newName)
+	}
+	return nil
+}
+
+// setCurrentWorkspace は選択中のワークスペースを設定ファイルに保存します。
+func setCurrentWorkspace(name string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Error("Failed to load config:", err)
+		return NewAppError(ErrTypeIO, "Failed to load config.", err)
+	}
+	cfg.CurrentWorkspace = name
+	if name == store.DefaultWorkspace {
+		cfg.CurrentWorkspace = ""
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		log.Error("Failed to save config:", err)
+		return NewAppError(ErrTypeIO, "Failed to save config.", err)
+	}
+	return nil
+}
+
+// newWorkspaceError はワークスペースの操作に失敗したエラーを AppError に変換します。
+func newWorkspaceError(message string, err error) *AppError {
+	switch {
+	case errors.Is(err, store.ErrWorkspaceNotFound):
+		return NewAppError(ErrTypeNotFound, "Workspace not found.", err)
+	case errors.Is(err, store.ErrWorkspaceExists):
+		return NewAppError(ErrTypeValidation, "A workspace with that name already exists.", err)
+	}
+	log.Error(message, err)
+	return NewAppError(ErrTypeIO, message, err)
+}
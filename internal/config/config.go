@@ -2,27 +2,114 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"go-task/internal/paths"
+	"go-task/internal/store"
 	"go-task/internal/task"
 )
 
 const (
 	ConfigFileName = "config.json"
-	ConfigDir      = ".go-task"
+	ConfigDir      = paths.LegacyDir // 保存先を指定しない場合の従来の設定ディレクトリ名
 )
 
 type Settings struct {
-	DefaultPriority task.Priority `json:"default_priority"`
-	AutoSave        bool          `json:"auto_save"`
-	Theme           string        `json:"theme"`
+	DefaultPriority task.Priority            `json:"default_priority"`
+	AutoSave        bool                     `json:"auto_save"`
+	Theme           string                   `json:"theme"`
+	Urgency         task.UrgencyCoefficients `json:"urgency"`
+	Backup          BackupSettings           `json:"backup"`
+	Storage         StorageSettings          `json:"storage"`
+}
+
+// StorageSettings はタスクデータの保存形式の設定です。
+type StorageSettings struct {
+	// Format は保存形式です。"json" は保存のたびに tasks.json 全体を書き換え、
+	// "journal" は変更を tasks.journal に追記して定期的に tasks.json へ書き戻します。
+	Format string `json:"format"`
+	// CompactAfter は "journal" 形式で tasks.json に書き戻すまでに追記する保存の回数です。
+	CompactAfter int `json:"compact_after"`
+}
+
+// DefaultStorageSettings は既定の保存形式の設定を返します。
+func DefaultStorageSettings() StorageSettings {
+	return StorageSettings{Format: store.FormatJSON, CompactAfter: store.DefaultCompactAfter}
+}
+
+// Options は保存形式の設定を Store の設定に変換します。
+func (s StorageSettings) Options() store.StorageOptions {
+	return store.StorageOptions{Format: s.Format, CompactAfter: s.CompactAfter}
+}
+
+// BackupSettings は自動バックアップの設定です。
+type BackupSettings struct {
+	// Interval は定期バックアップの間隔です (例: "1h", "30m")。"0" の場合は定期バックアップを行いません。
+	Interval string `json:"interval"`
+	// KeepLast, KeepHourly, KeepDaily, KeepWeekly はバックアップの保持数です (store.RetentionPolicy を参照)。
+	KeepLast   int `json:"keep_last"`
+	KeepHourly int `json:"keep_hourly"`
+	KeepDaily  int `json:"keep_daily"`
+	KeepWeekly int `json:"keep_weekly"`
+	// Compress はバックアップを gzip で圧縮するかどうかです。
+	Compress bool `json:"compress"`
+	// OnExit は TUI の終了時にバックアップを作成するかどうかです。
+	OnExit bool `json:"on_exit"`
+	// BeforeDestructive はインポート、バックアップからの復元、複数タスクの削除の前にバックアップを作成するかどうかです。
+	BeforeDestructive bool `json:"before_destructive"`
+}
+
+// DefaultBackupSettings は既定のバックアップ設定を返します。
+func DefaultBackupSettings() BackupSettings {
+	return BackupSettings{
+		Interval:          "1h",
+		KeepLast:          5,
+		KeepHourly:        24,
+		KeepDaily:         7,
+		KeepWeekly:        4,
+		Compress:          true,
+		OnExit:            true,
+		BeforeDestructive: true,
+	}
+}
+
+// IntervalDuration は定期バックアップの間隔を返します。0 の場合は定期バックアップを行いません。
+func (b BackupSettings) IntervalDuration() (time.Duration, error) {
+	if b.Interval == "" || b.Interval == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(b.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backup interval %q: %w", b.Interval, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid backup interval %q: must not be negative", b.Interval)
+	}
+	return d, nil
+}
+
+// Options はバックアップ設定を Store のバックアップ設定に変換します。
+func (b BackupSettings) Options() store.BackupOptions {
+	return store.BackupOptions{
+		Retention: store.RetentionPolicy{
+			Last:   b.KeepLast,
+			Hourly: b.KeepHourly,
+			Daily:  b.KeepDaily,
+			Weekly: b.KeepWeekly,
+		},
+		Compress: b.Compress,
+	}
 }
 
 type Config struct {
 	Settings Settings `json:"settings"`
-	mu       sync.RWMutex
+	// CurrentWorkspace は起動時に開くワークスペースの名前です。空の場合は既定のワークスペースを開きます。
+	CurrentWorkspace string `json:"current_workspace,omitempty"`
+	mu               sync.RWMutex
 }
 
 func NewDefaultConfig() *Config {
@@ -31,16 +118,20 @@ func NewDefaultConfig() *Config {
 			DefaultPriority: task.PriorityMedium,
 			AutoSave:        true,
 			Theme:           "default",
+			Urgency:         task.DefaultUrgencyCoefficients(),
+			Backup:          DefaultBackupSettings(),
+			Storage:         DefaultStorageSettings(),
 		},
 	}
 }
 
+// GetConfigFilePath は設定ファイルのパスを返し、設定ディレクトリが存在しない場合は作成します。
+// 設定ディレクトリは --data-dir フラグ、環境変数 GO_TASK_HOME、XDG_CONFIG_HOME の順に決まります。
 func GetConfigFilePath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := paths.ConfigDir()
 	if err != nil {
 		return "", err
 	}
-	configDir := filepath.Join(homeDir, ConfigDir)
 	if _, err := os.Stat(configDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(configDir, 0700); err != nil {
 			return "", err
@@ -67,11 +158,12 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// ファイルに存在しない項目はデフォルト値のままにする
+	cfg := NewDefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	return cfg, nil
 }
 
 func (c *Config) SaveConfig() error {
@@ -88,5 +180,5 @@ func (c *Config) SaveConfig() error {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0600)
+	return store.WriteFileAtomic(configPath, data, 0600)
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"go-task/internal/task"
 )
@@ -108,3 +109,56 @@ func TestSaveAndLoadConfig(t *testing.T) {
 		t.Errorf("Expected default priority after non-existent load, got %s", newLoadedCfg.Settings.DefaultPriority)
 	}
 }
+
+func TestLoadConfigKeepsDefaultsForMissingFields(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("Failed to get home directory: %v", err)
+	}
+	configDirPath := filepath.Join(homeDir, ConfigDir)
+	os.RemoveAll(configDirPath)
+	defer os.RemoveAll(configDirPath)
+
+	// 緊急度係数が導入される前の設定ファイル
+	configPath, err := GetConfigFilePath()
+	if err != nil {
+		t.Fatalf("GetConfigFilePath returned an error: %v", err)
+	}
+	oldConfig := `{"settings": {"default_priority": "HIGH", "auto_save": true, "theme": "dark"}}`
+	if err := os.WriteFile(configPath, []byte(oldConfig), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Settings.DefaultPriority != task.PriorityHigh {
+		t.Errorf("Expected default priority HIGH, got %s", cfg.Settings.DefaultPriority)
+	}
+	if cfg.Settings.Urgency != task.DefaultUrgencyCoefficients() {
+		t.Errorf("Expected default urgency coefficients, got %+v", cfg.Settings.Urgency)
+	}
+}
+
+func TestBackupSettings(t *testing.T) {
+	b := DefaultBackupSettings()
+	if d, err := b.IntervalDuration(); err != nil || d != time.Hour {
+		t.Errorf("IntervalDuration() = %v, %v, want 1h", d, err)
+	}
+	opts := b.Options()
+	if opts.Retention.Last != b.KeepLast || opts.Retention.Daily != b.KeepDaily || opts.Compress != b.Compress {
+		t.Errorf("Options() = %+v, does not match %+v", opts, b)
+	}
+
+	for _, interval := range []string{"soon", "-1h"} {
+		b.Interval = interval
+		if _, err := b.IntervalDuration(); err == nil {
+			t.Errorf("IntervalDuration(%q) should fail", interval)
+		}
+	}
+	b.Interval = "0"
+	if d, err := b.IntervalDuration(); err != nil || d != 0 {
+		t.Errorf("IntervalDuration(\"0\") = %v, %v, want 0", d, err)
+	}
+}
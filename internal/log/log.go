@@ -1,39 +1,52 @@
 package log
 
 import (
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-)
-
-var logger *log.Logger
+	"sync"
 
-func init() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatalf("Failed to get user home directory: %v", err)
-	}
-	logDir := filepath.Join(home, ".go-task")
-	if err := os.MkdirAll(logDir, 0700); err != nil {
-		log.Fatalf("Failed to create log directory: %v", err)
-	}
+	"go-task/internal/paths"
+)
 
-	logFile, err := os.OpenFile(filepath.Join(logDir, "app.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
-	}
+var (
+	logger     *log.Logger
+	loggerOnce sync.Once
+)
 
-	logger = log.New(logFile, "", log.LstdFlags)
+// getLogger はログファイルを開いたロガーを返します。
+// ログファイルは最初の出力時に開くため、--data-dir フラグなどで保存先を変更した後のディレクトリが使われます。
+// ログファイルを開けない場合はログを破棄します。
+func getLogger() *log.Logger {
+	loggerOnce.Do(func() {
+		logger = log.New(io.Discard, "", log.LstdFlags)
+		logDir, err := paths.StateDir()
+		if err != nil {
+			return
+		}
+		if err := os.MkdirAll(logDir, 0700); err != nil {
+			return
+		}
+		logFile, err := os.OpenFile(filepath.Join(logDir, "app.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return
+		}
+		logger.SetOutput(logFile)
+	})
+	return logger
 }
 
 // Info は情報レベルのログを出力します。
 func Info(v ...interface{}) {
-	logger.SetPrefix("INFO: ")
-	logger.Println(v...)
+	l := getLogger()
+	l.SetPrefix("INFO: ")
+	l.Println(v...)
 }
 
 // Error はエラーレベルのログを出力します。
 func Error(v ...interface{}) {
-	logger.SetPrefix("ERROR: ")
-	logger.Println(v...)
+	l := getLogger()
+	l.SetPrefix("ERROR: ")
+	l.Println(v...)
 }
@@ -0,0 +1,130 @@
+// Package paths は go-task がデータ、設定、ログを保存するディレクトリを決定します。
+//
+// ディレクトリは以下の優先順位で決まります。
+//
+//  1. --data-dir フラグで指定されたディレクトリ (SetDataDir)
+//  2. 環境変数 GO_TASK_HOME
+//  3. 既存の ~/.go-task ディレクトリ (以前のバージョンとの互換性のため)
+//  4. XDG Base Directory の環境変数 (XDG_DATA_HOME, XDG_CONFIG_HOME, XDG_STATE_HOME) 以下の go-task。
+//     いずれかが設定されている場合、設定されていない用途は仕様の既定値 (~/.local/share, ~/.config, ~/.local/state) を使用します
+//  5. ~/.go-task
+//
+// 1 と 2 ではデータ、設定、ログのすべてを指定されたディレクトリに保存します。
+// 3 の ~/.go-task は、このプロセスが最初に確認した時点で存在していた場合だけ使用します。
+// 設定ファイルやログの保存で作成したディレクトリによって、他の用途の保存先が変わらないようにするためです。
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// EnvHome はデータディレクトリを指定する環境変数の名前です。
+	EnvHome = "GO_TASK_HOME"
+	// LegacyDir はホームディレクトリ直下の従来のデータディレクトリ名です。
+	LegacyDir = ".go-task"
+
+	appName = "go-task"
+)
+
+// xdgDefaults は XDG Base Directory の環境変数と、設定されていない場合のホームディレクトリからの既定のパスです。
+var xdgDefaults = map[string]string{
+	"XDG_DATA_HOME":   filepath.Join(".local", "share"),
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_STATE_HOME":  filepath.Join(".local", "state"),
+}
+
+var (
+	mu       sync.RWMutex
+	override string
+	// legacySeen は ~/.go-task のパス → このプロセスが最初に確認した時点で存在していたかです。
+	legacySeen = make(map[string]bool)
+)
+
+// SetDataDir はコマンドラインで指定されたディレクトリをデータ、設定、ログの保存先に設定します。
+// 空文字列を指定すると設定を解除します。
+func SetDataDir(dir string) error {
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve data directory %s: %w", dir, err)
+		}
+		dir = abs
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	override = dir
+	return nil
+}
+
+// DataDirSet は --data-dir フラグで保存先が指定されているかを返します。
+func DataDirSet() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return override != ""
+}
+
+// DataDir はタスクデータ、テンプレート、バックアップを保存するディレクトリのパスを返します。
+func DataDir() (string, error) {
+	return resolve("XDG_DATA_HOME")
+}
+
+// ConfigDir は設定ファイルを保存するディレクトリのパスを返します。
+func ConfigDir() (string, error) {
+	return resolve("XDG_CONFIG_HOME")
+}
+
+// StateDir はログファイルを保存するディレクトリのパスを返します。
+func StateDir() (string, error) {
+	return resolve("XDG_STATE_HOME")
+}
+
+// resolve はパッケージのドキュメントの優先順位に従ってディレクトリを決定します。
+// xdgEnv は用途に対応する XDG Base Directory の環境変数の名前です。
+func resolve(xdgEnv string) (string, error) {
+	mu.RLock()
+	dir := override
+	mu.RUnlock()
+	if dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv(EnvHome); dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	legacy := filepath.Join(homeDir, LegacyDir)
+	if legacyExisted(legacy) {
+		return legacy, nil
+	}
+	// XDG Base Directory の仕様では絶対パス以外は無視する
+	if base := os.Getenv(xdgEnv); filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+	// XDG の環境変数を使用している場合は、設定されていない用途も XDG の既定のディレクトリにそろえる
+	for env := range xdgDefaults {
+		if filepath.IsAbs(os.Getenv(env)) {
+			return filepath.Join(homeDir, xdgDefaults[xdgEnv], appName), nil
+		}
+	}
+	return legacy, nil
+}
+
+// legacyExisted は ~/.go-task がこのプロセスで最初に確認した時点で存在していたかを返します。
+func legacyExisted(legacy string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	existed, ok := legacySeen[legacy]
+	if !ok {
+		_, err := os.Stat(legacy)
+		existed = err == nil
+		legacySeen[legacy] = existed
+	}
+	return existed
+}
@@ -0,0 +1,113 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTestEnv は HOME を一時ディレクトリに設定し、保存先に関係する環境変数を無効にします。
+func setupTestEnv(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{EnvHome, "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(env, "")
+	}
+	t.Cleanup(func() { SetDataDir("") })
+	return home
+}
+
+// forgetLegacy は ~/.go-task の存在の確認結果を破棄し、新しいプロセスで起動した状態にします。
+func forgetLegacy(legacy string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(legacySeen, legacy)
+}
+
+func TestResolve(t *testing.T) {
+	home := setupTestEnv(t)
+	legacy := filepath.Join(home, LegacyDir)
+
+	// 何も指定しない場合は ~/.go-task
+	if dir, err := DataDir(); err != nil || dir != legacy {
+		t.Errorf("DataDir() = %q, %v, want %q", dir, err, legacy)
+	}
+
+	// XDG の環境変数は用途ごとに使い分ける (相対パスは無視する)
+	xdg := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(xdg, "data"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(xdg, "config"))
+	t.Setenv("XDG_STATE_HOME", "relative/state")
+	if dir, _ := DataDir(); dir != filepath.Join(xdg, "data", appName) {
+		t.Errorf("DataDir() with XDG_DATA_HOME = %q", dir)
+	}
+	if dir, _ := ConfigDir(); dir != filepath.Join(xdg, "config", appName) {
+		t.Errorf("ConfigDir() with XDG_CONFIG_HOME = %q", dir)
+	}
+	if dir, _ := StateDir(); dir != filepath.Join(home, ".local", "state", appName) {
+		t.Errorf("StateDir() with a relative XDG_STATE_HOME = %q, want the XDG default", dir)
+	}
+
+	// このプロセスが作成した ~/.go-task は XDG より優先しない
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatalf("Failed to create legacy dir: %v", err)
+	}
+	if dir, _ := DataDir(); dir != filepath.Join(xdg, "data", appName) {
+		t.Errorf("DataDir() after creating the legacy dir = %q, want the XDG data dir", dir)
+	}
+
+	// 起動前から存在する ~/.go-task は XDG より優先する
+	forgetLegacy(legacy)
+	if dir, _ := DataDir(); dir != legacy {
+		t.Errorf("DataDir() with an existing legacy dir = %q, want %q", dir, legacy)
+	}
+
+	// GO_TASK_HOME はすべての用途で優先する
+	goTaskHome := t.TempDir()
+	t.Setenv(EnvHome, goTaskHome)
+	for name, fn := range map[string]func() (string, error){"DataDir": DataDir, "ConfigDir": ConfigDir, "StateDir": StateDir} {
+		if dir, _ := fn(); dir != goTaskHome {
+			t.Errorf("%s() with %s = %q, want %q", name, EnvHome, dir, goTaskHome)
+		}
+	}
+
+	// --data-dir フラグは GO_TASK_HOME より優先し、相対パスは絶対パスに変換する
+	if err := SetDataDir("flag-dir"); err != nil {
+		t.Fatalf("SetDataDir() error = %v", err)
+	}
+	want, _ := filepath.Abs("flag-dir")
+	if dir, _ := ConfigDir(); dir != want {
+		t.Errorf("ConfigDir() with --data-dir = %q, want %q", dir, want)
+	}
+	SetDataDir("")
+	if dir, _ := DataDir(); dir != goTaskHome {
+		t.Errorf("DataDir() after clearing --data-dir = %q, want %q", dir, goTaskHome)
+	}
+}
+
+func TestResolveOnlyXDGDataHome(t *testing.T) {
+	home := setupTestEnv(t)
+	data := filepath.Join(home, "data")
+	t.Setenv("XDG_DATA_HOME", data)
+
+	// 設定とログは XDG の既定のディレクトリを使用し、~/.go-task を作成しない
+	if dir, _ := ConfigDir(); dir != filepath.Join(home, ".config", appName) {
+		t.Errorf("ConfigDir() = %q, want ~/.config/go-task", dir)
+	}
+	if dir, _ := StateDir(); dir != filepath.Join(home, ".local", "state", appName) {
+		t.Errorf("StateDir() = %q, want ~/.local/state/go-task", dir)
+	}
+	// 設定ディレクトリを作成した後もデータディレクトリは XDG_DATA_HOME のまま
+	configDir, _ := ConfigDir()
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if dir, _ := DataDir(); dir != filepath.Join(data, appName) {
+			t.Errorf("DataDir() = %q, want %q", dir, filepath.Join(data, appName))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, LegacyDir)); err == nil {
+		t.Errorf("~/.go-task should not be created")
+	}
+}
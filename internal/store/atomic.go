@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// writeData は一時ファイルにデータを書き込みます。テストでは書き込みの途中失敗を再現するために差し替えます。
+var writeData = func(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	return err
+}
+
+// WriteFileAtomic はファイルをアトミックに書き込みます。
+// 同じディレクトリの一時ファイルに書き込んで fsync した後、rename で置き換え、ディレクトリも fsync します。
+// 書き込みの途中でクラッシュやディスクフルが発生しても、元のファイルは壊れずに残ります。
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := writeTempFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeTempFile は path と同じディレクトリの一時ファイルにデータを書き込んで fsync し、一時ファイルのパスを返します。
+// 失敗した場合は一時ファイルを残しません。rename で path を置き換えるのは呼び出し側です。
+func writeTempFile(path string, data []byte, perm os.FileMode) (string, error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	// 書き込みを終えるまでは一時ファイルを残さない
+	written := false
+	defer func() {
+		if !written {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := writeData(tmp, data); err != nil {
+		return "", fmt.Errorf("failed to write temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return "", fmt.Errorf("failed to set permission on %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file %s: %w", tmpPath, err)
+	}
+	written = true
+	return tmpPath, nil
+}
+
+// syncDir はディレクトリを fsync し、rename によるエントリの変更を永続化します。
+// Windows ではディレクトリを fsync できないため何もしません。
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compressedBackupSuffix は gzip で圧縮したバックアップファイルの拡張子です。
+const compressedBackupSuffix = ".json.gz"
+
+// RetentionPolicy はバックアップの保持ルールです。
+// いずれかの規則で保持対象になったバックアップは削除されず、最新のバックアップは常に保持されます。
+type RetentionPolicy struct {
+	Last   int // 新しい順に保持する数
+	Hourly int // バックアップがある直近の N 時間について、それぞれの時間の最新のバックアップを保持する
+	Daily  int // バックアップがある直近の N 日について、それぞれの日の最新のバックアップを保持する
+	Weekly int // バックアップがある直近の N 週について、それぞれの週の最新のバックアップを保持する
+}
+
+// BackupOptions はバックアップの作成と削除の設定です。
+type BackupOptions struct {
+	Retention RetentionPolicy
+	Compress  bool // バックアップを gzip で圧縮する (MemoryStore では無視されます)
+}
+
+// DefaultBackupOptions は Store の既定のバックアップ設定を返します。最新の 5 個のバックアップを圧縮せずに保持します。
+func DefaultBackupOptions() BackupOptions {
+	return BackupOptions{Retention: RetentionPolicy{Last: maxBackups}}
+}
+
+// keep は新しい順に並んだバックアップのうち、保持するバックアップの名前を返します。
+func (p RetentionPolicy) keep(backups []BackupInfo) map[string]bool {
+	keep := make(map[string]bool)
+	if len(backups) > 0 {
+		keep[backups[0].Name] = true
+	}
+	for i := 0; i < p.Last && i < len(backups); i++ {
+		keep[backups[i].Name] = true
+	}
+
+	rules := []struct {
+		n      int
+		bucket func(t time.Time) string
+	}{
+		{p.Hourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{p.Daily, func(t time.Time) string { return t.Format("20060102") }},
+		{p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+	}
+	for _, rule := range rules {
+		seen := make(map[string]bool)
+		for _, b := range backups {
+			if len(seen) >= rule.n {
+				break
+			}
+			if key := rule.bucket(b.CreatedAt); !seen[key] {
+				seen[key] = true
+				keep[b.Name] = true
+			}
+		}
+	}
+	return keep
+}
+
+// isBackupFileName はファイル名が CreateBackup で作成したバックアップのものかを返します。
+func isBackupFileName(name string) bool {
+	return strings.HasPrefix(name, backupFilePrefix) &&
+		(strings.HasSuffix(name, backupFileSuffix) || strings.HasSuffix(name, compressedBackupSuffix))
+}
+
+// backupTime はバックアップファイル名に含まれる作成日時を返します。
+func backupTime(name string) (time.Time, bool) {
+	timestamp := strings.TrimPrefix(name, backupFilePrefix)
+	if len(timestamp) < len(backupFileTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupFileTimeLayout, timestamp[:len(backupFileTimeLayout)], time.Local)
+	return t, err == nil
+}
+
+// compress は内容を gzip で圧縮します。
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress backup: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressIfNeeded は gzip で圧縮された内容を展開します。圧縮されていない場合はそのまま返します。
+func decompressIfNeeded(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	defer zr.Close()
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	return plain, nil
+}
+
+// backupSequence は同じ秒に作成したバックアップの連番を返します。連番のないバックアップは 1 です。
+func backupSequence(name string) int {
+	rest := strings.TrimPrefix(name, backupFilePrefix)
+	if len(rest) <= len(backupFileTimeLayout) || rest[len(backupFileTimeLayout)] != '-' {
+		return 1
+	}
+	n, err := strconv.Atoi(strings.SplitN(rest[len(backupFileTimeLayout)+1:], ".", 2)[0])
+	if err != nil {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,401 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// encryptionAlgorithm は暗号化されたファイルに記録する暗号方式の名前です。
+	encryptionAlgorithm = "aes-256-gcm"
+	// kdfAlgorithm は暗号化されたファイルに記録する鍵導出関数の名前です。
+	// 外部の依存を増やさないよう、標準ライブラリだけで実装できる PBKDF2-HMAC-SHA256 を使用します。
+	// scrypt や Argon2 のようなメモリハードな関数ではないため、反復回数 (kdfIterations) を多くしてその分を補います。
+	kdfAlgorithm = "pbkdf2-sha256"
+	saltSize     = 16
+	keySize      = 32
+)
+
+// kdfIterations は新しく暗号化するファイルの鍵導出の反復回数です。テストでは小さな値に変更します。
+// PBKDF2-HMAC-SHA256 の推奨値 (OWASP、60万回) より多くし、メモリハードでない分の総当たりへの耐性を補います。
+// 反復回数はファイルごとに記録されるため、変更しても既存のファイルはそのまま読み込めます。
+var kdfIterations = 1200000
+
+var (
+	// ErrPassphraseRequired はファイルが暗号化されているがパスフレーズが設定されていないことを表します。
+	ErrPassphraseRequired = errors.New("the task file is encrypted and no passphrase was given")
+	// ErrWrongPassphrase はパスフレーズが誤っていることを表します。
+	// 鍵の確認値を持たない古い形式のファイルでは、ファイルが改ざんされている場合も含みます。
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted encrypted file")
+)
+
+// keyCheckLabel は鍵の確認値の計算に使用する文字列です。
+const keyCheckLabel = "go-task key check"
+
+// encryptedFile は暗号化されたファイルの形式です。鍵導出のパラメータと共に暗号文を保存します。
+type encryptedFile struct {
+	Encryption string `json:"encryption"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	// KeyCheck は鍵から計算した確認値です。パスフレーズの誤りとファイルの破損を区別するために使用します。
+	KeyCheck   []byte `json:"key_check,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// encryptedPrefix は暗号化されたファイルの先頭です。平文のタスクデータと区別するために使用します。
+var encryptedPrefix = []byte(`{"encryption":`)
+
+// keyring はパスフレーズと、導出済みの鍵を保持します。
+// 鍵の導出は意図的に遅いため、同じソルトの鍵は一度だけ導出して再利用します。
+type keyring struct {
+	mu         sync.Mutex
+	passphrase string
+	keys       map[string][]byte // ソルトと反復回数ごとの導出済みの鍵
+	saveSalt   []byte            // 暗号化に使用するソルト
+	saveIter   int
+}
+
+// keys はプロセス全体で共有する鍵の保管場所です。
+var keys = &keyring{}
+
+// SetPassphrase は暗号化されたファイルの読み書きに使用するパスフレーズを設定します。
+func SetPassphrase(passphrase string) {
+	keys.reset(passphrase)
+}
+
+// current は設定されているパスフレーズを返します。
+func (k *keyring) current() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.passphrase
+}
+
+// reset はパスフレーズを設定し、導出済みの鍵を破棄します。
+func (k *keyring) reset(passphrase string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.passphrase = passphrase
+	k.keys = nil
+	k.saveSalt = nil
+	k.saveIter = 0
+}
+
+// key は指定されたソルトと反復回数の鍵を返します。
+func (k *keyring) key(salt []byte, iterations int) ([]byte, error) {
+	if k.passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	id := fmt.Sprintf("%x:%d", salt, iterations)
+	if key, ok := k.keys[id]; ok {
+		return key, nil
+	}
+	if k.keys == nil {
+		k.keys = make(map[string][]byte)
+	}
+	key := pbkdf2SHA256([]byte(k.passphrase), salt, iterations, keySize)
+	k.keys[id] = key
+	return key, nil
+}
+
+// encrypt は平文を暗号化したファイルの内容を返します。
+// ソルトは復号に使用したものか、なければ新しく生成したものを再利用し、ノンスは毎回生成します。
+func (k *keyring) encrypt(plaintext []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.saveSalt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		k.saveSalt, k.saveIter = salt, kdfIterations
+	}
+	key, err := k.key(k.saveSalt, k.saveIter)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return json.Marshal(encryptedFile{
+		Encryption: encryptionAlgorithm,
+		KDF:        kdfAlgorithm,
+		Iterations: k.saveIter,
+		Salt:       k.saveSalt,
+		KeyCheck:   keyCheck(key),
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+// hasPassphrase はパスフレーズが設定されているかを返します。
+func (k *keyring) hasPassphrase() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.passphrase != ""
+}
+
+// decrypt は暗号化されたファイルの内容を復号します。
+// 途中で切れたファイルや、鍵の確認値が一致するのに認証に失敗したファイルは ErrCorrupted を返します。
+func (k *keyring) decrypt(data []byte) ([]byte, error) {
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: failed to read encrypted file: %v", ErrCorrupted, err)
+	}
+	if file.Encryption != encryptionAlgorithm || file.KDF != kdfAlgorithm {
+		return nil, fmt.Errorf("unsupported encryption %s/%s", file.Encryption, file.KDF)
+	}
+	if file.Iterations <= 0 || len(file.Salt) == 0 {
+		return nil, fmt.Errorf("%w: invalid key derivation parameters", ErrCorrupted)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, err := k.key(file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	// 確認値が一致する場合は鍵が正しいため、認証の失敗はファイルの破損とみなす
+	failed := ErrWrongPassphrase
+	if file.KeyCheck != nil {
+		if !hmac.Equal(file.KeyCheck, keyCheck(key)) {
+			return nil, ErrWrongPassphrase
+		}
+		failed = ErrCorrupted
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", failed)
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: authentication failed", failed)
+	}
+	if k.saveSalt == nil {
+		// 以降の保存では同じ鍵を使い、鍵の導出を繰り返さない
+		k.saveSalt, k.saveIter = file.Salt, file.Iterations
+	}
+	return plaintext, nil
+}
+
+// isEncrypted はファイルの内容が暗号化されているかを返します。
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), encryptedPrefix)
+}
+
+// decryptIfNeeded は暗号化されたファイルの場合に復号した内容を返します。平文の場合はそのまま返します。
+func decryptIfNeeded(data []byte) ([]byte, bool, error) {
+	if !isEncrypted(data) {
+		return data, false, nil
+	}
+	plaintext, err := keys.decrypt(data)
+	return plaintext, true, err
+}
+
+// encryptIf は encrypted が真の場合に内容を暗号化して返します。
+func encryptIf(data []byte, encrypted bool) ([]byte, error) {
+	if !encrypted {
+		return data, nil
+	}
+	return keys.encrypt(data)
+}
+
+// keyCheck は鍵の確認値を返します。鍵そのものは明かさずに、パスフレーズが正しいかを判定できます。
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckLabel))
+	return mac.Sum(nil)[:16]
+}
+
+// newGCM は AES-256-GCM の AEAD を作成します。
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 は RFC 8018 の PBKDF2 で HMAC-SHA256 を使用して鍵を導出します。
+// go-task は標準ライブラリ以外の暗号ライブラリに依存しないため、ここで実装しています。
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	derived := make([]byte, 0, blocks*hashLen)
+	var counter [4]byte
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+	return derived[:keyLen]
+}
+
+// Encrypter は保存時の暗号化に対応した Store が実装するインターフェースです。
+type Encrypter interface {
+	// Encrypted はタスクデータが暗号化されているかを返します。
+	Encrypted() (bool, error)
+	// SetEncryption はタスクデータとバックアップを指定されたパスフレーズで暗号化し直します。
+	// 空文字列を指定すると暗号化を解除します。現在暗号化されている場合は、事前に SetPassphrase で現在のパスフレーズを設定しておく必要があります。
+	SetEncryption(passphrase string) error
+}
+
+// Encrypted はデータファイルが暗号化されているかを返します。データファイルがない場合は false です。
+func (s *FileStore) Encrypted() (bool, error) {
+	data, err := os.ReadFile(s.DataFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read data file %s: %w", s.DataFilePath(), err)
+	}
+	return isEncrypted(data), nil
+}
+
+// SetEncryption はデータファイル、バックアップディレクトリ内のすべてのファイルと同期の共通の祖先を新しいパスフレーズで暗号化し直します。
+// 空文字列を指定すると平文に戻します。すべてのファイルを復号できた場合にのみ書き換えを行います。
+// 書き換えるファイルはすべて一時ファイルに書き込み、すべて成功した後に rename で置き換えるため、
+// 途中で失敗しても古いパスフレーズと新しいパスフレーズのファイルが混在しません。
+func (s *FileStore) SetEncryption(passphrase string) error {
+	if err := ensureDir(s.dir); err != nil {
+		return err
+	}
+	unlock, err := acquireLock(filepath.Join(s.dir, lockFile), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files := []string{s.DataFilePath()}
+	if entries, err := os.ReadDir(s.BackupDirPath()); err == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() && (isBackupFileName(name) ||
+				strings.HasPrefix(name, premigrationFilePrefix) && strings.HasSuffix(name, backupFileSuffix)) {
+				files = append(files, filepath.Join(s.BackupDirPath(), name))
+			}
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(s.dir, syncBaseDir)); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(s.dir, syncBaseDir, entry.Name()))
+			}
+		}
+	}
+
+	// 先にすべてのファイルを復号し、1つでも失敗した場合は何も書き換えない
+	plaintexts := make([][]byte, len(files))
+	for i, path := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && i == 0 {
+			if data, err = MarshalTasks(NewTasks()); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if plaintexts[i], _, err = decryptIfNeeded(data); err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+	}
+	journal, err := s.journalLines()
+	if err != nil {
+		return err
+	}
+
+	// 新しいパスフレーズですべての内容を暗号化する。失敗した場合は元のパスフレーズに戻す
+	oldPassphrase := keys.current()
+	keys.reset(passphrase)
+	contents := make(map[string][]byte, len(files)+1)
+	for i, path := range files {
+		data, err := encryptIf(plaintexts[i], passphrase != "")
+		if err != nil {
+			keys.reset(oldPassphrase)
+			return err
+		}
+		contents[path] = data
+	}
+	if journal != nil {
+		// ジャーナルは行ごとに暗号化する
+		var buf bytes.Buffer
+		for _, line := range journal {
+			data, err := encryptIf(line, passphrase != "")
+			if err != nil {
+				keys.reset(oldPassphrase)
+				return err
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		files = append(files, s.JournalFilePath())
+		contents[s.JournalFilePath()] = buf.Bytes()
+	}
+
+	// すべての一時ファイルを書き込めた場合にだけ置き換える
+	temps := make([]string, 0, len(files))
+	for _, path := range files {
+		tmp, err := writeTempFile(path, contents[path], filePerm)
+		if err != nil {
+			for _, t := range temps {
+				os.Remove(t)
+			}
+			keys.reset(oldPassphrase)
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		temps = append(temps, tmp)
+	}
+	dirs := make(map[string]bool)
+	for i, path := range files {
+		if err := os.Rename(temps[i], path); err != nil {
+			for _, t := range temps[i:] {
+				os.Remove(t)
+			}
+			return fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go-task/internal/task"
+)
+
+// FileStore はディレクトリ内の JSON ファイルにタスクデータを保存する Store の実装です。
+// タスクデータは tasks.json、テンプレートは templates.json、バックアップは backup/ 以下に保存されます。
+type FileStore struct {
+	dir           string
+	backupOptions BackupOptions
+	// seen は最後に読み込んだ、または保存した時点のデータファイルとジャーナルの状態です (Modified を参照)。
+	seen *fileStamps
+	// historyMark は git のコミットメッセージに含めた最後の変更履歴です (commitGit を参照)。
+	historyMark *task.HistoryEntry
+	// encryptNew はデータファイルがまだない場合に、最初の保存で暗号化するかです (SetEncryptNew を参照)。
+	encryptNew bool
+}
+
+// NewFileStore は指定されたディレクトリを使用する FileStore を作成します。
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir, backupOptions: DefaultBackupOptions()}
+}
+
+// SetBackupOptions はバックアップの保持ルールと圧縮の設定を変更します。
+func (s *FileStore) SetBackupOptions(opts BackupOptions) {
+	s.backupOptions = opts
+}
+
+// SetEncryptNew はデータファイルがまだない場合に、最初の保存でデータファイルを暗号化するかを設定します。
+// 暗号化には SetPassphrase で設定したパスフレーズを使用します。既存のデータファイルの暗号化の状態は変わりません。
+func (s *FileStore) SetEncryptNew(encrypt bool) {
+	s.encryptNew = encrypt
+}
+
+// Dir はデータディレクトリのパスを返します。
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// DataFilePath はデータファイルのパスを返します。
+func (s *FileStore) DataFilePath() string {
+	return filepath.Join(s.dir, dataFile)
+}
+
+// BackupDirPath はバックアップディレクトリのパスを返します。
+func (s *FileStore) BackupDirPath() string {
+	return filepath.Join(s.dir, backupDir)
+}
+
+// Load はデータファイルからタスクを読み込みます。
+// ジャーナル形式 (JournalStore) で追記された変更がある場合は、それも適用します。
+// データファイルやジャーナルを解析できない場合は ErrCorrupted を返します。Recover で復旧できます。
+// 古いスキーマのファイルは変換前の内容をバックアップディレクトリに退避した上で、現在のスキーマに変換して読み込みます。
+func (s *FileStore) Load() (*task.Tasks, error) {
+	snap, err := s.Peek()
+	if err != nil {
+		return nil, err
+	}
+	s.Adopt(snap)
+	return snap.Tasks, nil
+}
+
+// Peek は Load と同じくタスクデータを読み込みますが、読み込んだことを記録しません。
+// FileStore のフィールドを変更しないため、Save と並行して別の goroutine から呼び出せます。
+func (s *FileStore) Peek() (*Snapshot, error) {
+	tasks, disk, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Tasks: tasks, disk: disk}, nil
+}
+
+// Adopt は Peek で読み込んだ時点のファイルの状態を、このプロセスが読み込んだ状態として記録します。
+func (s *FileStore) Adopt(snap *Snapshot) {
+	s.markSeen(fileStamps{snapshot: snap.disk.snapshotStamp, journal: snap.disk.journalStamp})
+	s.markHistory(snap.Tasks)
+}
+
+// loadSnapshot はデータファイルからタスクを読み込み、ファイルが暗号化されているかと共に返します。ジャーナルは適用しません。
+func (s *FileStore) loadSnapshot() (*task.Tasks, bool, error) {
+	filePath := s.DataFilePath()
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		// ファイルが存在しない場合は新しいTasks構造体を返す
+		return NewTasks(), false, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read data file %s: %w", filePath, err)
+	}
+	plaintext, encrypted, err := decryptIfNeeded(data)
+	if err != nil {
+		return nil, encrypted, err
+	}
+	tasks, version, err := decodeTasks(plaintext)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedVersion) || errors.Is(err, ErrUnknownVersion) {
+			return nil, encrypted, err
+		}
+		return nil, encrypted, fmt.Errorf("%w: %s: %v", ErrCorrupted, filePath, err)
+	}
+	if version != CurrentVersion {
+		// 古いスキーマのファイルは次の保存で上書きされるため、変換前の内容を退避しておく
+		if err := s.backupBeforeMigration(data, version); err != nil {
+			return nil, encrypted, err
+		}
+	}
+	return tasks, encrypted, nil
+}
+
+// Save はタスクをデータファイルに保存します。
+// 保存はプロセス間のロックを取得した上で行い、読み込んだ後に他のプロセスがデータファイルを
+// 更新していた場合 (リビジョンが一致しない場合) は上書きせずに ErrConflict を返します。
+// データファイルが暗号化されている場合は、設定されたパスフレーズで暗号化して保存します。
+// ジャーナルがある場合は、その内容を含むタスクデータ全体を書き出した後にジャーナルを削除します。
+func (s *FileStore) Save(tasks *task.Tasks) error {
+	if err := ensureDir(s.dir); err != nil {
+		return err
+	}
+
+	unlock, err := acquireLock(filepath.Join(s.dir, lockFile), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, encrypted, err := s.currentRevision()
+	if err != nil {
+		return err
+	}
+	if s.encryptNew && !fileExists(s.DataFilePath()) {
+		encrypted = true
+	}
+	if current != tasks.Revision {
+		return fmt.Errorf("%w (loaded revision %d, current revision %d)", ErrConflict, tasks.Revision, current)
+	}
+
+	// 書き込みに失敗した場合は元のリビジョンと更新日時に戻す
+	prevRevision, prevUpdatedAt := tasks.Revision, tasks.UpdatedAt
+	tasks.Revision++
+	tasks.UpdatedAt = time.Now() // 更新日時を自動更新
+
+	data, err := MarshalTasks(tasks)
+	if err == nil {
+		data, err = encryptIf(data, encrypted)
+	}
+	if err == nil {
+		filePath := s.DataFilePath()
+		if err = WriteFileAtomic(filePath, data, filePerm); err != nil {
+			err = fmt.Errorf("failed to write data file %s: %w", filePath, err)
+		}
+	}
+	if err != nil {
+		tasks.Revision, tasks.UpdatedAt = prevRevision, prevUpdatedAt
+		return err
+	}
+	// データファイルより古いジャーナルの行は読み込み時に無視されるため、削除やコミットに失敗しても保存は完了している
+	err = s.removeJournal()
+	if gitErr := s.commitGit(tasks, prevUpdatedAt); err == nil {
+		err = gitErr
+	}
+	s.markSeen(s.stamps())
+	return err
+}
+
+// currentRevision はデータファイルにジャーナルを適用した後のリビジョンと、ファイルが暗号化されているかを返します。
+// ファイルが存在しない場合のリビジョンは 0 です。
+func (s *FileStore) currentRevision() (int64, bool, error) {
+	disk, err := s.readDiskState()
+	return disk.revision, disk.encrypted, err
+}
+
+// CreateBackup は現在のタスクデータのバックアップを作成します。
+// 設定に応じて gzip で圧縮し、データファイルが暗号化されている場合はバックアップも暗号化します。
+func (s *FileStore) CreateBackup(tasks *task.Tasks) error {
+	backupPath := s.BackupDirPath()
+	if err := ensureDir(backupPath); err != nil {
+		return err
+	}
+
+	suffix := backupFileSuffix
+	if s.backupOptions.Compress {
+		suffix = compressedBackupSuffix
+	}
+	// 同じ秒に複数のバックアップを作成した場合は連番を付けて上書きを避ける
+	timestamp := time.Now().Format(backupFileTimeLayout)
+	backupFilePath := filepath.Join(backupPath, backupFilePrefix+timestamp+suffix)
+	for n := 2; fileExists(backupFilePath); n++ {
+		backupFilePath = filepath.Join(backupPath, fmt.Sprintf("%s%s-%d%s", backupFilePrefix, timestamp, n, suffix))
+	}
+
+	data, err := MarshalTasks(tasks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tasks for backup: %w", err)
+	}
+	if s.backupOptions.Compress {
+		if data, err = compress(data); err != nil {
+			return err
+		}
+	}
+	encrypted, err := s.Encrypted()
+	if err != nil {
+		return err
+	}
+	if data, err = encryptIf(data, encrypted); err != nil {
+		return err
+	}
+
+	if err := WriteFileAtomic(backupFilePath, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write backup file %s: %w", backupFilePath, err)
+	}
+	return nil
+}
+
+// CleanOldBackups は保持ルールに該当しない古いバックアップファイルを削除します。
+func (s *FileStore) CleanOldBackups() error {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	keep := s.backupOptions.Retention.keep(backups)
+	for _, backup := range backups {
+		if keep[backup.Name] {
+			continue
+		}
+		filePath := filepath.Join(s.BackupDirPath(), backup.Name)
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("failed to remove old backup file %s: %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups はバックアップファイルの一覧を新しい順に返します。
+func (s *FileStore) ListBackups() ([]BackupInfo, error) {
+	files, err := os.ReadDir(s.BackupDirPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // バックアップディレクトリが存在しない場合は空
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []BackupInfo
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !isBackupFileName(name) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		// ファイル名の日時を優先し、解析できない場合は更新日時を使用する
+		createdAt := info.ModTime()
+		if t, ok := backupTime(name); ok {
+			createdAt = t
+		}
+		backups = append(backups, BackupInfo{Name: name, CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	// 同じ秒に作成したバックアップは連番の大きい (名前の長い、または後の) ものを新しいとみなす
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backupSequence(backups[i].Name) > backupSequence(backups[j].Name)
+	})
+	return backups, nil
+}
+
+// LoadBackup はバックアップディレクトリ内の指定された名前のバックアップファイルを読み込みます。
+func (s *FileStore) LoadBackup(name string) (*task.Tasks, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid backup name %q", name)
+	}
+	return ReadTasksFile(filepath.Join(s.BackupDirPath(), name))
+}
+
+// LoadTemplates はテンプレートファイルからタスクテンプレートを読み込みます。
+// ファイルが存在しない場合は空のリストを返します。
+func (s *FileStore) LoadTemplates() ([]task.Template, error) {
+	filePath := filepath.Join(s.dir, templatesFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []task.Template{}, nil
+		}
+		return nil, fmt.Errorf("failed to read templates file %s: %w", filePath, err)
+	}
+
+	var templates []task.Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal templates: %w", err)
+	}
+	return templates, nil
+}
+
+// SaveTemplates はタスクテンプレートをテンプレートファイルに保存します。
+func (s *FileStore) SaveTemplates(templates []task.Template) error {
+	if err := ensureDir(s.dir); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal templates: %w", err)
+	}
+
+	filePath := filepath.Join(s.dir, templatesFile)
+	if err := WriteFileAtomic(filePath, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write templates file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// ReadTasksFile はタスクデータやバックアップのファイルを読み込みます。暗号化されたファイルは復号し、gzip で圧縮されたファイルは展開して読み込みます。
+func ReadTasksFile(filePath string) (*task.Tasks, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file %s: %w", filePath, err)
+	}
+	if data, _, err = decryptIfNeeded(data); err != nil {
+		return nil, err
+	}
+	if data, err = decompressIfNeeded(data); err != nil {
+		return nil, err
+	}
+	return UnmarshalTasks(data)
+}
+
+// fileExists はファイルが存在するかを返します。
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-task/internal/task"
+)
+
+const (
+	// gitDir はデータディレクトリを git リポジトリにした場合に作成されるディレクトリです。
+	gitDir = ".git"
+	// maxGitValueLength はコミットメッセージに含めるフィールドの値の最大文字数です。
+	maxGitValueLength = 40
+)
+
+// ErrGit は git コマンドの実行に失敗したことを表します。
+var ErrGit = errors.New("git command failed")
+
+// ErrGitCommit は保存したタスクデータの git へのコミットに失敗したことを表します。タスクデータの保存は完了しています。
+// 失敗した git コマンドの ErrGit と一緒に返されるため、errors.Is(err, ErrGit) も true になります。
+var ErrGitCommit = errors.New("git commit after save failed")
+
+// GitCommit はデータディレクトリの git リポジトリのコミットです。
+type GitCommit struct {
+	Hash    string
+	Time    time.Time
+	Subject string
+}
+
+// GitHistory はタスクデータの保存を git のコミットとして記録できる Store が実装するインターフェースです。
+type GitHistory interface {
+	// GitEnabled はデータディレクトリが git リポジトリで、保存のたびにコミットするかを返します。
+	GitEnabled() bool
+	// InitGit はデータディレクトリを git リポジトリにし、現在のタスクデータを最初のコミットとして記録します。
+	InitGit() error
+	// GitLog はタスクデータのコミットを新しい順に最大 limit 件返します。limit が 0 以下の場合はすべて返します。
+	GitLog(limit int) ([]GitCommit, error)
+	// LoadGitCommit は指定されたコミットの時点のタスクデータを読み込みます。
+	LoadGitCommit(rev string) (*task.Tasks, error)
+}
+
+// GitEnabled はデータディレクトリに .git があるかを返します。
+// 親ディレクトリのリポジトリ (プロジェクトの .go-task など) は対象外です。
+func (s *FileStore) GitEnabled() bool {
+	_, err := os.Stat(filepath.Join(s.dir, gitDir))
+	return err == nil
+}
+
+// InitGit はローカルの git コマンドでデータディレクトリを git リポジトリにし、タスクデータを最初のコミットとして記録します。
+// git のユーザー名とメールアドレスが設定されていない場合は、このリポジトリにだけ go-task の名前を設定します。
+func (s *FileStore) InitGit() error {
+	if err := ensureDir(s.dir); err != nil {
+		return err
+	}
+	unlock, err := acquireLock(filepath.Join(s.dir, lockFile), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := s.git("init", "-q"); err != nil {
+		return err
+	}
+	if _, err := s.git("config", "user.email"); err != nil {
+		if _, err := s.git("config", "user.name", "go-task"); err != nil {
+			return err
+		}
+		if _, err := s.git("config", "user.email", "go-task@localhost"); err != nil {
+			return err
+		}
+	}
+	return s.commitFiles("init: start task history", "")
+}
+
+// GitLog はタスクデータのファイルを変更したコミットを新しい順に返します。
+func (s *FileStore) GitLog(limit int) ([]GitCommit, error) {
+	args := []string{"log", "--format=%H%x1f%aI%x1f%s"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("-n%d", limit))
+	}
+	out, err := s.git(append(args, "--", dataFile, journalFile)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []GitCommit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		committed, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: unexpected git log output %q", ErrGit, line)
+		}
+		commits = append(commits, GitCommit{Hash: fields[0], Time: committed, Subject: fields[2]})
+	}
+	return commits, nil
+}
+
+// LoadGitCommit は指定されたコミットのデータファイルとジャーナルを一時ディレクトリに取り出し、Load と同じ方法で読み込みます。
+func (s *FileStore) LoadGitCommit(rev string) (*task.Tasks, error) {
+	out, err := s.git("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown commit %q: %w", rev, err)
+	}
+	hash := strings.TrimSpace(string(out))
+
+	tmpDir, err := os.MkdirTemp("", "go-task-git-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{dataFile, journalFile} {
+		data, err := s.git("show", hash+":"+name)
+		if err != nil {
+			// コミットの時点で存在しなかったファイル (ジャーナルなど) は取り出さない
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), data, filePerm); err != nil {
+			return nil, fmt.Errorf("failed to write %s from commit %s: %w", name, rev, err)
+		}
+	}
+	if !fileExists(filepath.Join(tmpDir, dataFile)) {
+		return nil, fmt.Errorf("%w: commit %s has no %s", ErrGit, rev, dataFile)
+	}
+	return NewFileStore(tmpDir).Load()
+}
+
+// commitGit は git の履歴が有効な場合に、保存したタスクデータをコミットします。コミットに失敗した場合は ErrGitCommit を返します。
+// コミットメッセージは前回のコミット以降に追加された変更履歴から作成します。since は保存前のタスクデータの更新日時です。
+// タスクデータが暗号化されている場合は、タイトルや値を平文で .git に残さないよう、変更したタスクの件数だけを記録します。
+// 保存中 (ロックの取得中) に呼び出します。
+func (s *FileStore) commitGit(tasks *task.Tasks, since time.Time) error {
+	if !s.GitEnabled() {
+		// git の履歴を有効にした後の最初のコミットに、それまでの変更を含めない
+		s.markHistory(tasks)
+		return nil
+	}
+	entries := s.newHistory(tasks.History, since)
+	var subject, body string
+	// 暗号化されているか判定できない場合も内容を含めない
+	if encrypted, err := s.Encrypted(); err != nil || encrypted {
+		subject = encryptedGitCommitMessage(entries)
+	} else {
+		subject, body = gitCommitMessage(tasks, entries)
+	}
+	if err := s.commitFiles(subject, body); err != nil {
+		return fmt.Errorf("%w: %w", ErrGitCommit, err)
+	}
+	s.markHistory(tasks)
+	return nil
+}
+
+// commitFiles はデータファイルとジャーナルの変更をステージしてコミットします。変更がない場合は何もしません。
+func (s *FileStore) commitFiles(subject, body string) error {
+	if _, err := s.git("add", "--all", "--", dataFile); err != nil {
+		return err
+	}
+	if fileExists(s.JournalFilePath()) {
+		_, err := s.git("add", "--all", "--", journalFile)
+		if err != nil {
+			return err
+		}
+	} else if _, err := s.git("rm", "--cached", "--quiet", "--ignore-unmatch", "--", journalFile); err != nil {
+		return err
+	}
+
+	// diff --quiet は差分がある場合に終了コード 1 を返す
+	if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	args := []string{"commit", "--quiet", "--no-verify", "-m", subject}
+	if body != "" {
+		args = append(args, "-m", body)
+	}
+	_, err := s.git(args...)
+	return err
+}
+
+// markHistory は変更履歴の最後のエントリを、コミットメッセージに含めた最後の変更として記録します。
+func (s *FileStore) markHistory(tasks *task.Tasks) {
+	s.historyMark = &task.HistoryEntry{}
+	if n := len(tasks.History); n > 0 {
+		*s.historyMark = tasks.History[n-1]
+	}
+}
+
+// newHistory は markHistory で記録した変更より後に追加された変更履歴を返します。
+// 読み込まずに保存した場合は since より新しい日時の変更を、記録した変更が古い履歴の削除で見つからない場合は
+// それより新しい日時の変更を返します。
+func (s *FileStore) newHistory(history []task.HistoryEntry, since time.Time) []task.HistoryEntry {
+	if mark := s.historyMark; mark != nil {
+		for i := len(history) - 1; i >= 0; i-- {
+			e := history[i]
+			if e.TaskID == mark.TaskID && e.Field == mark.Field && e.Source == mark.Source && e.Timestamp.Equal(mark.Timestamp) &&
+				e.OldValue == mark.OldValue && e.NewValue == mark.NewValue {
+				return history[i+1:]
+			}
+		}
+		since = mark.Timestamp
+	}
+	var entries []task.HistoryEntry
+	for _, e := range history {
+		if e.Timestamp.After(since) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// gitCommitMessage は変更履歴からコミットメッセージの件名と本文を作成します。
+// 1件のタスクごとに "update: Buy groceries status TODO→DONE" の形式の行を作成し、
+// 最初の行を件名、複数のタスクを変更した場合はすべての行を本文にします。
+func gitCommitMessage(tasks *task.Tasks, entries []task.HistoryEntry) (string, string) {
+	if len(entries) == 0 {
+		return "update: task data", ""
+	}
+
+	titles := make(map[string]string)
+	for _, e := range entries {
+		titles[e.TaskID] = ""
+	}
+	for _, t := range tasks.Tasks {
+		if _, ok := titles[t.ID]; ok {
+			titles[t.ID] = t.Title
+		}
+	}
+	// 削除されたタスクは変更履歴に残る削除前のタイトルを使用する
+	for _, e := range entries {
+		if e.Field == "title" && titles[e.TaskID] == "" {
+			titles[e.TaskID] = e.OldValue
+		}
+	}
+
+	// 操作とタスクの組ごとに1行にまとめる
+	type change struct {
+		head   string
+		fields []string
+	}
+	var changes []*change
+	byKey := make(map[string]*change)
+	for _, e := range entries {
+		key := e.Source + "\x00" + e.TaskID
+		c, ok := byKey[key]
+		if !ok {
+			c = &change{head: e.Source + ": " + titles[e.TaskID]}
+			byKey[key] = c
+			changes = append(changes, c)
+		}
+		// 追加と削除はタイトルだけを示す
+		if e.Source != "add" && e.Source != "delete" {
+			c.fields = append(c.fields, fmt.Sprintf("%s %s→%s", e.Field, shortenGitValue(e.OldValue), shortenGitValue(e.NewValue)))
+		}
+	}
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		lines[i] = strings.TrimSpace(c.head + " " + strings.Join(c.fields, ", "))
+	}
+
+	if len(lines) == 1 {
+		return lines[0], ""
+	}
+	return fmt.Sprintf("%s (+%d more)", lines[0], len(lines)-1), strings.Join(lines, "\n")
+}
+
+// encryptedGitCommitMessage は暗号化されたタスクデータのコミットメッセージの件名を作成します。
+// "update: 2 task(s)" のように、変更したタスクの件数だけを含めます。
+func encryptedGitCommitMessage(entries []task.HistoryEntry) string {
+	if len(entries) == 0 {
+		return "update: task data"
+	}
+	ids := make(map[string]bool)
+	for _, e := range entries {
+		ids[e.TaskID] = true
+	}
+	return fmt.Sprintf("update: %d task(s)", len(ids))
+}
+
+// shortenGitValue はコミットメッセージに含めるフィールドの値を1行にし、長い場合は切り詰めます。空の値は "-" にします。
+func shortenGitValue(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return "-"
+	}
+	if utf8.RuneCountInString(value) > maxGitValueLength {
+		value = string([]rune(value)[:maxGitValueLength-1]) + "…"
+	}
+	return value
+}
+
+// git はデータディレクトリでローカルの git コマンドを実行し、標準出力を返します。
+func (s *FileStore) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", s.dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: git %s: %v: %s", ErrGit, args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%w: git %s: %v", ErrGit, args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,757 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"go-task/internal/task"
+)
+
+const (
+	// journalFile はジャーナル形式で保存の差分を追記するファイルです。
+	journalFile = "tasks.journal"
+	// DefaultCompactAfter はジャーナルをスナップショット (tasks.json) に書き戻すまでに追記する保存の回数の既定値です。
+	DefaultCompactAfter = 500
+)
+
+// 保存形式の名前です。
+const (
+	FormatJSON    = "json"    // 保存のたびに tasks.json 全体を書き換える
+	FormatJournal = "journal" // 変更を tasks.journal に追記し、定期的に tasks.json へ書き戻す
+)
+
+// StorageOptions はタスクデータの保存形式の設定です。
+type StorageOptions struct {
+	Format       string // FormatJSON または FormatJournal。空の場合は FormatJSON
+	CompactAfter int    // FormatJournal でスナップショットに書き戻すまでの保存の回数。0 以下の場合は DefaultCompactAfter
+}
+
+// OpenStore は dir にタスクデータを保存する Store を、指定された保存形式で返します。
+// どちらの形式でも tasks.json と tasks.journal の両方を読み込むため、形式はいつでも切り替えられます。
+func OpenStore(dir string, opts StorageOptions) (Store, error) {
+	switch opts.Format {
+	case "", FormatJSON:
+		return NewFileStore(dir), nil
+	case FormatJournal:
+		return NewJournalStore(dir, opts.CompactAfter), nil
+	}
+	return nil, fmt.Errorf("unknown storage format %q (use %q or %q)", opts.Format, FormatJSON, FormatJournal)
+}
+
+// Compacter はジャーナルのコンパクションに対応した Store が実装するインターフェースです。
+type Compacter interface {
+	// Compact はタスクデータを新しいスナップショットとして保存し、ジャーナルを削除します。
+	Compact(tasks *task.Tasks) error
+}
+
+// JournalStore は保存のたびに前回の保存からの差分を JSON Lines 形式でジャーナルに追記する Store の実装です。
+// 読み込み時は最後のスナップショット (tasks.json) にジャーナルを順に適用し、追記が CompactAfter 回に達すると
+// 新しいスナップショットを書き出してジャーナルを空にします (コンパクション)。
+// バックアップ、テンプレート、暗号化、復旧は FileStore と同じです。
+type JournalStore struct {
+	*FileStore
+	compactAfter int
+	// base は最後に読み込んだ、または保存したタスクデータの写しで、次の保存の差分を求めるために使用します。
+	base *journalState
+	// disk は base に対応するデータファイルとジャーナルの状態です。
+	disk diskState
+}
+
+// NewJournalStore は指定されたディレクトリを使用する JournalStore を作成します。
+// compactAfter が 0 以下の場合は DefaultCompactAfter を使用します。
+func NewJournalStore(dir string, compactAfter int) *JournalStore {
+	if compactAfter <= 0 {
+		compactAfter = DefaultCompactAfter
+	}
+	return &JournalStore{FileStore: NewFileStore(dir), compactAfter: compactAfter}
+}
+
+// JournalFilePath はジャーナルのパスを返します。
+func (s *FileStore) JournalFilePath() string {
+	return filepath.Join(s.dir, journalFile)
+}
+
+// Load はスナップショットにジャーナルを適用したタスクデータを読み込みます。
+func (s *JournalStore) Load() (*task.Tasks, error) {
+	snap, err := s.Peek()
+	if err != nil {
+		return nil, err
+	}
+	s.Adopt(snap)
+	return snap.Tasks, nil
+}
+
+// Adopt は Peek で読み込んだタスクデータを、次の保存で差分を求める元の内容として記録します。
+func (s *JournalStore) Adopt(snap *Snapshot) {
+	s.base = newJournalState(snap.Tasks)
+	s.disk = snap.disk
+	s.FileStore.Adopt(snap)
+}
+
+// Save は前回の読み込みまたは保存からの差分をジャーナルに1行追記します。
+// スナップショットがない場合や、追記の回数が CompactAfter に達した場合は Compact と同じくスナップショットを書き出します。
+// 他のプロセスによる更新の検出は FileStore.Save と同じです。
+func (s *JournalStore) Save(tasks *task.Tasks) error {
+	return s.save(tasks, false)
+}
+
+// Compact はタスクデータを新しいスナップショットとして保存し、ジャーナルを削除します。
+func (s *JournalStore) Compact(tasks *task.Tasks) error {
+	return s.save(tasks, true)
+}
+
+// save はタスクデータをジャーナルへの追記、またはスナップショットの書き出しで保存します。
+func (s *JournalStore) save(tasks *task.Tasks, compact bool) error {
+	if err := ensureDir(s.dir); err != nil {
+		return err
+	}
+	unlock, err := acquireLock(filepath.Join(s.dir, lockFile), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	if s.disk.revision != tasks.Revision {
+		return fmt.Errorf("%w (loaded revision %d, current revision %d)", ErrConflict, tasks.Revision, s.disk.revision)
+	}
+
+	// 書き込みに失敗した場合は元のリビジョンと更新日時に戻す
+	prevRevision, prevUpdatedAt := tasks.Revision, tasks.UpdatedAt
+	tasks.Revision++
+	tasks.UpdatedAt = time.Now()
+
+	if compact || !s.disk.snapshot || s.base == nil || s.base.tasks.Revision != prevRevision || s.disk.entries+1 >= s.compactAfter {
+		err = s.writeSnapshot(tasks)
+	} else {
+		err = s.appendEntry(tasks)
+	}
+	if err != nil {
+		tasks.Revision, tasks.UpdatedAt = prevRevision, prevUpdatedAt
+		return err
+	}
+	// 保存は完了しているため、コミットに失敗してもリビジョンは戻さない
+	return s.commitGit(tasks, prevUpdatedAt)
+}
+
+// refresh はデータファイルとジャーナルが最後の読み込みまたは保存の後に変更されている場合に、その状態を読み直します。
+// 変更されていなければファイルを読まずに済ませます。
+func (s *JournalStore) refresh() error {
+	snapshot, journal := statFile(s.DataFilePath()), statFile(s.JournalFilePath())
+	if s.disk.valid && snapshot == s.disk.snapshotStamp && journal == s.disk.journalStamp {
+		return nil
+	}
+	disk, err := s.readDiskState()
+	if err != nil {
+		return err
+	}
+	s.disk = disk
+	return nil
+}
+
+// appendEntry は前回の保存からの差分をジャーナルに追記します。
+func (s *JournalStore) appendEntry(tasks *task.Tasks) error {
+	entry := s.base.diff(tasks)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	if line, err = encryptIf(line, s.disk.encrypted); err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	path := s.JournalFilePath()
+	created := !fileExists(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open journal %s: %w", path, err)
+	}
+	// 保存の途中で中断された書きかけの行が残っている場合は、切り詰めてから追記する
+	err = f.Truncate(s.disk.journalSize)
+	if err == nil {
+		_, err = f.WriteAt(line, s.disk.journalSize)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && created {
+		err = syncDir(s.dir)
+	}
+	if err != nil {
+		// 追記できなかった行は次の保存で切り詰められる
+		s.disk.valid = false
+		return fmt.Errorf("failed to append to journal %s: %w", path, err)
+	}
+
+	s.base.commit(entry)
+	s.disk.revision = tasks.Revision
+	s.disk.entries++
+	s.disk.journalSize += int64(len(line))
+	s.disk.journalStamp = statFile(path)
+	s.markSeen(fileStamps{snapshot: s.disk.snapshotStamp, journal: s.disk.journalStamp})
+	return nil
+}
+
+// writeSnapshot はタスクデータ全体をスナップショットとして書き出し、ジャーナルを削除します。
+// ジャーナルの削除前に中断しても、スナップショットより古いジャーナルの行は読み込み時に無視されます。
+func (s *JournalStore) writeSnapshot(tasks *task.Tasks) error {
+	data, err := MarshalTasks(tasks)
+	if err == nil {
+		data, err = encryptIf(data, s.disk.encrypted)
+	}
+	if err == nil {
+		filePath := s.DataFilePath()
+		if err = WriteFileAtomic(filePath, data, filePerm); err != nil {
+			err = fmt.Errorf("failed to write data file %s: %w", filePath, err)
+		}
+	}
+	if err == nil {
+		err = s.removeJournal()
+	}
+	if err != nil {
+		s.disk.valid = false
+		return err
+	}
+
+	s.base = newJournalState(tasks)
+	s.disk = diskState{
+		valid:         true,
+		revision:      tasks.Revision,
+		encrypted:     s.disk.encrypted,
+		snapshot:      true,
+		snapshotStamp: statFile(s.DataFilePath()),
+		journalStamp:  statFile(s.JournalFilePath()),
+	}
+	s.markSeen(fileStamps{snapshot: s.disk.snapshotStamp, journal: s.disk.journalStamp})
+	return nil
+}
+
+// removeJournal はジャーナルを削除します。ジャーナルがない場合は何もしません。
+func (s *FileStore) removeJournal() error {
+	path := s.JournalFilePath()
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove journal %s: %w", path, err)
+	}
+	return syncDir(s.dir)
+}
+
+// fileStamp はファイルのサイズと更新日時です。ファイルが他のプロセスに変更されたかの判定に使用します。
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// statFile はファイルの fileStamp を返します。
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// diskState はデータファイルとジャーナルの状態です。
+type diskState struct {
+	valid       bool  // 以下の値が読み込み済みか
+	revision    int64 // ジャーナルを適用した後のリビジョン
+	encrypted   bool  // データファイルが暗号化されているか
+	snapshot    bool  // データファイルが存在するか
+	entries     int   // スナップショットより新しいジャーナルの行数
+	journalSize int64 // ジャーナルの完全に書き込まれた行の末尾の位置
+
+	snapshotStamp fileStamp
+	journalStamp  fileStamp
+}
+
+// readDiskState はタスクデータを読み込まずに、データファイルのリビジョンとジャーナルの状態を読み込みます。
+func (s *FileStore) readDiskState() (diskState, error) {
+	disk := diskState{snapshotStamp: statFile(s.DataFilePath()), journalStamp: statFile(s.JournalFilePath())}
+	data, err := os.ReadFile(s.DataFilePath())
+	if err != nil && !os.IsNotExist(err) {
+		return disk, fmt.Errorf("failed to read data file %s: %w", s.DataFilePath(), err)
+	}
+	if err == nil {
+		disk.snapshot = true
+		if data, disk.encrypted, err = decryptIfNeeded(data); err != nil {
+			return disk, err
+		}
+		var header struct {
+			Revision int64 `json:"revision"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return disk, fmt.Errorf("failed to unmarshal tasks data: %w", err)
+		}
+		disk.revision = header.Revision
+	}
+
+	info, err := s.readJournal(disk.revision, nil)
+	if err != nil {
+		return disk, err
+	}
+	disk.revision, disk.entries, disk.journalSize = info.revision, info.entries, info.size
+	disk.valid = true
+	return disk, nil
+}
+
+// load はスナップショットを読み込み、ジャーナルを適用したタスクデータと、読み込んだ時点のファイルの状態を返します。
+func (s *FileStore) load() (*task.Tasks, diskState, error) {
+	disk := diskState{snapshotStamp: statFile(s.DataFilePath()), journalStamp: statFile(s.JournalFilePath())}
+	tasks, encrypted, err := s.loadSnapshot()
+	if err != nil {
+		return nil, disk, err
+	}
+	disk.snapshot, disk.encrypted = disk.snapshotStamp.exists, encrypted
+
+	r := newReplayer(tasks)
+	info, err := s.readJournal(tasks.Revision, r.apply)
+	if err != nil {
+		return nil, disk, err
+	}
+	disk.valid = true
+	disk.revision, disk.entries, disk.journalSize = info.revision, info.entries, info.size
+	return tasks, disk, nil
+}
+
+// journalInfo はジャーナルを読み込んだ結果です。
+type journalInfo struct {
+	revision int64 // 最後に適用した変更のリビジョン。適用した変更がない場合はスナップショットのリビジョン
+	entries  int   // スナップショットより新しい変更の数
+	size     int64 // 完全に書き込まれた行の末尾の位置。末尾の書きかけの行は含まない
+}
+
+// readJournal はジャーナルを先頭から読み込み、スナップショットのリビジョン snapshotRevision より新しい変更を順に apply に渡します。
+// apply が nil の場合はリビジョンだけを読み込みます。保存の途中で中断された末尾の書きかけの行は無視します。
+// 解析できない行やリビジョンの欠落がある場合は ErrCorrupted を返します。それまでの行は apply に渡されています。
+func (s *FileStore) readJournal(snapshotRevision int64, apply func(*journalEntry) error) (journalInfo, error) {
+	info := journalInfo{revision: snapshotRevision}
+	path := s.JournalFilePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info, nil
+		}
+		return info, fmt.Errorf("failed to read journal %s: %w", path, err)
+	}
+
+	for lineNo := 1; ; lineNo++ {
+		rest := data[info.size:]
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return info, nil
+		}
+		plaintext, _, err := decryptIfNeeded(rest[:end])
+		if err != nil {
+			if errors.Is(err, ErrPassphraseRequired) || errors.Is(err, ErrWrongPassphrase) {
+				return info, err
+			}
+			return info, fmt.Errorf("%w: %s line %d: %v", ErrCorrupted, path, lineNo, err)
+		}
+
+		var entry journalEntry
+		var header struct {
+			Revision int64 `json:"revision"`
+		}
+		if apply != nil {
+			err = json.Unmarshal(plaintext, &entry)
+			header.Revision = entry.Revision
+		} else {
+			err = json.Unmarshal(plaintext, &header)
+		}
+		if err != nil {
+			return info, fmt.Errorf("%w: %s line %d: %v", ErrCorrupted, path, lineNo, err)
+		}
+		// スナップショットに含まれている変更 (コンパクションの途中で中断された場合の残り) は読み飛ばす
+		if header.Revision > info.revision {
+			if header.Revision != info.revision+1 {
+				return info, fmt.Errorf("%w: %s line %d: expected revision %d, found %d", ErrCorrupted, path, lineNo, info.revision+1, header.Revision)
+			}
+			if apply != nil {
+				if err := apply(&entry); err != nil {
+					return info, fmt.Errorf("%w: %s line %d: %v", ErrCorrupted, path, lineNo, err)
+				}
+			}
+			info.revision = header.Revision
+			info.entries++
+		}
+		info.size += int64(end + 1)
+	}
+}
+
+// journalLines はジャーナルの完全に書き込まれた各行を復号して返します。ジャーナルがない場合は nil を返します。
+func (s *FileStore) journalLines() ([][]byte, error) {
+	path := s.JournalFilePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal %s: %w", path, err)
+	}
+	lines := [][]byte{}
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return lines, nil
+		}
+		line, _, err := decryptIfNeeded(data[:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+		lines = append(lines, line)
+		data = data[end+1:]
+	}
+}
+
+// journalEntry はジャーナルの1行で、1回の保存による変更を表します。変更のなかった項目は省略されます。
+type journalEntry struct {
+	Revision  int64     `json:"revision"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Delete は削除したタスクのIDです。Put より先に適用します。
+	Delete []string `json:"delete,omitempty"`
+	// Put は追加または変更したタスクです。既存のタスクは同じ位置で置き換え、新しいタスクは末尾に追加します。
+	Put []task.Task `json:"put,omitempty"`
+	// Order は Delete と Put の適用後の並び順が保存したタスクデータと異なる場合の、すべてのタスクのIDの並びです。
+	Order       []string                        `json:"order,omitempty"`
+	Settings    *task.Settings                  `json:"settings,omitempty"`
+	TagRegistry *map[string]task.TagInfo        `json:"tag_registry,omitempty"`
+	History     *sliceChange[task.HistoryEntry] `json:"history,omitempty"`
+	UndoStack   *sliceChange[task.Operation]    `json:"undo_stack,omitempty"`
+	RedoStack   *sliceChange[task.Operation]    `json:"redo_stack,omitempty"`
+	Tombstones  *sliceChange[task.Tombstone]    `json:"tombstones,omitempty"`
+	Conflicts   *[]task.SyncConflict            `json:"conflicts,omitempty"`
+}
+
+// sliceChange はスライスの変更を、先頭と末尾から取り除く要素の数と末尾に追加する要素で表します。
+// 変更履歴や取り消し用のスタックは末尾への追加と両端からの削除で変化するため、全体を書き出さずに済みます。
+type sliceChange[T any] struct {
+	Drop     int `json:"drop,omitempty"`     // 先頭から取り除く要素の数
+	Truncate int `json:"truncate,omitempty"` // 末尾から取り除く要素の数
+	Append   []T `json:"append,omitempty"`
+}
+
+// diffSlice は old を new に変える変更を返します。変更がない場合は nil を返します。
+func diffSlice[T any](old, new []T, equal func(a, b *T) bool) *sliceChange[T] {
+	// new の先頭と一致する old の位置から、両方に共通する範囲を求める
+	drop, keep := len(old), 0
+	if len(new) > 0 {
+		for i := range old {
+			if equal(&old[i], &new[0]) {
+				drop, keep = i, 1
+				for i+keep < len(old) && keep < len(new) && equal(&old[i+keep], &new[keep]) {
+					keep++
+				}
+				break
+			}
+		}
+	}
+	c := &sliceChange[T]{Drop: drop, Truncate: len(old) - drop - keep, Append: new[keep:]}
+	if c.Drop == 0 && c.Truncate == 0 && len(c.Append) == 0 {
+		return nil
+	}
+	return c
+}
+
+// apply は s に変更を適用したスライスを返します。s の配列は再利用されます。
+func (c *sliceChange[T]) apply(s []T) ([]T, error) {
+	if c.Drop < 0 || c.Truncate < 0 || c.Drop+c.Truncate > len(s) {
+		return nil, fmt.Errorf("cannot remove %d+%d of %d elements", c.Drop, c.Truncate, len(s))
+	}
+	return append(s[c.Drop:len(s)-c.Truncate], c.Append...), nil
+}
+
+// replayer はジャーナルの変更をタスクデータに順に適用します。
+type replayer struct {
+	tasks *task.Tasks
+	pos   map[string]int // タスクID → tasks.Tasks 内の位置
+}
+
+// newReplayer は tasks に変更を適用する replayer を作成します。
+func newReplayer(tasks *task.Tasks) *replayer {
+	r := &replayer{tasks: tasks, pos: make(map[string]int, len(tasks.Tasks))}
+	r.reposition(0)
+	return r
+}
+
+// reposition は from 以降のタスクの位置を記録し直します。
+func (r *replayer) reposition(from int) {
+	for i := from; i < len(r.tasks.Tasks); i++ {
+		r.pos[r.tasks.Tasks[i].ID] = i
+	}
+}
+
+// apply はジャーナルの1件の変更を適用します。
+func (r *replayer) apply(e *journalEntry) error {
+	tasks := r.tasks
+	if len(e.Delete) > 0 {
+		deleted := make(map[string]bool, len(e.Delete))
+		first := len(tasks.Tasks)
+		for _, id := range e.Delete {
+			i, ok := r.pos[id]
+			if !ok {
+				return fmt.Errorf("deleted task %s does not exist", id)
+			}
+			deleted[id] = true
+			first = min(first, i)
+			delete(r.pos, id)
+		}
+		kept := tasks.Tasks[:first]
+		for _, t := range tasks.Tasks[first:] {
+			if !deleted[t.ID] {
+				kept = append(kept, t)
+			}
+		}
+		clear(tasks.Tasks[len(kept):])
+		tasks.Tasks = kept
+		r.reposition(first)
+	}
+
+	for _, t := range e.Put {
+		if i, ok := r.pos[t.ID]; ok {
+			tasks.Tasks[i] = t
+			continue
+		}
+		r.pos[t.ID] = len(tasks.Tasks)
+		tasks.Tasks = append(tasks.Tasks, t)
+	}
+
+	if e.Order != nil {
+		if len(e.Order) != len(tasks.Tasks) {
+			return fmt.Errorf("order lists %d tasks, want %d", len(e.Order), len(tasks.Tasks))
+		}
+		ordered := make([]task.Task, len(e.Order))
+		for i, id := range e.Order {
+			j, ok := r.pos[id]
+			if !ok {
+				return fmt.Errorf("ordered task %s does not exist", id)
+			}
+			ordered[i] = tasks.Tasks[j]
+		}
+		tasks.Tasks = ordered
+		r.reposition(0)
+	}
+
+	if e.Settings != nil {
+		tasks.Settings = *e.Settings
+	}
+	if e.TagRegistry != nil {
+		tasks.TagRegistry = *e.TagRegistry
+		if len(tasks.TagRegistry) == 0 {
+			tasks.TagRegistry = nil
+		}
+	}
+	var err error
+	if e.History != nil {
+		if tasks.History, err = e.History.apply(tasks.History); err != nil {
+			return fmt.Errorf("history: %w", err)
+		}
+	}
+	if e.UndoStack != nil {
+		if tasks.UndoStack, err = e.UndoStack.apply(tasks.UndoStack); err != nil {
+			return fmt.Errorf("undo stack: %w", err)
+		}
+	}
+	if e.RedoStack != nil {
+		if tasks.RedoStack, err = e.RedoStack.apply(tasks.RedoStack); err != nil {
+			return fmt.Errorf("redo stack: %w", err)
+		}
+	}
+	if e.Tombstones != nil {
+		if tasks.Tombstones, err = e.Tombstones.apply(tasks.Tombstones); err != nil {
+			return fmt.Errorf("tombstones: %w", err)
+		}
+	}
+	if e.Conflicts != nil {
+		tasks.Conflicts = *e.Conflicts
+		if len(tasks.Conflicts) == 0 {
+			tasks.Conflicts = nil
+		}
+	}
+	tasks.Revision = e.Revision
+	tasks.UpdatedAt = e.UpdatedAt
+	return nil
+}
+
+// journalState は最後に読み込んだ、または保存したタスクデータの写しです。
+// App はタスクのスライスなどをその場で書き換えることがあるため、タスクは複製して保持します。
+// 保存した変更は読み込み時と同じ replayer で写しに適用します。
+type journalState struct {
+	tasks *task.Tasks
+	r     *replayer
+}
+
+// newJournalState はタスクデータの写しを作成します。
+func newJournalState(tasks *task.Tasks) *journalState {
+	c := *tasks
+	c.Tasks = make([]task.Task, len(tasks.Tasks))
+	for i, t := range tasks.Tasks {
+		c.Tasks[i] = copyTask(t)
+	}
+	c.TagRegistry = maps.Clone(tasks.TagRegistry)
+	c.History = slices.Clone(tasks.History)
+	c.UndoStack = slices.Clone(tasks.UndoStack)
+	c.RedoStack = slices.Clone(tasks.RedoStack)
+	c.Tombstones = slices.Clone(tasks.Tombstones)
+	c.Conflicts = slices.Clone(tasks.Conflicts)
+	return &journalState{tasks: &c, r: newReplayer(&c)}
+}
+
+// diff は写しから tasks への変更を表すジャーナルの行を返します。行のリビジョンと更新日時には tasks の値を使用します。
+func (st *journalState) diff(tasks *task.Tasks) *journalEntry {
+	entry := &journalEntry{Revision: tasks.Revision, UpdatedAt: tasks.UpdatedAt}
+	prev := st.tasks.Tasks
+	// 写しと同じ位置に同じタスクがあるうちは索引を引かずに比較する
+	aligned := len(tasks.Tasks) >= len(prev)
+	for i := range tasks.Tasks {
+		t := &tasks.Tasks[i]
+		var old *task.Task
+		if i < len(prev) && prev[i].ID == t.ID {
+			old = &prev[i]
+		} else {
+			if i < len(prev) {
+				aligned = false
+			}
+			if j, ok := st.r.pos[t.ID]; ok {
+				old = &prev[j]
+			}
+		}
+		if old == nil || !sameTask(old, t) {
+			entry.Put = append(entry.Put, *t)
+		}
+	}
+
+	// 既存のタスクがすべて元の位置にあり、新しいタスクが末尾に追加されただけの場合は、削除も並びの変更もない
+	if !aligned {
+		present := make(map[string]bool, len(tasks.Tasks))
+		for i := range tasks.Tasks {
+			present[tasks.Tasks[i].ID] = true
+		}
+		expected := make([]string, 0, len(tasks.Tasks))
+		for i := range prev {
+			if present[prev[i].ID] {
+				expected = append(expected, prev[i].ID)
+			} else {
+				entry.Delete = append(entry.Delete, prev[i].ID)
+			}
+		}
+		for i := range tasks.Tasks {
+			if _, ok := st.r.pos[tasks.Tasks[i].ID]; !ok {
+				expected = append(expected, tasks.Tasks[i].ID)
+			}
+		}
+		// Delete と Put を適用した後の並びが異なる場合 (取り消しで削除したタスクを元の位置に戻した場合など) は並びを記録する
+		for i, id := range expected {
+			if tasks.Tasks[i].ID != id {
+				entry.Order = make([]string, len(tasks.Tasks))
+				for j := range tasks.Tasks {
+					entry.Order[j] = tasks.Tasks[j].ID
+				}
+				break
+			}
+		}
+	}
+
+	if tasks.Settings != st.tasks.Settings {
+		settings := tasks.Settings
+		entry.Settings = &settings
+	}
+	if !maps.Equal(tasks.TagRegistry, st.tasks.TagRegistry) {
+		registry := tasks.TagRegistry
+		if registry == nil {
+			registry = map[string]task.TagInfo{} // null では変更として読み込まれないため空のマップを記録する
+		}
+		entry.TagRegistry = &registry
+	}
+	entry.History = diffSlice(st.tasks.History, tasks.History, func(a, b *task.HistoryEntry) bool { return *a == *b })
+	entry.UndoStack = diffSlice(st.tasks.UndoStack, tasks.UndoStack, sameOperation)
+	entry.RedoStack = diffSlice(st.tasks.RedoStack, tasks.RedoStack, sameOperation)
+	entry.Tombstones = diffSlice(st.tasks.Tombstones, tasks.Tombstones, func(a, b *task.Tombstone) bool { return *a == *b })
+	if !reflect.DeepEqual(tasks.Conflicts, st.tasks.Conflicts) {
+		conflicts := tasks.Conflicts
+		if conflicts == nil {
+			conflicts = []task.SyncConflict{} // null では変更として読み込まれないため空のスライスを記録する
+		}
+		entry.Conflicts = &conflicts
+	}
+	return entry
+}
+
+// commit は保存したジャーナルの行を写しに適用します。行のタスクとタグの登録は App と共有しないよう複製します。
+func (st *journalState) commit(e *journalEntry) {
+	c := *e
+	c.Put = make([]task.Task, len(e.Put))
+	for i, t := range e.Put {
+		c.Put[i] = copyTask(t)
+	}
+	if e.TagRegistry != nil {
+		registry := maps.Clone(*e.TagRegistry)
+		c.TagRegistry = &registry
+	}
+	if e.Conflicts != nil {
+		conflicts := slices.Clone(*e.Conflicts)
+		c.Conflicts = &conflicts
+	}
+	// diff が求めた変更は写しに必ず適用できる
+	_ = st.r.apply(&c)
+}
+
+// copyTask はスライスとポインタのフィールドを複製したタスクを返します。
+func copyTask(t task.Task) task.Task {
+	t.Tags = slices.Clone(t.Tags)
+	t.Depends = slices.Clone(t.Depends)
+	t.Checklist = slices.Clone(t.Checklist)
+	t.CompletedAt = copyTime(t.CompletedAt)
+	t.DueDate = copyTime(t.DueDate)
+	t.WaitUntil = copyTime(t.WaitUntil)
+	return t
+}
+
+// copyTime は日時のポインタを複製します。
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// sameTask は2つのタスクのすべてのフィールドが等しいかを返します。
+// 大量のタスクを保存のたびに比較するため reflect.DeepEqual は使用しません。task.Task にフィールドを追加した場合はここにも追加してください。
+func sameTask(a, b *task.Task) bool {
+	return a.ID == b.ID && a.Title == b.Title && a.Description == b.Description &&
+		a.Status == b.Status && a.Priority == b.Priority &&
+		a.CreatedAt == b.CreatedAt && a.UpdatedAt == b.UpdatedAt &&
+		sameTime(a.CompletedAt, b.CompletedAt) && sameTime(a.DueDate, b.DueDate) && sameTime(a.WaitUntil, b.WaitUntil) &&
+		slices.Equal(a.Tags, b.Tags) && slices.Equal(a.Depends, b.Depends) && slices.Equal(a.Checklist, b.Checklist)
+}
+
+// sameTime は2つの日時のポインタが同じ日時を指しているか、どちらも nil かを返します。
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameOperation は取り消し用の操作が等しいかを返します。
+// 操作の記録は変更されないため、多くの場合はスライスが同じ配列を指していることで速やかに判定されます。
+func sameOperation(a, b *task.Operation) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go-task/internal/paths"
+)
+
+// localDir はリポジトリなどのプロジェクト固有のタスクデータを保存するディレクトリ名です。
+const localDir = paths.LegacyDir
+
+// FindLocalDir は start から親ディレクトリに向かって .go-task/tasks.json を探し、見つかった .go-task ディレクトリのパスを返します。
+// グローバルなデータディレクトリ (~/.go-task など) はローカルのタスクファイルとして扱いません。
+func FindLocalDir(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	globalDir, _ := GetConfigDirPath()
+	homeDir, _ := os.UserHomeDir()
+
+	for {
+		candidate := filepath.Join(dir, localDir)
+		if candidate != globalDir && dir != homeDir {
+			if info, err := os.Stat(filepath.Join(candidate, dataFile)); err == nil && !info.IsDir() {
+				return candidate, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// InitLocal は dir に空のタスクファイル (.go-task/tasks.json) を作成し、作成した .go-task ディレクトリのパスを返します。
+// 既にタスクファイルがある場合はエラーを返します。
+func InitLocal(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory %s: %w", dir, err)
+	}
+	target := filepath.Join(abs, localDir)
+	if globalDir, err := GetConfigDirPath(); err == nil && target == globalDir {
+		return "", fmt.Errorf("%s is the global data directory", target)
+	}
+	s := NewFileStore(target)
+	if _, err := os.Stat(s.DataFilePath()); err == nil {
+		return "", fmt.Errorf("local task file already exists: %s", s.DataFilePath())
+	}
+	if err := s.Save(NewTasks()); err != nil {
+		return "", err
+	}
+	return target, nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockFile          = "tasks.json.lock"
+	lockTimeout       = 5 * time.Second       // ロックの取得を待つ最大時間
+	lockRetryInterval = 20 * time.Millisecond // ロックの取得を再試行する間隔
+	staleLockAge      = 10 * time.Minute      // これより古いロックファイルは放棄されたものとみなす
+)
+
+// ErrLocked は他のプロセスがロックを保持し続けているためにロックを取得できなかったことを表します。
+var ErrLocked = errors.New("data file is locked by another go-task process")
+
+// ErrConflict は読み込んだ後に他のプロセスがデータファイルを更新したため、保存を中止したことを表します。
+var ErrConflict = errors.New("data file was modified by another go-task process")
+
+// acquirePIDLock はロックファイルを排他的に作成してロックを取得し、解放する関数を返します。
+// ロックファイルには保持しているプロセスの PID を書き込み、そのプロセスが終了している場合や
+// ロックファイルが staleLockAge より古い場合は放棄されたロックとして削除してから取得し直します。
+func acquirePIDLock(path string, timeout time.Duration) (func() error, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePerm)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() error { return os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+
+		if isStaleLock(path) {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// isStaleLock はロックファイルを作成したプロセスが既に終了しているか、ロックファイルが古すぎるかを返します。
+func isStaleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false // 既に削除されている場合は再試行で取得できる
+	}
+	if time.Since(info.ModTime()) > staleLockAge {
+		return true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// 書き込み途中の可能性があるため、内容が読めないだけでは放棄されたとみなさない
+		return false
+	}
+	return !processAlive(pid)
+}
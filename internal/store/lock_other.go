@@ -0,0 +1,22 @@
+//go:build !unix
+
+package store
+
+import (
+	"os"
+	"time"
+)
+
+// acquireLock は flock を使用できない環境で、PID を記録したロックファイルによってロックを取得します。
+func acquireLock(path string, timeout time.Duration) (func() error, error) {
+	return acquirePIDLock(path, timeout)
+}
+
+// processAlive は指定された PID のプロセスが実行中かを返します。
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	return err == nil && p != nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-task/internal/task"
+)
+
+// MemoryStore はタスクデータをメモリ上に保持する Store の実装です。
+// ファイルシステムやホームディレクトリに依存しないため、テストや組み込み用途に使用します。
+// 保存時には JSON にシリアライズしたコピーを保持するため、呼び出し元の変更は影響しません。
+type MemoryStore struct {
+	mu        sync.Mutex
+	data      []byte
+	revision  int64
+	backups   []memoryBackup
+	templates []task.Template
+	options   BackupOptions
+	seq       int // バックアップの名前に付ける連番
+}
+
+// memoryBackup はメモリ上に保持するバックアップです。
+type memoryBackup struct {
+	info BackupInfo
+	data []byte
+}
+
+// NewMemoryStore は空の MemoryStore を作成します。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{options: DefaultBackupOptions()}
+}
+
+// Dir は空文字列を返します。MemoryStore はファイルに保存しないため、データディレクトリを持ちません。
+func (s *MemoryStore) Dir() string {
+	return ""
+}
+
+// SetBackupOptions はバックアップの保持ルールを変更します。MemoryStore ではバックアップを圧縮しません。
+func (s *MemoryStore) SetBackupOptions(opts BackupOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options = opts
+}
+
+// Load は保存されているタスクデータのコピーを返します。保存されていない場合は空のタスクデータを返します。
+func (s *MemoryStore) Load() (*task.Tasks, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		return NewTasks(), nil
+	}
+	return UnmarshalTasks(s.data)
+}
+
+// Save はタスクデータのコピーを保持します。
+// FileStore と同様に、読み込んだ後に他の利用者が保存していた場合は ErrConflict を返します。
+func (s *MemoryStore) Save(tasks *task.Tasks) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tasks.Revision != s.revision {
+		return fmt.Errorf("%w (loaded revision %d, current revision %d)", ErrConflict, tasks.Revision, s.revision)
+	}
+
+	prevUpdatedAt := tasks.UpdatedAt
+	tasks.Revision++
+	tasks.UpdatedAt = time.Now() // 更新日時を自動更新
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		tasks.Revision, tasks.UpdatedAt = s.revision, prevUpdatedAt
+		return fmt.Errorf("failed to marshal tasks data: %w", err)
+	}
+	s.data = data
+	s.revision = tasks.Revision
+	return nil
+}
+
+// CreateBackup はタスクデータのコピーをバックアップとして保持します。
+func (s *MemoryStore) CreateBackup(tasks *task.Tasks) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tasks for backup: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.seq++
+	name := fmt.Sprintf("%s%s-%d%s", backupFilePrefix, now.Format(backupFileTimeLayout), s.seq, backupFileSuffix)
+	s.backups = append(s.backups, memoryBackup{
+		info: BackupInfo{Name: name, CreatedAt: now, Size: int64(len(data))},
+		data: data,
+	})
+	return nil
+}
+
+// CleanOldBackups は保持ルールに該当しない古いバックアップを削除します。
+func (s *MemoryStore) CleanOldBackups() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]BackupInfo, len(s.backups))
+	for i, b := range s.backups {
+		infos[len(s.backups)-1-i] = b.info
+	}
+	keep := s.options.Retention.keep(infos)
+	kept := s.backups[:0]
+	for _, b := range s.backups {
+		if keep[b.info.Name] {
+			kept = append(kept, b)
+		}
+	}
+	s.backups = kept
+	return nil
+}
+
+// ListBackups はバックアップの一覧を新しい順に返します。
+func (s *MemoryStore) ListBackups() ([]BackupInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backups := make([]BackupInfo, len(s.backups))
+	for i, b := range s.backups {
+		backups[len(s.backups)-1-i] = b.info
+	}
+	return backups, nil
+}
+
+// LoadBackup は指定された名前のバックアップのコピーを返します。
+func (s *MemoryStore) LoadBackup(name string) (*task.Tasks, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.backups {
+		if b.info.Name == name {
+			return UnmarshalTasks(b.data)
+		}
+	}
+	return nil, fmt.Errorf("backup %q not found", name)
+}
+
+// LoadTemplates は保持しているタスクテンプレートのコピーを返します。
+func (s *MemoryStore) LoadTemplates() ([]task.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]task.Template{}, s.templates...), nil
+}
+
+// SaveTemplates はタスクテンプレートのコピーを保持します。
+func (s *MemoryStore) SaveTemplates(templates []task.Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates = append([]task.Template(nil), templates...)
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-task/internal/task"
+)
+
+// CurrentVersion はこのバージョンの go-task が読み書きするデータファイルのスキーマバージョンです。
+const CurrentVersion = "1.1.0"
+
+// initialVersion はバージョンが記録されていないデータファイルのスキーマバージョンです。
+const initialVersion = "1.0.0"
+
+// premigrationFilePrefix はマイグレーション前のデータファイルを退避するファイル名の接頭辞です。
+// 通常のバックアップとは別の名前にし、古いバックアップの削除対象にならないようにしています。
+const premigrationFilePrefix = "tasks_premigration_"
+
+// ErrUnsupportedVersion はデータファイルがこのバージョンの go-task より新しいスキーマで書かれていることを表します。
+var ErrUnsupportedVersion = errors.New("data file was written by a newer version of go-task")
+
+// ErrUnknownVersion はデータファイルが現在より古いものの、どのマイグレーションも対応していないスキーマバージョンであることを表します。
+var ErrUnknownVersion = errors.New("data file has an unknown schema version")
+
+// migration はあるスキーマバージョンから次のバージョンへの変換です。
+// JSON をデコードしたままの形式で変換するため、フィールドの名前や構造の変更も扱えます。
+type migration struct {
+	from, to string
+	migrate  func(doc map[string]interface{}) error
+}
+
+// migrations はバージョンの古い順に並べたマイグレーションの一覧です。
+var migrations = []migration{
+	{from: "1.0.0", to: "1.1.0", migrate: migrateTo110},
+}
+
+// migrateTo110 はタグの前後の空白を除去して空のタグを削除し、既定の優先度が未設定の場合は MEDIUM にします。
+func migrateTo110(doc map[string]interface{}) error {
+	tasks, _ := doc["tasks"].([]interface{})
+	for _, item := range tasks {
+		t, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected task entry: %v", item)
+		}
+		tags, _ := t["tags"].([]interface{})
+		var cleaned []interface{}
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok && strings.TrimSpace(s) != "" {
+				cleaned = append(cleaned, strings.TrimSpace(s))
+			}
+		}
+		t["tags"] = cleaned
+	}
+
+	settings, _ := doc["settings"].(map[string]interface{})
+	if settings == nil {
+		settings = make(map[string]interface{})
+		doc["settings"] = settings
+	}
+	if p, _ := settings["default_priority"].(string); p == "" {
+		settings["default_priority"] = string(task.PriorityMedium)
+	}
+	return nil
+}
+
+// UnmarshalTasks は JSON 形式のタスクデータを読み込み、古いスキーマの場合は現在のスキーマに変換します。
+// 新しいスキーマで書かれたデータの場合は ErrUnsupportedVersion、変換できない古いスキーマの場合は ErrUnknownVersion を返します。
+func UnmarshalTasks(data []byte) (*task.Tasks, error) {
+	tasks, _, err := decodeTasks(data)
+	return tasks, err
+}
+
+// decodeTasks は JSON 形式のタスクデータを必要に応じてマイグレーションして読み込み、
+// 読み込んだデータの元のスキーマバージョンも返します。
+func decodeTasks(data []byte) (*task.Tasks, string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal tasks data: %w", err)
+	}
+
+	version, _ := doc["version"].(string)
+	if version == "" {
+		version = initialVersion
+	}
+	migrated, err := migrate(doc, version)
+	if err != nil {
+		return nil, version, err
+	}
+	if migrated {
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, version, fmt.Errorf("failed to marshal migrated tasks data: %w", err)
+		}
+	}
+
+	var tasks task.Tasks
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, version, fmt.Errorf("failed to unmarshal tasks data: %w", err)
+	}
+	tasks.Version = CurrentVersion
+	return &tasks, version, nil
+}
+
+// migrate は version のデータを現在のスキーマまで順に変換し、変換を行ったかを返します。
+// 各マイグレーションは from と一致するバージョンのデータにだけ適用し、現在のスキーマまで変換できない場合は ErrUnknownVersion を返します。
+func migrate(doc map[string]interface{}, version string) (bool, error) {
+	cmp, err := compareVersions(version, CurrentVersion)
+	if err != nil {
+		return false, err
+	}
+	if cmp > 0 {
+		return false, fmt.Errorf("%w (file version %s, supported version %s)", ErrUnsupportedVersion, version, CurrentVersion)
+	}
+
+	migrated := false
+	for _, m := range migrations {
+		if version != m.from {
+			continue
+		}
+		if err := m.migrate(doc); err != nil {
+			return false, fmt.Errorf("failed to migrate tasks data from %s to %s: %w", m.from, m.to, err)
+		}
+		version = m.to
+		doc["version"] = version
+		migrated = true
+	}
+	if version != CurrentVersion {
+		return false, fmt.Errorf("%w %s (supported version %s)", ErrUnknownVersion, version, CurrentVersion)
+	}
+	return migrated, nil
+}
+
+// compareVersions は "1.2.3" 形式のバージョンを比較し、a < b なら負、a == b なら 0、a > b なら正の値を返します。
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] - pb[i], nil
+		}
+	}
+	return 0, nil
+}
+
+// parseVersion は "1.2.3" 形式のバージョンを数値に変換します。
+func parseVersion(v string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return parsed, fmt.Errorf("invalid data file version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid data file version %q", v)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// backupBeforeMigration はマイグレーション前のデータファイルをバックアップディレクトリに退避します。
+// 退避はスキーマバージョンごとに一度だけ行い、既存の退避ファイルは上書きしません。
+func (s *FileStore) backupBeforeMigration(data []byte, version string) error {
+	if err := ensureDir(s.BackupDirPath()); err != nil {
+		return err
+	}
+	path := filepath.Join(s.BackupDirPath(), premigrationFilePrefix+version+backupFileSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return nil // 変換前の内容は退避済み
+	}
+	if err := WriteFileAtomic(path, data, filePerm); err != nil {
+		return fmt.Errorf("failed to back up data file before migration: %w", err)
+	}
+	return nil
+}
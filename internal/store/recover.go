@@ -0,0 +1,222 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-task/internal/task"
+)
+
+// ErrCorrupted はデータファイルを解析できないことを表します。
+var ErrCorrupted = errors.New("data file is corrupted")
+
+// quarantineFileInfix は破損したデータファイルを退避するファイル名の、データファイル名の後に続く部分です。
+// 退避先は "tasks.json.corrupt-<日時>" となり、バックアップやテンプレートとは区別されます。
+const quarantineFileInfix = ".corrupt-"
+
+// RecoveryReport は破損したデータファイルの復旧内容です。
+type RecoveryReport struct {
+	QuarantinePath string      // 破損したファイルの退避先。PlanRecovery では空
+	JournalPath    string      // ジャーナルの退避先。ジャーナルがない場合や PlanRecovery では空
+	Backup         string      // 復旧に使用したバックアップの名前。使用しない場合や有効なバックアップがない場合は空
+	FromBackup     []task.Task // バックアップから復旧したタスク
+	Salvaged       []task.Task // 破損したファイルから個別に読み込めたタスク。バックアップの同じIDのタスクより優先されます
+}
+
+// Recoverer は破損したデータファイルの復旧に対応した Store が実装するインターフェースです。
+type Recoverer interface {
+	// PlanRecovery はデータファイルを変更せずに、Recover(true) で復旧される内容を返します。
+	PlanRecovery() (*RecoveryReport, error)
+	// Recover は破損したデータファイルを退避し、読み込めたタスクから新しいデータファイルを作成します。
+	// useBackup が true の場合は最新の有効なバックアップを元にし、破損したファイルから読み込めたタスクで上書きします。
+	Recover(useBackup bool) (*RecoveryReport, error)
+}
+
+// VerifyTasks はタスクデータの各タスクが有効で、IDが重複していないことを検証します。
+func VerifyTasks(tasks *task.Tasks) error {
+	seen := make(map[string]bool, len(tasks.Tasks))
+	for i := range tasks.Tasks {
+		t := &tasks.Tasks[i]
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("task %d (%s): %w", i+1, t.ID, err)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("duplicate task ID %s", t.ID)
+		}
+		seen[t.ID] = true
+	}
+	return nil
+}
+
+// PlanRecovery はデータファイルを変更せずに、Recover(true) で復旧される内容を返します。
+func (s *FileStore) PlanRecovery() (*RecoveryReport, error) {
+	report, _, _, err := s.recovery(true)
+	return report, err
+}
+
+// Recover は破損したデータファイルを退避し、読み込めたタスクから新しいデータファイルを作成します。
+// データファイルが暗号化されていた場合は、復旧したデータファイルも同じパスフレーズで暗号化します。
+func (s *FileStore) Recover(useBackup bool) (*RecoveryReport, error) {
+	unlock, err := acquireLock(filepath.Join(s.dir, lockFile), lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	report, recovered, raw, err := s.recovery(useBackup)
+	if err != nil {
+		return nil, err
+	}
+	data, err := MarshalTasks(recovered)
+	if err == nil {
+		data, err = encryptIf(data, isEncrypted(raw))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 復旧したファイルを書き込む前に、破損したファイルをそのままの内容で退避する
+	report.QuarantinePath = s.DataFilePath() + quarantineFileInfix + time.Now().Format(backupFileTimeLayout)
+	for n := 1; fileExists(report.QuarantinePath); n++ {
+		report.QuarantinePath = fmt.Sprintf("%s%s%s-%d", s.DataFilePath(), quarantineFileInfix, time.Now().Format(backupFileTimeLayout), n)
+	}
+	if err := WriteFileAtomic(report.QuarantinePath, raw, filePerm); err != nil {
+		return nil, fmt.Errorf("failed to quarantine corrupted data file: %w", err)
+	}
+	// ジャーナルの内容は復旧したデータファイルに含まれているか、適用できないため退避する
+	if fileExists(s.JournalFilePath()) {
+		report.JournalPath = s.JournalFilePath() + quarantineFileInfix + time.Now().Format(backupFileTimeLayout)
+		if err := os.Rename(s.JournalFilePath(), report.JournalPath); err != nil {
+			return nil, fmt.Errorf("failed to quarantine journal: %w", err)
+		}
+	}
+	if err := WriteFileAtomic(s.DataFilePath(), data, filePerm); err != nil {
+		return nil, fmt.Errorf("failed to write recovered data file %s: %w", s.DataFilePath(), err)
+	}
+	return report, nil
+}
+
+// recovery は破損したデータファイルから復旧するタスクデータと、その内容の報告、データファイルの元の内容を返します。
+// データファイルが正常でジャーナルだけが破損している場合は、データファイルにジャーナルの読み込めた行までを適用した内容を復旧します。
+// データファイルとジャーナルが正常に読み込める場合はエラーを返します。
+// 暗号化されたデータファイルが破損して復号できない場合は、個別のタスクは取り出さずに、復号したバックアップから復旧します。
+// パスフレーズがない場合や誤っている場合は、復旧せずにそのエラーを返します。
+func (s *FileStore) recovery(useBackup bool) (*RecoveryReport, *task.Tasks, []byte, error) {
+	raw, err := os.ReadFile(s.DataFilePath())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read data file %s: %w", s.DataFilePath(), err)
+	}
+	encrypted := isEncrypted(raw)
+	if encrypted && !keys.hasPassphrase() {
+		// 復旧したデータファイルの暗号化と、バックアップの復号にパスフレーズが必要
+		return nil, nil, nil, ErrPassphraseRequired
+	}
+	plaintext, _, err := decryptIfNeeded(raw)
+	if err != nil {
+		if !errors.Is(err, ErrCorrupted) {
+			return nil, nil, nil, err
+		}
+		plaintext = nil
+	}
+	tasks, _, err := decodeTasks(plaintext)
+	if errors.Is(err, ErrUnsupportedVersion) || errors.Is(err, ErrUnknownVersion) {
+		return nil, nil, nil, fmt.Errorf("data file %s is not corrupted", s.DataFilePath())
+	}
+	if err == nil {
+		if _, err := s.readJournal(tasks.Revision, newReplayer(tasks).apply); err == nil {
+			return nil, nil, nil, fmt.Errorf("data file %s is not corrupted", s.DataFilePath())
+		} else if !errors.Is(err, ErrCorrupted) {
+			return nil, nil, nil, err
+		}
+		tasks.Version = CurrentVersion
+		tasks.UpdatedAt = time.Now()
+		return &RecoveryReport{Salvaged: tasks.Tasks}, tasks, raw, nil
+	}
+
+	report := &RecoveryReport{Salvaged: salvageTasks(plaintext)}
+	recovered := NewTasks()
+	if useBackup {
+		name, backup, err := s.newestValidBackup()
+		if backup == nil && encrypted && err != nil {
+			// 誤ったパスフレーズで空のデータファイルを暗号化し直さない
+			return nil, nil, nil, err
+		}
+		if backup != nil {
+			report.Backup = name
+			recovered = backup
+		}
+	}
+
+	// 破損したファイルから読み込めたタスクは、バックアップ時点より新しい内容とみなして優先する
+	salvaged := make(map[string]bool, len(report.Salvaged))
+	for _, t := range report.Salvaged {
+		salvaged[t.ID] = true
+	}
+	kept := recovered.Tasks[:0]
+	for _, t := range recovered.Tasks {
+		if !salvaged[t.ID] {
+			kept = append(kept, t)
+			report.FromBackup = append(report.FromBackup, t)
+		}
+	}
+	recovered.Tasks = append(kept, report.Salvaged...)
+	recovered.Version = CurrentVersion
+	recovered.UpdatedAt = time.Now()
+	return report, recovered, raw, nil
+}
+
+// newestValidBackup は読み込みと検証に成功した最新のバックアップの名前と内容を返します。
+// 見つからない場合は nil と、パスフレーズの問題で復号できなかったバックアップがあればそのエラーを返します。
+func (s *FileStore) newestValidBackup() (string, *task.Tasks, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return "", nil, nil
+	}
+	var passphraseErr error
+	for _, b := range backups {
+		tasks, err := s.LoadBackup(b.Name)
+		if err == nil && VerifyTasks(tasks) == nil {
+			return b.Name, tasks, nil
+		}
+		if errors.Is(err, ErrWrongPassphrase) || errors.Is(err, ErrPassphraseRequired) {
+			passphraseErr = err
+		}
+	}
+	return "", nil, passphraseErr
+}
+
+// salvageTasks は破損したタスクデータの "tasks" 配列から、個別に解析と検証ができたタスクを取り出します。
+// 配列の各位置でタスクのオブジェクトとして読み込みを試み、壊れた部分は読み飛ばします。
+// 変更履歴や取り消し用のスタックに含まれるタスクを拾わないよう、"settings" より前の範囲だけを対象にします。
+func salvageTasks(data []byte) []task.Task {
+	start := bytes.Index(data, []byte(`"tasks":`))
+	if start < 0 {
+		return nil
+	}
+	region := data[start:]
+	if end := bytes.Index(region, []byte(`"settings":`)); end >= 0 {
+		region = region[:end]
+	}
+
+	var tasks []task.Task
+	seen := make(map[string]bool)
+	for i := 0; i < len(region); i++ {
+		if region[i] != '{' {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(region[i:]))
+		var t task.Task
+		if err := dec.Decode(&t); err != nil || t.Validate() != nil || seen[t.ID] {
+			continue // 壊れたオブジェクトやチェックリストの項目などは次の位置から探し直す
+		}
+		seen[t.ID] = true
+		tasks = append(tasks, t)
+		i += int(dec.InputOffset()) - 1
+	}
+	return tasks
+}
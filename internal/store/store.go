@@ -5,30 +5,79 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
+	"go-task/internal/paths"
 	"go-task/internal/task"
 )
 
 const (
-	dataDir                          = ".go-task"
 	dataFile                         = "tasks.json"
+	templatesFile                    = "templates.json"
 	backupDir                        = "backup"
+	backupFilePrefix                 = "tasks_backup_"
+	backupFileSuffix                 = ".json"
 	backupFileTimeLayout             = "20060102150405" // YYYYMMDDhhmmss
-	maxBackups                       = 5                // Keep last 5 backups
+	maxBackups                       = 5                // Keep last 5 backups by default
 	filePerm             os.FileMode = 0600
 	dirPerm              os.FileMode = 0700
 )
 
-// GetConfigDirPath は設定ディレクトリのパスを返します。
-func GetConfigDirPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+// DataFileName はデータディレクトリ内のタスクデータのファイル名です。
+const DataFileName = dataFile
+
+// Store はタスクデータの保存先を表します。
+// デフォルトはデータディレクトリの JSON ファイル (FileStore) で、テストや組み込み用途には MemoryStore を使用できます。
+type Store interface {
+	// Load はタスクデータを読み込みます。データが存在しない場合は空のタスクデータを返します。
+	Load() (*task.Tasks, error)
+	// Save はタスクデータを保存します。
+	Save(tasks *task.Tasks) error
+	// CreateBackup はタスクデータのバックアップを作成します。
+	CreateBackup(tasks *task.Tasks) error
+	// CleanOldBackups はバックアップの保持ルールに該当しない古いバックアップを削除します。
+	CleanOldBackups() error
+	// SetBackupOptions はバックアップの保持ルールと圧縮の設定を変更します。
+	SetBackupOptions(opts BackupOptions)
+	// ListBackups はバックアップの一覧を新しい順に返します。
+	ListBackups() ([]BackupInfo, error)
+	// LoadBackup は ListBackups が返した名前のバックアップを読み込みます。
+	LoadBackup(name string) (*task.Tasks, error)
+	// LoadTemplates はタスクテンプレートを読み込みます。
+	LoadTemplates() ([]task.Template, error)
+	// SaveTemplates はタスクテンプレートを保存します。
+	SaveTemplates(templates []task.Template) error
+	// Dir はデータディレクトリのパスを返します。ファイルに保存しない Store では空文字列を返します。
+	Dir() string
+}
+
+// BackupInfo はバックアップの情報を表します。
+type BackupInfo struct {
+	Name      string    // バックアップの名前 (FileStore ではファイル名)
+	CreatedAt time.Time // バックアップの作成日時
+	Size      int64     // バックアップのサイズ (バイト)
+}
+
+// NewTasks はデフォルト設定の空のタスクデータを返します。
+func NewTasks() *task.Tasks {
+	return &task.Tasks{
+		Version:   CurrentVersion,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Tasks:     []task.Task{},
+		Settings: task.Settings{
+			DefaultPriority: task.PriorityMedium,
+			AutoSave:        true,
+			Theme:           "default",
+		},
 	}
-	return filepath.Join(homeDir, dataDir), nil
+}
+
+// GetConfigDirPath はデータディレクトリのパスを返します。
+// パスは --data-dir フラグ、環境変数 GO_TASK_HOME、XDG_DATA_HOME の順に決まります (詳細は paths パッケージを参照)。
+func GetConfigDirPath() (string, error) {
+	return paths.DataDir()
 }
 
 // GetDataFilePath はデータファイルのパスを返します。
@@ -46,81 +95,81 @@ func EnsureDataDirExists() error {
 	if err != nil {
 		return err
 	}
-	if _, err := os.Stat(configDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(configDir, dirPerm); err != nil {
-			return fmt.Errorf("failed to create data directory %s: %w", configDir, err)
+	return ensureDir(configDir)
+}
+
+// ensureDir はディレクトリが存在しない場合に作成します。
+func ensureDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, dirPerm); err != nil {
+			return fmt.Errorf("failed to create data directory %s: %w", dir, err)
 		}
 	}
 	return nil
 }
 
-// LoadTasks はデータファイルからタスクを読み込みます。
-func LoadTasks() (*task.Tasks, error) {
-	filePath, err := GetDataFilePath()
+// DefaultStore はデフォルトのデータディレクトリを使用する FileStore を返します。
+func DefaultStore() (*FileStore, error) {
+	configDir, err := GetConfigDirPath()
 	if err != nil {
 		return nil, err
 	}
+	return NewFileStore(configDir), nil
+}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// ファイルが存在しない場合は新しいTasks構造体を返す
-		return &task.Tasks{
-			Version:   "1.0.0",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			Tasks:     []task.Task{},
-			Settings: task.Settings{
-				DefaultPriority: task.PriorityMedium,
-				AutoSave:        true,
-				Theme:           "default",
-			},
-		}, nil
-	}
-
-	data, err := os.ReadFile(filePath)
+// LoadTasks はデフォルトのデータファイルからタスクを読み込みます。
+func LoadTasks() (*task.Tasks, error) {
+	s, err := DefaultStore()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read data file %s: %w", filePath, err)
-	}
-
-	var tasks task.Tasks
-	if err := json.Unmarshal(data, &tasks); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal tasks data: %w", err)
+		return nil, err
 	}
-
-	return &tasks, nil
+	return s.Load()
 }
 
-// SaveTasks はタスクをデータファイルに保存します。
+// SaveTasks はタスクをデフォルトのデータファイルに保存します。
 func SaveTasks(tasks *task.Tasks) error {
-	if err := EnsureDataDirExists(); err != nil {
-		return err
-	}
-
-	filePath, err := GetDataFilePath()
+	s, err := DefaultStore()
 	if err != nil {
 		return err
 	}
+	return s.Save(tasks)
+}
 
-	tasks.UpdatedAt = time.Now() // 更新日時を自動更新
-
+// MarshalTasks はタスクデータをJSON形式にマーシャルします。
+func MarshalTasks(tasks *task.Tasks) ([]byte, error) {
 	data, err := json.MarshalIndent(tasks, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal tasks data: %w", err)
+		return nil, fmt.Errorf("failed to marshal tasks data: %w", err)
 	}
+	return data, nil
+}
 
-	if err := os.WriteFile(filePath, data, filePerm); err != nil {
-		return fmt.Errorf("failed to write data file %s: %w", filePath, err)
+// GetTemplatesFilePath はタスクテンプレートを保存するファイルのパスを返します。
+func GetTemplatesFilePath() (string, error) {
+	configDir, err := GetConfigDirPath()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(configDir, templatesFile), nil
+}
 
-	return nil
+// LoadTemplates はデフォルトのテンプレートファイルからタスクテンプレートを読み込みます。
+// ファイルが存在しない場合は空のリストを返します。
+func LoadTemplates() ([]task.Template, error) {
+	s, err := DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadTemplates()
 }
 
-// MarshalTasks はタスクデータをJSON形式にマーシャルします。
-func MarshalTasks(tasks *task.Tasks) ([]byte, error) {
-	data, err := json.MarshalIndent(tasks, "", "  ")
+// SaveTemplates はタスクテンプレートをデフォルトのテンプレートファイルに保存します。
+func SaveTemplates(templates []task.Template) error {
+	s, err := DefaultStore()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal tasks data: %w", err)
+		return err
 	}
-	return data, nil
+	return s.SaveTemplates(templates)
 }
 
 // GetBackupDirPath はバックアップディレクトリのパスを返します。
@@ -138,95 +187,51 @@ func EnsureBackupDirExists() error {
 	if err != nil {
 		return err
 	}
-	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(backupPath, dirPerm); err != nil {
-			return fmt.Errorf("failed to create backup directory %s: %w", backupPath, err)
-		}
-	}
-	return nil
+	return ensureDir(backupPath)
 }
 
-// CreateBackup は現在のタスクデータのバックアップを作成します。
+// CreateBackup は現在のタスクデータのバックアップをデフォルトのバックアップディレクトリに作成します。
 func CreateBackup(tasks *task.Tasks) error {
-	if err := EnsureBackupDirExists(); err != nil {
-		return err
-	}
-
-	backupPath, err := GetBackupDirPath()
+	s, err := DefaultStore()
 	if err != nil {
 		return err
 	}
-
-	timestamp := time.Now().Format(backupFileTimeLayout)
-	backupFileName := fmt.Sprintf("tasks_backup_%s.json", timestamp)
-	backupFilePath := filepath.Join(backupPath, backupFileName)
-
-	data, err := MarshalTasks(tasks)
-	if err != nil {
-		return fmt.Errorf("failed to marshal tasks for backup: %w", err)
-	}
-
-	if err := os.WriteFile(backupFilePath, data, filePerm); err != nil {
-		return fmt.Errorf("failed to write backup file %s: %w", backupFilePath, err)
-	}
-
-	return nil
+	return s.CreateBackup(tasks)
 }
 
-// CleanOldBackups は古いバックアップファイルを削除します。
+// CleanOldBackups はデフォルトのバックアップディレクトリから古いバックアップファイルを削除します。
 func CleanOldBackups() error {
-	backupPath, err := GetBackupDirPath()
+	s, err := DefaultStore()
 	if err != nil {
 		return err
 	}
-
-	files, err := os.ReadDir(backupPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // バックアップディレクトリが存在しない場合は何もしない
-		}
-		return fmt.Errorf("failed to read backup directory: %w", err)
-	}
-
-	// バックアップファイルをフィルタリングし、作成日時でソート
-	var backupFiles []os.FileInfo
-	for _, file := range files {
-		if !file.IsDir() && strings.HasPrefix(file.Name(), "tasks_backup_") && strings.HasSuffix(file.Name(), ".json") {
-			info, err := file.Info()
-			if err != nil {
-				continue
-			}
-			backupFiles = append(backupFiles, info)
-		}
-	}
-
-	sort.Slice(backupFiles, func(i, j int) bool {
-		return backupFiles[i].ModTime().Before(backupFiles[j].ModTime())
-	})
-
-	// 古いバックアップを削除
-	if len(backupFiles) > maxBackups {
-		for i := 0; i < len(backupFiles)-maxBackups; i++ {
-			filePath := filepath.Join(backupPath, backupFiles[i].Name())
-			if err := os.Remove(filePath); err != nil {
-				return fmt.Errorf("failed to remove old backup file %s: %w", filePath, err)
-			}
-		}
-	}
-	return nil
+	return s.CleanOldBackups()
 }
 
-// IsPathSafe は指定されたパスがアプリケーションのデータディレクトリ内にあるかを確認します。
+// IsPathSafe は指定されたパスがアプリケーションの既定のデータディレクトリ内にあるかを確認します。
+// ローカルのタスクファイルやワークスペースなど、別のディレクトリを使用する場合は IsPathWithin を使用してください。
 func IsPathSafe(p string) (bool, error) {
 	dataDirPath, err := GetConfigDirPath()
 	if err != nil {
 		return false, fmt.Errorf("failed to get config directory path: %w", err)
 	}
+	return IsPathWithin(dataDirPath, p)
+}
 
+// IsPathWithin は指定されたパスがディレクトリ dir 内にあるかを確認します。dir が空の場合は常に false を返します。
+func IsPathWithin(dir, p string) (bool, error) {
+	if dir == "" {
+		return false, nil
+	}
+	dataDirPath, err := filepath.Abs(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to get absolute path: %w", err)
+	}
 	absolutePath, err := filepath.Abs(p)
 	if err != nil {
 		return false, fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	return strings.HasPrefix(absolutePath, dataDirPath), nil
+	// 名前の前方が一致するだけの別ディレクトリ (~/.go-task-other など) は対象外とする
+	return absolutePath == dataDirPath || strings.HasPrefix(absolutePath, dataDirPath+string(filepath.Separator)), nil
 }
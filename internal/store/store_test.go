@@ -1,12 +1,22 @@
 package store
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"go-task/internal/paths"
 	"go-task/internal/task"
 )
 
@@ -14,6 +24,10 @@ import (
 func setupTestEnv(t *testing.T, tempDir string) {
 	oldHome := os.Getenv("HOME")
 	os.Setenv("HOME", tempDir)
+	// データディレクトリが HOME 以下になるよう、保存先を変更する環境変数を無効にする
+	for _, env := range []string{"GO_TASK_HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(env, "")
+	}
 	t.Cleanup(func() {
 		os.Setenv("HOME", oldHome)
 	})
@@ -27,7 +41,7 @@ func TestGetConfigDirPath(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 	setupTestEnv(t, tmpDir)
 
-	expectedPath := filepath.Join(tmpDir, dataDir)
+	expectedPath := filepath.Join(tmpDir, paths.LegacyDir)
 	path, err := GetConfigDirPath()
 	if err != nil {
 		t.Errorf("GetConfigDirPath() error = %v, wantErr %v", err, false)
@@ -45,7 +59,7 @@ func TestGetDataFilePath(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 	setupTestEnv(t, tmpDir)
 
-	expectedPath := filepath.Join(tmpDir, dataDir, dataFile)
+	expectedPath := filepath.Join(tmpDir, paths.LegacyDir, dataFile)
 	path, err := GetDataFilePath()
 	if err != nil {
 		t.Errorf("GetDataFilePath() error = %v, wantErr %v", err, false)
@@ -63,7 +77,7 @@ func TestEnsureDataDirExists(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 	setupTestEnv(t, tmpDir)
 
-	configDir := filepath.Join(tmpDir, dataDir)
+	configDir := filepath.Join(tmpDir, paths.LegacyDir)
 	os.RemoveAll(configDir) // 確実に存在しない状態にする
 
 	err = EnsureDataDirExists()
@@ -202,3 +216,1373 @@ func TestIsPathSafe(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPathWithin(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		dir, path string
+		expected  bool
+	}{
+		{dir, filepath.Join(dir, "export.csv"), true},
+		{dir, filepath.Join(dir, "..", "export.csv"), false},
+		{dir, dir + "-other", false},
+		{"", filepath.Join(dir, "export.csv"), false},
+	}
+	for _, tt := range tests {
+		if safe, err := IsPathWithin(tt.dir, tt.path); err != nil || safe != tt.expected {
+			t.Errorf("IsPathWithin(%q, %q) = %v, %v, want %v", tt.dir, tt.path, safe, err, tt.expected)
+		}
+	}
+}
+
+// testStore は Store の実装に共通する振る舞いを検証します。
+func testStore(t *testing.T, s Store) {
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(tasks.Tasks) != 0 || tasks.Settings.DefaultPriority != task.PriorityMedium {
+		t.Errorf("Expected empty tasks with default settings on initial load, got %+v", tasks)
+	}
+
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "id-1", Title: "First", Status: task.StatusTODO, Priority: task.PriorityLow})
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	// 保存後の変更は保存先に影響しない
+	tasks.Tasks[0].Title = "Changed"
+	reloaded, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(reloaded.Tasks) != 1 || reloaded.Tasks[0].Title != "First" {
+		t.Errorf("Load() after Save() got %+v", reloaded.Tasks)
+	}
+
+	if err := s.CreateBackup(reloaded); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups() got %v, %v, want 1 backup", backups, err)
+	}
+	backup, err := s.LoadBackup(backups[0].Name)
+	if err != nil || len(backup.Tasks) != 1 || backup.Tasks[0].ID != "id-1" {
+		t.Errorf("LoadBackup() got %+v, %v", backup, err)
+	}
+	if _, err := s.LoadBackup("missing"); err == nil {
+		t.Errorf("LoadBackup() of a missing backup should fail")
+	}
+	if err := s.CleanOldBackups(); err != nil {
+		t.Errorf("CleanOldBackups() error = %v", err)
+	}
+
+	templates := []task.Template{{Name: "weekly", Title: "Weekly review"}}
+	if err := s.SaveTemplates(templates); err != nil {
+		t.Fatalf("SaveTemplates() error = %v", err)
+	}
+	loaded, err := s.LoadTemplates()
+	if err != nil || len(loaded) != 1 || loaded[0].Name != "weekly" {
+		t.Errorf("LoadTemplates() got %+v, %v", loaded, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_filestore_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// ホームディレクトリに依存せず、指定されたディレクトリを使用する
+	dir := filepath.Join(tmpDir, "data")
+	testStore(t, NewFileStore(dir))
+	if _, err := os.Stat(filepath.Join(dir, dataFile)); err != nil {
+		t.Errorf("Expected data file in %s: %v", dir, err)
+	}
+	if _, err := NewFileStore(dir).LoadBackup("../tasks.json"); err == nil {
+		t.Errorf("LoadBackup() outside the backup directory should fail")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	testStore(t, s)
+
+	tasks, _ := s.Load()
+	for i := 0; i < maxBackups+2; i++ {
+		s.CreateBackup(tasks)
+	}
+	s.CleanOldBackups()
+	if backups, _ := s.ListBackups(); len(backups) != maxBackups {
+		t.Errorf("CleanOldBackups() kept %d backups, want %d", len(backups), maxBackups)
+	}
+}
+
+// failAfter は data の先頭 n バイトだけを書き込んでディスクフルを返す書き込み関数を返します。
+func failAfter(n int) func(f *os.File, data []byte) error {
+	return func(f *os.File, data []byte) error {
+		if n > len(data) {
+			n = len(data)
+		}
+		f.Write(data[:n])
+		return syscall.ENOSPC
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_atomic_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "data.json")
+	if err := WriteFileAtomic(path, []byte(`{"v":1}`), filePerm); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != filePerm {
+		t.Errorf("Expected file permission %o, got %o", filePerm, info.Mode().Perm())
+	}
+
+	// 書き込みの途中で失敗しても元のファイルはそのまま残り、一時ファイルも残らない
+	orig := writeData
+	writeData = failAfter(3)
+	t.Cleanup(func() { writeData = orig })
+	if err := WriteFileAtomic(path, []byte(`{"v":2}`), filePerm); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("WriteFileAtomic() error = %v, want ENOSPC", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"v":1}` {
+		t.Errorf("Original file was modified by a partial write: %s", data)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Temporary files were left behind: %v", entries)
+	}
+}
+
+func TestFileStoreSurvivesPartialWrite(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_partial_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewFileStore(tmpDir)
+	tasks := NewTasks()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "id-1", Title: "Keep me", Status: task.StatusTODO, Priority: task.PriorityLow})
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	orig := writeData
+	writeData = failAfter(10)
+	t.Cleanup(func() { writeData = orig })
+
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "id-2", Title: "Lost", Status: task.StatusTODO, Priority: task.PriorityLow})
+	if err := s.Save(tasks); err == nil {
+		t.Fatalf("Save() should fail when the disk is full")
+	}
+	if err := s.CreateBackup(tasks); err == nil {
+		t.Errorf("CreateBackup() should fail when the disk is full")
+	}
+
+	// 前回保存したデータはそのまま読み込める
+	loaded, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() after a failed save error = %v", err)
+	}
+	if len(loaded.Tasks) != 1 || loaded.Tasks[0].Title != "Keep me" {
+		t.Errorf("Load() after a failed save got %+v", loaded.Tasks)
+	}
+	if backups, _ := s.ListBackups(); len(backups) != 0 {
+		t.Errorf("A partial backup was left behind: %v", backups)
+	}
+}
+
+func TestFileStoreConflict(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_conflict_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 2つのプロセスが同じデータファイルを読み込んだ状態を再現する
+	s := NewFileStore(tmpDir)
+	first, _ := s.Load()
+	second, _ := s.Load()
+
+	if err := s.Save(first); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if first.Revision != 1 {
+		t.Errorf("Save() should increment the revision, got %d", first.Revision)
+	}
+	if err := s.Save(second); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save() with a stale revision error = %v, want ErrConflict", err)
+	}
+	// 競合した保存でリビジョンが進むことはなく、再読み込みすれば保存できる
+	if second.Revision != 0 {
+		t.Errorf("Save() changed the revision of a conflicting write: %d", second.Revision)
+	}
+	reloaded, _ := s.Load()
+	if err := s.Save(reloaded); err != nil {
+		t.Errorf("Save() after reload error = %v", err)
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_lock_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, lockFile)
+	unlock, err := acquireLock(path, lockTimeout)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+	if _, err := acquireLock(path, 50*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("acquireLock() while locked error = %v, want ErrLocked", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	unlock, err = acquireLock(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("acquireLock() after unlock error = %v", err)
+	}
+	unlock()
+}
+
+func TestPIDLockStaleDetection(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_pidlock_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, lockFile)
+
+	// 実行中のプロセスが保持しているロックは取得できない
+	os.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), filePerm)
+	if _, err := acquirePIDLock(path, 50*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("acquirePIDLock() held by a live process error = %v, want ErrLocked", err)
+	}
+
+	// 終了したプロセスのロックは放棄されたものとして取得し直す
+	os.WriteFile(path, []byte("999999999\n"), filePerm)
+	unlock, err := acquirePIDLock(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("acquirePIDLock() with a dead owner error = %v", err)
+	}
+	unlock()
+
+	// 古すぎるロックも放棄されたものとみなす
+	os.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), filePerm)
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(path, old, old)
+	unlock, err = acquirePIDLock(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("acquirePIDLock() with an old lock file error = %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("unlock() should remove the lock file")
+	}
+}
+
+func TestMigration(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_migrate_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewFileStore(tmpDir)
+	old := []byte(`{
+  "version": "1.0.0",
+  "tasks": [{"id": "1", "title": "Old task", "status": "TODO", "priority": "HIGH", "tags": [" work ", "", "home"]}],
+  "settings": {"auto_save": true}
+}`)
+	if err := os.WriteFile(s.DataFilePath(), old, filePerm); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if tasks.Version != CurrentVersion {
+		t.Errorf("Load() version = %q, want %q", tasks.Version, CurrentVersion)
+	}
+	if got := strings.Join(tasks.Tasks[0].Tags, ","); got != "work,home" {
+		t.Errorf("Load() tags = %q, want %q", got, "work,home")
+	}
+	if tasks.Settings.DefaultPriority != task.PriorityMedium {
+		t.Errorf("Load() default priority = %q, want %q", tasks.Settings.DefaultPriority, task.PriorityMedium)
+	}
+
+	// 変換前のファイルがそのまま退避され、通常のバックアップとしては扱われない
+	saved, err := os.ReadFile(filepath.Join(s.BackupDirPath(), premigrationFilePrefix+"1.0.0"+backupFileSuffix))
+	if err != nil {
+		t.Fatalf("pre-migration backup not found: %v", err)
+	}
+	if string(saved) != string(old) {
+		t.Errorf("pre-migration backup does not match the original file")
+	}
+	if backups, _ := s.ListBackups(); len(backups) != 0 {
+		t.Errorf("ListBackups() should not include the pre-migration backup, got %v", backups)
+	}
+
+	// 保存すると現在のバージョンで書き込まれ、再読み込みでは変換されない
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, version, err := decodeTasks(mustReadFile(t, s.DataFilePath())); err != nil || version != CurrentVersion {
+		t.Errorf("saved file version = %q (err %v), want %q", version, err, CurrentVersion)
+	}
+}
+
+func TestMigrationRejectsNewerVersion(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_migrate_newer_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewFileStore(tmpDir)
+	newer := []byte(`{"version": "99.0.0", "tasks": []}`)
+	os.WriteFile(s.DataFilePath(), newer, filePerm)
+	if _, err := s.Load(); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Load() of a newer file error = %v, want ErrUnsupportedVersion", err)
+	}
+	if got := mustReadFile(t, s.DataFilePath()); string(got) != string(newer) {
+		t.Errorf("Load() must not modify a newer data file")
+	}
+
+	if _, err := UnmarshalTasks([]byte(`{"version": "one"}`)); err == nil {
+		t.Errorf("UnmarshalTasks() with an invalid version should fail")
+	}
+	// バージョンのないデータは最初のバージョンとして扱う
+	if tasks, err := UnmarshalTasks([]byte(`{"tasks": []}`)); err != nil || tasks.Version != CurrentVersion {
+		t.Errorf("UnmarshalTasks() without version = %v, %v", tasks, err)
+	}
+}
+
+func TestMigrationRejectsUnknownVersion(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_migrate_unknown_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// マイグレーションの対象より古いバージョンと、マイグレーションの間のバージョンはどちらも変換せずに拒否する
+	for _, version := range []string{"0.9.0", "1.0.5"} {
+		data := []byte(`{"version": "` + version + `", "tasks": [{"id": "1", "title": "Task", "tags": [" a "]}]}`)
+		if _, err := UnmarshalTasks(data); !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("UnmarshalTasks() of version %s error = %v, want ErrUnknownVersion", version, err)
+		}
+
+		s := NewFileStore(filepath.Join(tmpDir, version))
+		os.MkdirAll(s.dir, 0700)
+		os.WriteFile(s.DataFilePath(), data, filePerm)
+		if _, err := s.Load(); !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("Load() of version %s error = %v, want ErrUnknownVersion", version, err)
+		}
+		if got := mustReadFile(t, s.DataFilePath()); string(got) != string(data) {
+			t.Errorf("Load() must not modify a data file of version %s", version)
+		}
+	}
+}
+
+// mustReadFile はファイルの内容を読み込み、失敗した場合はテストを終了します。
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestWorkspaces(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_workspace_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	setupTestEnv(t, tmpDir)
+
+	// 既定のワークスペースはデータディレクトリ直下を使用する
+	configDir, _ := GetConfigDirPath()
+	if dir, _ := WorkspaceDir(DefaultWorkspace); dir != configDir {
+		t.Errorf("WorkspaceDir(default) = %q, want %q", dir, configDir)
+	}
+	if err := CreateWorkspace("work"); err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+	if err := CreateWorkspace("work"); !errors.Is(err, ErrWorkspaceExists) {
+		t.Errorf("CreateWorkspace() of an existing workspace error = %v, want ErrWorkspaceExists", err)
+	}
+	for _, name := range []string{"", "../evil", "a/b", ".hidden"} {
+		if err := CreateWorkspace(name); err == nil {
+			t.Errorf("CreateWorkspace(%q) should fail", name)
+		}
+	}
+	CreateWorkspace("home")
+	if names, _ := ListWorkspaces(); strings.Join(names, ",") != "default,home,work" {
+		t.Errorf("ListWorkspaces() = %v", names)
+	}
+
+	// ワークスペースごとに別のファイルに保存される
+	s, err := WorkspaceStore("work")
+	if err != nil {
+		t.Fatalf("WorkspaceStore() error = %v", err)
+	}
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "w1", Title: "Work task"})
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if defaultTasks, _ := LoadTasks(); len(defaultTasks.Tasks) != 0 {
+		t.Errorf("default workspace should not see tasks of another workspace")
+	}
+
+	if err := RenameWorkspace("work", "job"); err != nil {
+		t.Fatalf("RenameWorkspace() error = %v", err)
+	}
+	s, _ = WorkspaceStore("job")
+	if tasks, _ := s.Load(); len(tasks.Tasks) != 1 {
+		t.Errorf("renamed workspace should keep its tasks, got %d", len(tasks.Tasks))
+	}
+	if err := RenameWorkspace(DefaultWorkspace, "x"); err == nil {
+		t.Errorf("RenameWorkspace() of the default workspace should fail")
+	}
+
+	if err := DeleteWorkspace("job"); err != nil {
+		t.Fatalf("DeleteWorkspace() error = %v", err)
+	}
+	if _, err := WorkspaceStore("job"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("WorkspaceStore() of a deleted workspace error = %v, want ErrWorkspaceNotFound", err)
+	}
+	if err := DeleteWorkspace(DefaultWorkspace); err == nil {
+		t.Errorf("DeleteWorkspace() of the default workspace should fail")
+	}
+}
+
+func TestFindLocalDir(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_local_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	home := filepath.Join(tmpDir, "home")
+	setupTestEnv(t, home)
+
+	// ホームディレクトリのグローバルなデータディレクトリはローカルのタスクファイルとして扱わない
+	EnsureDataDirExists()
+	SaveTasks(NewTasks())
+	nested := filepath.Join(home, "src", "repo", "pkg", "sub")
+	os.MkdirAll(nested, dirPerm)
+	if dir, ok := FindLocalDir(nested); ok {
+		t.Errorf("FindLocalDir() found the global data directory %s", dir)
+	}
+
+	repo := filepath.Join(home, "src", "repo")
+	localDir, err := InitLocal(repo)
+	if err != nil {
+		t.Fatalf("InitLocal() error = %v", err)
+	}
+	if localDir != filepath.Join(repo, paths.LegacyDir) {
+		t.Errorf("InitLocal() = %q", localDir)
+	}
+	if _, err := InitLocal(repo); err == nil {
+		t.Errorf("InitLocal() should fail when a local task file already exists")
+	}
+	if dir, ok := FindLocalDir(nested); !ok || dir != localDir {
+		t.Errorf("FindLocalDir() = %q, %v, want %q", dir, ok, localDir)
+	}
+	if _, ok := FindLocalDir(filepath.Join(home, "src")); ok {
+		t.Errorf("FindLocalDir() should not look into subdirectories")
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 の PBKDF2-HMAC-SHA256 のテストベクタ
+	got := fmt.Sprintf("%x", pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 32))
+	if want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"; got != want {
+		t.Errorf("pbkdf2SHA256() = %s, want %s", got, want)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_encryption_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oldIterations := kdfIterations
+	kdfIterations = 1000
+	defer func() { kdfIterations = oldIterations; SetPassphrase("") }()
+
+	s := NewFileStore(tmpDir)
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Call ACME customer"})
+	s.Save(tasks)
+	s.CreateBackup(tasks)
+
+	if err := s.SetEncryption("secret"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+	backups, _ := s.ListBackups()
+	for _, path := range []string{s.DataFilePath(), filepath.Join(s.BackupDirPath(), backups[0].Name)} {
+		if data := mustReadFile(t, path); strings.Contains(string(data), "ACME") || !isEncrypted(data) {
+			t.Errorf("%s should be encrypted", path)
+		}
+	}
+	if encrypted, _ := s.Encrypted(); !encrypted {
+		t.Errorf("Encrypted() = false after SetEncryption()")
+	}
+
+	// 暗号化されたファイルへの保存とバックアップも暗号化される
+	tasks, err = s.Load()
+	if err != nil || tasks.Tasks[0].Title != "Call ACME customer" {
+		t.Fatalf("Load() = %+v, %v", tasks, err)
+	}
+	tasks.Tasks[0].Title = "Call ACME customer again"
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if data := mustReadFile(t, s.DataFilePath()); strings.Contains(string(data), "ACME") {
+		t.Errorf("Save() wrote plaintext to an encrypted data file")
+	}
+
+	// パスフレーズがない場合や誤っている場合は明確なエラーになる
+	SetPassphrase("")
+	if _, err := s.Load(); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Load() without a passphrase error = %v, want ErrPassphraseRequired", err)
+	}
+	SetPassphrase("wrong")
+	if _, err := s.Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Load() with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	// 鍵のローテーション後は古いパスフレーズでは読めない
+	SetPassphrase("secret")
+	if err := s.SetEncryption("rotated"); err != nil {
+		t.Fatalf("SetEncryption() rotate error = %v", err)
+	}
+	SetPassphrase("secret")
+	if _, err := s.LoadBackup(backups[0].Name); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("LoadBackup() with the old passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+	SetPassphrase("rotated")
+	if backup, err := s.LoadBackup(backups[0].Name); err != nil || backup.Tasks[0].Title != "Call ACME customer" {
+		t.Errorf("LoadBackup() after rotation = %+v, %v", backup, err)
+	}
+
+	if err := s.SetEncryption(""); err != nil {
+		t.Fatalf("SetEncryption() disable error = %v", err)
+	}
+	if data := mustReadFile(t, s.DataFilePath()); isEncrypted(data) || !strings.Contains(string(data), "ACME") {
+		t.Errorf("SetEncryption(\"\") should write plaintext")
+	}
+}
+
+func TestSetEncryptionFailureKeepsAllFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_encryption_failure_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oldIterations := kdfIterations
+	kdfIterations = 1000
+	defer func() { kdfIterations = oldIterations; SetPassphrase("") }()
+
+	s := NewFileStore(tmpDir)
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Call ACME customer"})
+	s.Save(tasks)
+	s.CreateBackup(tasks)
+	s.CreateBackup(tasks)
+	if err := s.SetEncryption("secret"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+	snapshot := func() map[string]string {
+		files := make(map[string]string)
+		for _, dir := range []string{tmpDir, s.BackupDirPath()} {
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				if !e.IsDir() && e.Name() != lockFile {
+					files[filepath.Join(dir, e.Name())] = string(mustReadFile(t, filepath.Join(dir, e.Name())))
+				}
+			}
+		}
+		return files
+	}
+	before := snapshot()
+
+	// 3つ目のファイルの書き込みでディスクフルになる
+	orig := writeData
+	writes := 0
+	writeData = func(f *os.File, data []byte) error {
+		if writes++; writes == 3 {
+			return failAfter(10)(f, data)
+		}
+		return orig(f, data)
+	}
+	err = s.SetEncryption("rotated")
+	writeData = orig
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("SetEncryption() error = %v, want ENOSPC", err)
+	}
+
+	// どのファイルも書き換えられず、一時ファイルも残らず、元のパスフレーズで読み込める
+	if after := snapshot(); !reflect.DeepEqual(after, before) {
+		t.Errorf("SetEncryption() changed files after a failed write:\nbefore %v\nafter  %v", before, after)
+	}
+	if _, err := s.Load(); err != nil {
+		t.Errorf("Load() after a failed rotation error = %v", err)
+	}
+	backups, _ := s.ListBackups()
+	for _, b := range backups {
+		if _, err := s.LoadBackup(b.Name); err != nil {
+			t.Errorf("LoadBackup(%s) after a failed rotation error = %v", b.Name, err)
+		}
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	var backups []BackupInfo
+	// 30 分ごとに 10 日分のバックアップ (新しい順)
+	for i := 0; i < 10*48; i++ {
+		createdAt := now.Add(-time.Duration(i) * 30 * time.Minute)
+		backups = append(backups, BackupInfo{Name: createdAt.Format(time.RFC3339), CreatedAt: createdAt})
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   int
+	}{
+		{"最新のみ", RetentionPolicy{}, 1},
+		{"新しい順に3個", RetentionPolicy{Last: 3}, 3},
+		{"24時間", RetentionPolicy{Hourly: 24}, 24},
+		{"7日", RetentionPolicy{Daily: 7}, 7},
+		{"組み合わせ", RetentionPolicy{Last: 2, Hourly: 3, Daily: 3}, 2 + 1 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep := tt.policy.keep(backups)
+			if len(keep) != tt.want {
+				t.Errorf("keep() kept %d backups, want %d", len(keep), tt.want)
+			}
+			if !keep[backups[0].Name] {
+				t.Errorf("keep() should always keep the newest backup")
+			}
+		})
+	}
+}
+
+func TestCompressedBackups(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_backup_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewFileStore(tmpDir)
+	s.SetBackupOptions(BackupOptions{Retention: RetentionPolicy{Last: 2}, Compress: true})
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Compressed"})
+
+	// 同じ秒に作成したバックアップも上書きされない
+	for i := 0; i < 3; i++ {
+		if err := s.CreateBackup(tasks); err != nil {
+			t.Fatalf("CreateBackup() error = %v", err)
+		}
+	}
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("ListBackups() = %v, %v, want 3 backups", backups, err)
+	}
+	if !strings.HasSuffix(backups[0].Name, compressedBackupSuffix) {
+		t.Errorf("backup %s should be compressed", backups[0].Name)
+	}
+	if data := mustReadFile(t, filepath.Join(s.BackupDirPath(), backups[0].Name)); !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		t.Errorf("backup %s is not gzip data", backups[0].Name)
+	}
+	loaded, err := s.LoadBackup(backups[0].Name)
+	if err != nil || len(loaded.Tasks) != 1 || loaded.Tasks[0].Title != "Compressed" {
+		t.Errorf("LoadBackup() = %+v, %v", loaded, err)
+	}
+
+	if err := s.CleanOldBackups(); err != nil {
+		t.Fatalf("CleanOldBackups() error = %v", err)
+	}
+	remaining, _ := s.ListBackups()
+	if len(remaining) != 2 || remaining[0].Name != backups[0].Name {
+		t.Errorf("CleanOldBackups() left %v, want the newest 2 of %v", remaining, backups)
+	}
+}
+
+func TestVerifyTasks(t *testing.T) {
+	valid := &task.Tasks{Tasks: []task.Task{{ID: "1", Title: "A", Status: task.StatusTODO, Priority: task.PriorityLow}}}
+	if err := VerifyTasks(valid); err != nil {
+		t.Errorf("VerifyTasks() error = %v", err)
+	}
+	duplicate := &task.Tasks{Tasks: append(valid.Tasks, valid.Tasks[0])}
+	if err := VerifyTasks(duplicate); err == nil {
+		t.Errorf("VerifyTasks() should reject duplicate IDs")
+	}
+	invalid := &task.Tasks{Tasks: []task.Task{{ID: "1", Status: task.StatusTODO, Priority: task.PriorityLow}}}
+	if err := VerifyTasks(invalid); err == nil {
+		t.Errorf("VerifyTasks() should reject a task without a title")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_recover_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewFileStore(tmpDir)
+	backup := NewTasks()
+	backup.Tasks = []task.Task{
+		{ID: "1", Title: "Old title", Status: task.StatusTODO, Priority: task.PriorityLow},
+		{ID: "3", Title: "Only in backup", Status: task.StatusTODO, Priority: task.PriorityLow},
+	}
+	if err := s.CreateBackup(backup); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	// 2件目の途中で切れたファイル。1件目はチェックリストを含む
+	corrupted := `{"version": "1.1.0", "tasks": [
+  {"id": "1", "title": "New title", "status": "TODO", "priority": "LOW", "checklist": [{"text": "step", "done": false}]},
+  {"id": "2", "title": "Broken`
+	if err := os.WriteFile(s.DataFilePath(), []byte(corrupted), 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+	if _, err := s.Load(); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Load() error = %v, want ErrCorrupted", err)
+	}
+
+	plan, err := s.PlanRecovery()
+	if err != nil {
+		t.Fatalf("PlanRecovery() error = %v", err)
+	}
+	if plan.QuarantinePath != "" || string(mustReadFile(t, s.DataFilePath())) != corrupted {
+		t.Errorf("PlanRecovery() should not change the data file")
+	}
+
+	report, err := s.Recover(true)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if len(report.Salvaged) != 1 || report.Salvaged[0].Title != "New title" {
+		t.Errorf("Recover() Salvaged = %+v, want the first task only", report.Salvaged)
+	}
+	if report.Backup == "" || len(report.FromBackup) != 1 || report.FromBackup[0].ID != "3" {
+		t.Errorf("Recover() Backup = %q, FromBackup = %+v", report.Backup, report.FromBackup)
+	}
+	if string(mustReadFile(t, report.QuarantinePath)) != corrupted {
+		t.Errorf("Recover() should keep the corrupted file at %s", report.QuarantinePath)
+	}
+
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() after Recover() error = %v", err)
+	}
+	titles := make(map[string]string)
+	for _, tk := range tasks.Tasks {
+		titles[tk.ID] = tk.Title
+	}
+	if len(titles) != 2 || titles["1"] != "New title" || titles["3"] != "Only in backup" {
+		t.Errorf("recovered tasks = %v", titles)
+	}
+	if _, err := s.Recover(true); err == nil {
+		t.Errorf("Recover() of a readable data file should fail")
+	}
+}
+
+func TestRecoverEncrypted(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_recover_encrypted_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oldIterations := kdfIterations
+	kdfIterations = 1000
+	defer func() { kdfIterations = oldIterations; SetPassphrase("") }()
+
+	s := NewFileStore(tmpDir)
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Call ACME customer", Status: task.StatusTODO, Priority: task.PriorityLow})
+	s.Save(tasks)
+	if err := s.SetEncryption("secret"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+	tasks, _ = s.Load()
+	if err := s.CreateBackup(tasks); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	intact := mustReadFile(t, s.DataFilePath())
+
+	// 途中で切れたファイルも、暗号文が改ざんされたファイルも、パスフレーズの誤りではなく破損になる
+	var tampered encryptedFile
+	if err := json.Unmarshal(intact, &tampered); err != nil {
+		t.Fatalf("Failed to parse encrypted file: %v", err)
+	}
+	tampered.Ciphertext[0] ^= 0xff
+	tamperedData, _ := json.Marshal(tampered)
+	for name, data := range map[string][]byte{"truncated": intact[:len(intact)/2], "tampered": tamperedData} {
+		os.WriteFile(s.DataFilePath(), data, 0600)
+		if _, err := s.Load(); !errors.Is(err, ErrCorrupted) || errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("Load() of a %s encrypted file error = %v, want ErrCorrupted", name, err)
+		}
+	}
+	SetPassphrase("wrong")
+	if _, err := s.Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Load() with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	// 誤ったパスフレーズやパスフレーズなしでは復旧しない
+	os.WriteFile(s.DataFilePath(), intact[:len(intact)/2], 0600)
+	if _, err := s.Recover(true); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Recover() with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+	SetPassphrase("")
+	if _, err := s.Recover(true); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Recover() without a passphrase error = %v, want ErrPassphraseRequired", err)
+	}
+
+	// 復号したバックアップから復旧し、復旧したファイルも暗号化される
+	SetPassphrase("secret")
+	report, err := s.Recover(true)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if report.Backup == "" || len(report.FromBackup) != 1 {
+		t.Errorf("Recover() = %+v, want the task from the encrypted backup", report)
+	}
+	if data := mustReadFile(t, s.DataFilePath()); !isEncrypted(data) || bytes.Contains(data, []byte("ACME")) {
+		t.Errorf("Recover() should write an encrypted data file")
+	}
+	if recovered, err := s.Load(); err != nil || len(recovered.Tasks) != 1 || recovered.Tasks[0].Title != "Call ACME customer" {
+		t.Errorf("Load() after Recover() = %+v, %v", recovered, err)
+	}
+}
+
+func TestSalvageTasks(t *testing.T) {
+	// 壊れたタスクの後のタスクや、取り消し用のスタックのタスクの扱い
+	data := `{"tasks": [{"id": "1", "title": "A", "status": "TODO", "priority": "LOW"},
+{"id": "2", "title": "B", "status": ##},
+{"id": "3", "title": "C", "status": "DONE", "priority": "HIGH"}],
+"settings": {}, "undo_stack": [{"before": [{"id": "9", "task": {"id": "9", "title": "Deleted", "status": "TODO", "priority": "LOW"}}]}]}`
+	tasks := salvageTasks([]byte(data))
+	if len(tasks) != 2 || tasks[0].ID != "1" || tasks[1].ID != "3" {
+		t.Errorf("salvageTasks() = %+v, want tasks 1 and 3", tasks)
+	}
+	if tasks := salvageTasks([]byte("garbage")); len(tasks) != 0 {
+		t.Errorf("salvageTasks() of garbage = %+v", tasks)
+	}
+}
+
+// assertSameTasks は保存先から読み込み直したタスクデータが want と同じ内容であることを確認します。
+func assertSameTasks(t *testing.T, step string, s Store, want *task.Tasks) {
+	t.Helper()
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("%s: Load() error = %v", step, err)
+	}
+	gotJSON, _ := MarshalTasks(got)
+	wantJSON, _ := MarshalTasks(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("%s: reloaded tasks differ\ngot  %s\nwant %s", step, gotJSON, wantJSON)
+	}
+}
+
+func TestJournalStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_journal_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	testStore(t, NewJournalStore(filepath.Join(tmpDir, "basic"), 0))
+
+	s := NewJournalStore(tmpDir, 100)
+	tasks, _ := s.Load()
+	for i := 1; i <= 4; i++ {
+		tasks.Tasks = append(tasks.Tasks, task.Task{ID: strconv.Itoa(i), Title: fmt.Sprintf("Task %d", i), Tags: []string{"a"}})
+	}
+	// スナップショットがない場合は最初の保存でスナップショットを書き出す
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	snapshot := mustReadFile(t, s.DataFilePath())
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"add", func() { tasks.Tasks = append(tasks.Tasks, task.Task{ID: "5", Title: "Task 5"}) }},
+		// App と同じくタグのスライスをその場で書き換える
+		{"update in place", func() { tasks.Tasks[0].Tags[0] = "b" }},
+		{"delete", func() { tasks.Tasks = append(tasks.Tasks[:1], tasks.Tasks[2:]...) }},
+		{"reorder", func() { tasks.Tasks[0], tasks.Tasks[1] = tasks.Tasks[1], tasks.Tasks[0] }},
+		{"settings and tags", func() {
+			tasks.Settings.Theme = "dark"
+			tasks.TagRegistry = map[string]task.TagInfo{"b": {Color: "1"}}
+		}},
+		{"clear tag registry", func() { tasks.TagRegistry = nil }},
+		{"history", func() {
+			for i := 0; i < 3; i++ {
+				tasks.History = append(tasks.History, task.HistoryEntry{TaskID: "1", Field: "title", NewValue: strconv.Itoa(i)})
+			}
+		}},
+		{"trim history", func() { tasks.History = append(tasks.History[1:], task.HistoryEntry{TaskID: "3", Field: "status"}) }},
+		{"undo", func() {
+			tasks.UndoStack = append(tasks.UndoStack, task.Operation{Name: "delete", Before: []task.TaskSnapshot{{ID: "2"}}})
+		}},
+		{"pop undo", func() {
+			tasks.RedoStack = append(tasks.RedoStack, tasks.UndoStack[len(tasks.UndoStack)-1])
+			tasks.UndoStack = tasks.UndoStack[:len(tasks.UndoStack)-1]
+		}},
+		{"tombstones", func() {
+			tasks.Tombstones = append(tasks.Tombstones, task.Tombstone{ID: "2"}, task.Tombstone{ID: "9"})
+		}},
+		{"sync conflicts", func() {
+			tasks.Tombstones = tasks.Tombstones[1:]
+			tasks.Conflicts = []task.SyncConflict{{TaskID: "1", Fields: []string{"title"}, Local: &task.Task{ID: "1", Title: "local"}}}
+		}},
+		{"resolve conflicts", func() { tasks.Conflicts = nil }},
+		{"no change", func() {}},
+	}
+	for _, c := range changes {
+		c.change()
+		if err := s.Save(tasks); err != nil {
+			t.Fatalf("%s: Save() error = %v", c.name, err)
+		}
+		assertSameTasks(t, c.name, NewFileStore(tmpDir), tasks)
+	}
+	// 変更はジャーナルに1行ずつ追記され、スナップショットは書き換えられない
+	if !bytes.Equal(mustReadFile(t, s.DataFilePath()), snapshot) {
+		t.Errorf("Save() rewrote the snapshot")
+	}
+	if lines := bytes.Count(mustReadFile(t, s.JournalFilePath()), []byte("\n")); lines != len(changes) {
+		t.Errorf("journal has %d lines, want %d", lines, len(changes))
+	}
+
+	// 保存の途中で中断された書きかけの行は無視され、次の保存で切り詰められる
+	f, _ := os.OpenFile(s.JournalFilePath(), os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"revision":`)
+	f.Close()
+	assertSameTasks(t, "torn write", NewFileStore(tmpDir), tasks)
+	tasks.Tasks[0].Title = "After torn write"
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() after torn write error = %v", err)
+	}
+	assertSameTasks(t, "after torn write", NewFileStore(tmpDir), tasks)
+
+	// 他のプロセスの保存との競合を検出する
+	other := NewJournalStore(tmpDir, 100)
+	otherTasks, _ := other.Load()
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := other.Save(otherTasks); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save() with a stale revision error = %v, want ErrConflict", err)
+	}
+
+	// コンパクションでスナップショットを書き出し、ジャーナルを削除する
+	if err := s.Compact(tasks); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if fileExists(s.JournalFilePath()) {
+		t.Errorf("Compact() should remove the journal")
+	}
+	assertSameTasks(t, "compact", NewFileStore(tmpDir), tasks)
+}
+
+func TestJournalStoreCompactAfter(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_journal_compact_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s := NewJournalStore(tmpDir, 3)
+	tasks, _ := s.Load()
+	for i := 0; i < 3; i++ {
+		tasks.Tasks = append(tasks.Tasks, task.Task{ID: strconv.Itoa(i), Title: "Task"})
+		if err := s.Save(tasks); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	// 1回目はスナップショット、2回目と3回目は追記
+	if lines := bytes.Count(mustReadFile(t, s.JournalFilePath()), []byte("\n")); lines != 2 {
+		t.Fatalf("journal has %d lines, want 2", lines)
+	}
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "3", Title: "Task"})
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if fileExists(s.JournalFilePath()) {
+		t.Errorf("Save() should compact the journal after %d entries", 3)
+	}
+	assertSameTasks(t, "compacted", NewFileStore(tmpDir), tasks)
+
+	// json 形式で保存した場合もジャーナルの内容をスナップショットに含めてジャーナルを削除する
+	tasks.Tasks[0].Title = "Journaled"
+	s.Save(tasks)
+	fs := NewFileStore(tmpDir)
+	reloaded, err := fs.Load()
+	if err != nil || reloaded.Tasks[0].Title != "Journaled" {
+		t.Fatalf("FileStore.Load() got %+v, %v", reloaded, err)
+	}
+	if err := fs.Save(reloaded); err != nil {
+		t.Fatalf("FileStore.Save() error = %v", err)
+	}
+	if fileExists(fs.JournalFilePath()) {
+		t.Errorf("FileStore.Save() should remove the journal")
+	}
+	assertSameTasks(t, "json save", fs, reloaded)
+}
+
+func TestJournalStoreEncryptionAndRecovery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_journal_recover_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oldIterations := kdfIterations
+	kdfIterations = 1000
+	defer func() { kdfIterations = oldIterations; SetPassphrase("") }()
+
+	s := NewJournalStore(tmpDir, 100)
+	tasks, _ := s.Load()
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Call ACME customer"})
+	s.Save(tasks)
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "2", Title: "Email ACME"})
+	s.Save(tasks)
+
+	// 暗号化するとジャーナルも行ごとに暗号化される
+	if err := s.SetEncryption("secret"); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+	tasks.Tasks = append(tasks.Tasks, task.Task{ID: "3", Title: "Visit ACME"})
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save() after SetEncryption() error = %v", err)
+	}
+	journal := mustReadFile(t, s.JournalFilePath())
+	if bytes.Contains(journal, []byte("ACME")) || bytes.Count(journal, []byte("\n")) != 2 {
+		t.Errorf("journal should contain 2 encrypted lines, got %s", journal)
+	}
+	assertSameTasks(t, "encrypted", NewFileStore(tmpDir), tasks)
+	if err := s.SetEncryption(""); err != nil {
+		t.Fatalf("SetEncryption() error = %v", err)
+	}
+
+	// 2行目が壊れたジャーナルは、1行目までを適用した内容に復旧する
+	lines := bytes.SplitAfter(mustReadFile(t, s.JournalFilePath()), []byte("\n"))
+	os.WriteFile(s.JournalFilePath(), append(lines[0], []byte("{broken\n")...), 0600)
+	if _, err := NewFileStore(tmpDir).Load(); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Load() error = %v, want ErrCorrupted", err)
+	}
+	report, err := s.Recover(true)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if len(report.Salvaged) != 2 || report.Backup != "" || report.JournalPath == "" || fileExists(s.JournalFilePath()) {
+		t.Errorf("Recover() = %+v, want 2 salvaged tasks and the journal quarantined", report)
+	}
+	if recovered, err := NewFileStore(tmpDir).Load(); err != nil || len(recovered.Tasks) != 2 {
+		t.Errorf("Load() after Recover() got %+v, %v", recovered, err)
+	}
+}
+
+func TestSameTaskCoversAllFields(t *testing.T) {
+	// sameTask はフィールドを列挙して比較するため、task.Task にフィールドを追加した場合は sameTask と copyTask も更新する
+	if n := reflect.TypeOf(task.Task{}).NumField(); n != 13 {
+		t.Errorf("task.Task has %d fields; update sameTask and copyTask, then this test", n)
+	}
+	due := time.Now()
+	a := task.Task{ID: "1", Title: "Task", Tags: []string{"a"}, DueDate: &due, Checklist: []task.ChecklistItem{{Text: "step"}}}
+	b := copyTask(a)
+	if !sameTask(&a, &b) {
+		t.Errorf("sameTask() of a copy = false")
+	}
+	b.Checklist[0].Done = true
+	if sameTask(&a, &b) || a.Checklist[0].Done {
+		t.Errorf("copyTask() should not share the checklist")
+	}
+}
+
+func TestModified(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "go-task_test_modified_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, tt := range []struct {
+		name string
+		open func(dir string) Store
+	}{
+		{"file", func(dir string) Store { return NewFileStore(dir) }},
+		{"journal", func(dir string) Store { return NewJournalStore(dir, 100) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(tmpDir, tt.name)
+			mine, theirs := tt.open(dir), tt.open(dir)
+			watcher := mine.(Watcher)
+			if watcher.Modified() {
+				t.Errorf("Modified() before Load() = true, want false")
+			}
+			tasks, err := mine.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Mine"})
+			if err := mine.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if watcher.Modified() {
+				t.Errorf("Modified() after own Save() = true, want false")
+			}
+
+			other, _ := theirs.Load()
+			other.Tasks = append(other.Tasks, task.Task{ID: "2", Title: "Theirs"})
+			if err := theirs.Save(other); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if !watcher.Modified() {
+				t.Errorf("Modified() after another store saved = false, want true")
+			}
+			if theirs.(Watcher).Modified() {
+				t.Errorf("Modified() of the saving store = true, want false")
+			}
+			if _, err := mine.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if watcher.Modified() {
+				t.Errorf("Modified() after Load() = true, want false")
+			}
+		})
+	}
+}
+
+func TestGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := os.MkdirTemp("", "go-task_test_git_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, tt := range []struct {
+		name string
+		open func(dir string) Store
+	}{
+		{"file", func(dir string) Store { return NewFileStore(dir) }},
+		{"journal", func(dir string) Store { return NewJournalStore(dir, 100) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(tmpDir, tt.name)
+			s := tt.open(dir)
+			g := s.(GitHistory)
+			tasks, _ := s.Load()
+			tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Buy groceries", Status: task.StatusTODO})
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if g.GitEnabled() {
+				t.Fatalf("GitEnabled() before InitGit() = true, want false")
+			}
+			if err := g.InitGit(); err != nil {
+				t.Fatalf("InitGit() error = %v", err)
+			}
+			if !g.GitEnabled() {
+				t.Fatalf("GitEnabled() after InitGit() = false, want true")
+			}
+
+			now := time.Now()
+			tasks.Tasks[0].Status = task.StatusDone
+			tasks.History = append(tasks.History, task.DiffTask(&task.Task{ID: "1", Title: "Buy groceries", Status: task.StatusTODO}, &tasks.Tasks[0], now, "update")...)
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			added := task.Task{ID: "2", Title: "Call mom"}
+			tasks.Tasks = append(tasks.Tasks, added)
+			tasks.History = append(tasks.History, task.DiffTask(nil, &added, now.Add(time.Second), "add")...)
+			tasks.History = append(tasks.History, task.DiffTask(&tasks.Tasks[0], nil, now.Add(time.Second), "delete")...)
+			tasks.Tasks = tasks.Tasks[1:]
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			commits, err := g.GitLog(0)
+			if err != nil {
+				t.Fatalf("GitLog() error = %v", err)
+			}
+			var subjects []string
+			for _, c := range commits {
+				subjects = append(subjects, c.Subject)
+			}
+			want := []string{"add: Call mom (+1 more)", "update: Buy groceries status TODO→DONE", "init: start task history"}
+			if !reflect.DeepEqual(subjects, want) {
+				t.Errorf("GitLog() subjects = %q, want %q", subjects, want)
+			}
+			if commits, _ := g.GitLog(1); len(commits) != 1 {
+				t.Errorf("GitLog(1) returned %d commits, want 1", len(commits))
+			}
+
+			old, err := g.LoadGitCommit(commits[1].Hash)
+			if err != nil {
+				t.Fatalf("LoadGitCommit() error = %v", err)
+			}
+			if len(old.Tasks) != 1 || old.Tasks[0].Status != task.StatusDone {
+				t.Errorf("LoadGitCommit() = %+v, want the single DONE task", old.Tasks)
+			}
+			if _, err := g.LoadGitCommit("no-such-commit"); !errors.Is(err, ErrGit) {
+				t.Errorf("LoadGitCommit() of an unknown commit error = %v, want ErrGit", err)
+			}
+		})
+	}
+}
+
+func TestGitCommitMessage(t *testing.T) {
+	tasks := &task.Tasks{Tasks: []task.Task{{ID: "1", Title: "Renamed"}}}
+	entries := []task.HistoryEntry{
+		{TaskID: "1", Field: "title", OldValue: "Original", NewValue: "Renamed", Source: "update"},
+		{TaskID: "1", Field: "description", OldValue: "", NewValue: strings.Repeat("long\nline ", 10), Source: "update"},
+	}
+	subject, body := gitCommitMessage(tasks, entries)
+	want := "update: Renamed title Original→Renamed, description -→long line long line long line long line…"
+	if subject != want || body != "" {
+		t.Errorf("gitCommitMessage() = %q, %q, want %q", subject, body, want)
+	}
+	if subject, _ := gitCommitMessage(tasks, nil); subject != "update: task data" {
+		t.Errorf("gitCommitMessage() without history = %q", subject)
+	}
+}
+
+func TestGitHistoryEncrypted(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := os.MkdirTemp("", "go-task_test_git_encrypted_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	oldIterations := kdfIterations
+	kdfIterations = 1000
+	defer func() { kdfIterations = oldIterations; SetPassphrase("") }()
+
+	for _, tt := range []struct {
+		name string
+		open func(dir string) Store
+	}{
+		{"file", func(dir string) Store { return NewFileStore(dir) }},
+		{"journal", func(dir string) Store { return NewJournalStore(dir, 100) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPassphrase("")
+			dir := filepath.Join(tmpDir, tt.name)
+			s := tt.open(dir)
+			tasks, _ := s.Load()
+			if err := s.(Encrypter).SetEncryption("secret"); err != nil {
+				t.Fatalf("SetEncryption() error = %v", err)
+			}
+			tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Call ACME customer", Status: task.StatusTODO})
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			g := s.(GitHistory)
+			if err := g.InitGit(); err != nil {
+				t.Fatalf("InitGit() error = %v", err)
+			}
+
+			now := time.Now()
+			tasks.Tasks[0].Status = task.StatusDone
+			tasks.History = append(tasks.History, task.DiffTask(&task.Task{ID: "1", Title: "Call ACME customer", Status: task.StatusTODO}, &tasks.Tasks[0], now, "update")...)
+			added := task.Task{ID: "2", Title: "Email ACME"}
+			tasks.Tasks = append(tasks.Tasks, added)
+			tasks.History = append(tasks.History, task.DiffTask(nil, &added, now, "add")...)
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			commits, err := g.GitLog(0)
+			if err != nil || len(commits) != 2 || commits[0].Subject != "update: 2 task(s)" {
+				t.Fatalf("GitLog() = %+v, %v, want the subject %q", commits, err, "update: 2 task(s)")
+			}
+			// コミットメッセージにも差分にもタイトルや値が平文で残らない
+			out, err := exec.Command("git", "-C", dir, "log", "-p", "--format=%B").Output()
+			if err != nil {
+				t.Fatalf("git log error = %v", err)
+			}
+			if bytes.Contains(out, []byte("ACME")) || bytes.Contains(out, []byte("DONE")) {
+				t.Errorf("git log of an encrypted store contains task content:\n%s", out)
+			}
+		})
+	}
+}
+
+// useFailingGit は PATH の先頭に、常に失敗する git コマンドを置きます。
+func useFailingGit(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'fatal: simulated failure' >&2\nexit 128\n"
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0700); err != nil {
+		t.Fatalf("Failed to write fake git: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGitCommitFailureAfterSave(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := os.MkdirTemp("", "go-task_test_git_fail_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, tt := range []struct {
+		name string
+		open func(dir string) Store
+	}{
+		{"file", func(dir string) Store { return NewFileStore(dir) }},
+		{"journal", func(dir string) Store { return NewJournalStore(dir, 100) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(tmpDir, tt.name)
+			s := tt.open(dir)
+			tasks, _ := s.Load()
+			if err := s.Save(tasks); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if err := s.(GitHistory).InitGit(); err != nil {
+				t.Fatalf("InitGit() error = %v", err)
+			}
+
+			useFailingGit(t)
+			tasks.Tasks = append(tasks.Tasks, task.Task{ID: "1", Title: "Saved anyway", Status: task.StatusTODO})
+			err := s.Save(tasks)
+			if !errors.Is(err, ErrGitCommit) || !errors.Is(err, ErrGit) {
+				t.Fatalf("Save() with a failing git error = %v, want ErrGitCommit", err)
+			}
+			// コミットに失敗してもタスクデータは保存されている
+			assertSameTasks(t, "after a failed commit", tt.open(dir), tasks)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"go-task/internal/task"
+)
+
+// syncBaseDir は同期の共通の祖先を保存するディレクトリです。
+const syncBaseDir = "sync"
+
+// SyncBaseStore は同期先ごとに、前回の同期で両方に書き込んだタスクデータ (共通の祖先) を保存できる Store が実装するインターフェースです。
+type SyncBaseStore interface {
+	// LoadSyncBase は同期先 remote との共通の祖先を読み込みます。まだ同期していない場合は nil を返します。
+	LoadSyncBase(remote string) (*task.Tasks, error)
+	// SaveSyncBase は同期先 remote との共通の祖先を保存します。
+	SaveSyncBase(remote string, base *task.Tasks) error
+}
+
+// syncBasePath は同期先 remote (絶対パス) との共通の祖先を保存するファイルのパスを返します。
+func (s *FileStore) syncBasePath(remote string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(remote)))
+	return filepath.Join(s.dir, syncBaseDir, "base_"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// LoadSyncBase は同期先 remote との共通の祖先を sync/ 以下から読み込みます。
+func (s *FileStore) LoadSyncBase(remote string) (*task.Tasks, error) {
+	path := s.syncBasePath(remote)
+	if !fileExists(path) {
+		return nil, nil
+	}
+	return ReadTasksFile(path)
+}
+
+// SaveSyncBase は同期先 remote との共通の祖先を sync/ 以下に保存します。データファイルが暗号化されている場合は暗号化します。
+func (s *FileStore) SaveSyncBase(remote string, base *task.Tasks) error {
+	path := s.syncBasePath(remote)
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := MarshalTasks(base)
+	if err != nil {
+		return err
+	}
+	encrypted, err := s.Encrypted()
+	if err != nil {
+		return err
+	}
+	if data, err = encryptIf(data, encrypted); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write sync base %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import "go-task/internal/task"
+
+// Watcher は他のプロセスによるタスクデータの変更を検出できる Store が実装するインターフェースです。
+type Watcher interface {
+	// Modified は最後の Load または Save の後に、他のプロセスがタスクデータを変更したかを返します。
+	Modified() bool
+	// Peek は Store の状態を変更せずにタスクデータを読み込みます。Save と並行して別の goroutine から呼び出せます。
+	Peek() (*Snapshot, error)
+	// Adopt は Peek で読み込んだタスクデータを、Load で読み込んだものとして記録します。
+	Adopt(snap *Snapshot)
+}
+
+// Snapshot は Peek で読み込んだタスクデータと、読み込んだ時点のファイルの状態です。
+type Snapshot struct {
+	Tasks *task.Tasks
+	disk  diskState
+}
+
+// fileStamps はデータファイルとジャーナルの fileStamp です。
+type fileStamps struct {
+	snapshot fileStamp
+	journal  fileStamp
+}
+
+// stamps は現在のデータファイルとジャーナルの fileStamp を返します。
+func (s *FileStore) stamps() fileStamps {
+	return fileStamps{snapshot: statFile(s.DataFilePath()), journal: statFile(s.JournalFilePath())}
+}
+
+// Modified はデータファイルまたはジャーナルのサイズか更新日時が、最後の Load または Save の時点から変わっているかを返します。
+// ファイルの内容は読まないため、定期的に呼び出して変更を検出できます。Load も Save もしていない場合は false を返します。
+func (s *FileStore) Modified() bool {
+	return s.seen != nil && s.stamps() != *s.seen
+}
+
+// markSeen は現在のデータファイルとジャーナルの状態を、このプロセスが読み込んだ、または保存した状態として記録します。
+func (s *FileStore) markSeen(stamps fileStamps) {
+	s.seen = &stamps
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultWorkspace は既定のワークスペースの名前です。
+// 既定のワークスペースはデータディレクトリ直下のファイルを使用するため、以前のバージョンのデータをそのまま引き継ぎます。
+const DefaultWorkspace = "default"
+
+// workspacesDir は名前付きワークスペースを保存するデータディレクトリ内のディレクトリです。
+const workspacesDir = "workspaces"
+
+// workspaceNamePattern はワークスペース名に使用できる文字列のパターンです。
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+var (
+	// ErrWorkspaceNotFound は指定されたワークスペースが存在しないことを表します。
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrWorkspaceExists は指定された名前のワークスペースが既に存在することを表します。
+	ErrWorkspaceExists = errors.New("workspace already exists")
+)
+
+// ValidateWorkspaceName はワークスペース名が英数字で始まり、英数字、'-'、'_' のみで構成されているかを確認します。
+func ValidateWorkspaceName(name string) error {
+	if !workspaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %q: use letters, digits, '-' and '_' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// WorkspaceDir はワークスペースのタスクデータとバックアップを保存するディレクトリのパスを返します。
+// 名前が空の場合は既定のワークスペースとして扱います。
+func WorkspaceDir(name string) (string, error) {
+	configDir, err := GetConfigDirPath()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name == DefaultWorkspace {
+		return configDir, nil
+	}
+	if err := ValidateWorkspaceName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, workspacesDir, name), nil
+}
+
+// WorkspaceStore は指定されたワークスペースの FileStore を返します。ワークスペースが存在しない場合は ErrWorkspaceNotFound を返します。
+func WorkspaceStore(name string) (*FileStore, error) {
+	exists, err := WorkspaceExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkspaceNotFound, name)
+	}
+	dir, err := WorkspaceDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileStore(dir), nil
+}
+
+// WorkspaceExists はワークスペースが存在するかを返します。既定のワークスペースは常に存在します。
+func WorkspaceExists(name string) (bool, error) {
+	if name == "" || name == DefaultWorkspace {
+		return true, nil
+	}
+	dir, err := WorkspaceDir(name)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check workspace directory %s: %w", dir, err)
+	}
+	return info.IsDir(), nil
+}
+
+// ListWorkspaces は既定のワークスペースを先頭に、ワークスペースの名前を名前順に返します。
+func ListWorkspaces() ([]string, error) {
+	configDir, err := GetConfigDirPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(configDir, workspacesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read workspaces directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateWorkspaceName(entry.Name()) == nil && entry.Name() != DefaultWorkspace {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultWorkspace}, names...), nil
+}
+
+// CreateWorkspace は空のワークスペースを作成します。
+func CreateWorkspace(name string) error {
+	if err := ValidateWorkspaceName(name); err != nil {
+		return err
+	}
+	exists, err := WorkspaceExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrWorkspaceExists, name)
+	}
+	dir, err := WorkspaceDir(name)
+	if err != nil {
+		return err
+	}
+	return ensureDir(dir)
+}
+
+// DeleteWorkspace はワークスペースのタスクデータとバックアップを削除します。既定のワークスペースは削除できません。
+func DeleteWorkspace(name string) error {
+	if name == DefaultWorkspace {
+		return fmt.Errorf("the %s workspace cannot be deleted", DefaultWorkspace)
+	}
+	dir, err := existingWorkspaceDir(name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete workspace directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// RenameWorkspace はワークスペースの名前を変更します。既定のワークスペースの名前は変更できません。
+func RenameWorkspace(oldName, newName string) error {
+	if oldName == DefaultWorkspace || newName == DefaultWorkspace {
+		return fmt.Errorf("the %s workspace cannot be renamed", DefaultWorkspace)
+	}
+	oldDir, err := existingWorkspaceDir(oldName)
+	if err != nil {
+		return err
+	}
+	if err := ValidateWorkspaceName(newName); err != nil {
+		return err
+	}
+	exists, err := WorkspaceExists(newName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrWorkspaceExists, newName)
+	}
+	newDir, err := WorkspaceDir(newName)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return fmt.Errorf("failed to rename workspace %s to %s: %w", oldName, newName, err)
+	}
+	return nil
+}
+
+// existingWorkspaceDir は存在するワークスペースのディレクトリを返します。
+func existingWorkspaceDir(name string) (string, error) {
+	exists, err := WorkspaceExists(name)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrWorkspaceNotFound, name)
+	}
+	return WorkspaceDir(name)
+}
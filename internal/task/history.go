@@ -0,0 +1,96 @@
+package task
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryEntry はタスクのフィールド単位の変更履歴を表します。
+type HistoryEntry struct {
+	TaskID    string    `json:"task_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"` // 変更を行った操作 (add, update, delete, import, restore など)
+}
+
+// historyFields は変更履歴の対象となるフィールドと、その値を文字列化する関数、src の値を dst に写す関数です。
+// 記録順を安定させるためスライスで定義しています。同期のフィールド単位のマージ (MergeTask) にも使用します。
+// copy はスライスとポインタを共有するため、必要に応じて Clone したタスクを渡してください。
+var historyFields = []struct {
+	name  string
+	value func(t *Task) string
+	copy  func(dst, src *Task)
+}{
+	{"title", func(t *Task) string { return t.Title }, func(dst, src *Task) { dst.Title = src.Title }},
+	{"description", func(t *Task) string { return t.Description }, func(dst, src *Task) { dst.Description = src.Description }},
+	{"status", func(t *Task) string { return string(t.Status) }, func(dst, src *Task) { dst.Status = src.Status }},
+	{"priority", func(t *Task) string { return string(t.Priority) }, func(dst, src *Task) { dst.Priority = src.Priority }},
+	{"tags", func(t *Task) string { return strings.Join(t.Tags, ",") }, func(dst, src *Task) { dst.Tags = src.Tags }},
+	{"completed_at", func(t *Task) string { return formatTimePtr(t.CompletedAt) }, func(dst, src *Task) { dst.CompletedAt = src.CompletedAt }},
+	{"due_date", func(t *Task) string { return formatTimePtr(t.DueDate) }, func(dst, src *Task) { dst.DueDate = src.DueDate }},
+	{"depends", func(t *Task) string { return strings.Join(t.Depends, ",") }, func(dst, src *Task) { dst.Depends = src.Depends }},
+	{"wait_until", func(t *Task) string { return formatTimePtr(t.WaitUntil) }, func(dst, src *Task) { dst.WaitUntil = src.WaitUntil }},
+	{"checklist", func(t *Task) string { return formatChecklist(t.Checklist) }, func(dst, src *Task) { dst.Checklist = src.Checklist }},
+}
+
+// DiffTask は変更前後のタスクを比較し、差分を履歴エントリとして返します。
+// before が nil の場合は作成、after が nil の場合は削除として扱います。
+func DiffTask(before, after *Task, timestamp time.Time, source string) []HistoryEntry {
+	if before == nil && after == nil {
+		return nil
+	}
+	var id string
+	if after != nil {
+		id = after.ID
+	} else {
+		id = before.ID
+	}
+
+	empty := &Task{}
+	if before == nil {
+		before = empty
+	}
+	if after == nil {
+		after = empty
+	}
+
+	var entries []HistoryEntry
+	for _, f := range historyFields {
+		oldValue, newValue := f.value(before), f.value(after)
+		if oldValue == newValue {
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			TaskID:    id,
+			Field:     f.name,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+			Timestamp: timestamp,
+			Source:    source,
+		})
+	}
+	return entries
+}
+
+// formatTimePtr は日時ポインタを履歴用の文字列に変換します。nil の場合は空文字を返します。
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatChecklist はチェックリストを履歴用の文字列 ("[x] 項目1; [ ] 項目2") に変換します。
+func formatChecklist(items []ChecklistItem) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		mark := "[ ]"
+		if item.Done {
+			mark = "[x]"
+		}
+		parts[i] = mark + " " + item.Text
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,65 @@
+package task
+
+import "time"
+
+// Tombstone は削除したタスクのIDと削除日時の記録です。
+type Tombstone struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncConflict は同期で、同じタスクがローカルとリモートの両方で異なる内容に変更されていたことを表します。
+// 一方で削除され、もう一方で変更されていた場合も競合になります。
+type SyncConflict struct {
+	TaskID string `json:"task_id"`
+	// Fields は両方で異なる値に変更されたフィールドの名前 (変更履歴と同じ名前) です。削除と変更の競合では空です。
+	Fields []string `json:"fields,omitempty"`
+	// Local と Remote は同期した時点のローカルとリモートのタスクです。削除されていた側は nil です。
+	Local      *Task     `json:"local,omitempty"`
+	Remote     *Task     `json:"remote,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// MergeTask は共通の祖先 base からローカルとリモートで変更された同じタスクを、フィールド単位で3者間マージします。
+// 一方だけが変更したフィールドはその値を、両方が異なる値に変更したフィールドは更新日時が新しい側の値を使用し、
+// そのフィールドの名前を競合として返します。base が nil の場合 (初めての同期など) は、異なるフィールドは
+// 競合とせずに更新日時が新しい側の値を使用します。
+func MergeTask(base, local, remote *Task) (Task, []string) {
+	newer, older := local, remote
+	if remote.UpdatedAt.After(local.UpdatedAt) {
+		newer, older = remote, local
+	}
+	merged := older.Clone()
+	merged.UpdatedAt = newer.UpdatedAt
+
+	var conflicts []string
+	for _, f := range historyFields {
+		l, r := f.value(local), f.value(remote)
+		switch {
+		case l == r:
+			continue
+		case base == nil:
+			f.copy(&merged, newer)
+		case f.value(base) == l:
+			f.copy(&merged, remote)
+		case f.value(base) == r:
+			f.copy(&merged, local)
+		default:
+			f.copy(&merged, newer)
+			conflicts = append(conflicts, f.name)
+		}
+	}
+	return merged.Clone(), conflicts
+}
+
+// CopyFields は指定された名前 (変更履歴と同じ名前) のフィールドの値を src から dst に写します。
+func CopyFields(dst, src *Task, fields []string) {
+	c := src.Clone()
+	for _, f := range historyFields {
+		for _, name := range fields {
+			if f.name == name {
+				f.copy(dst, &c)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TagSeparator は階層タグ (例: "work/backend") の区切り文字です。
+const TagSeparator = "/"
+
+// TagMatches はタグが指定されたタグ、またはその子孫のタグであるかを返します。
+// 比較は大文字小文字を区別せず、前後の空白は無視します。
+// 例えば "work" は "work" と "work/backend" に一致しますが、"workshop" には一致しません。
+func TagMatches(tag, filter string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return false
+	}
+	return tag == filter || strings.HasPrefix(tag, filter+TagSeparator)
+}
+
+// RenameTag はタグが old またはその子孫の場合に、old の部分を new に置き換えたタグを返します。
+// 置き換えた場合は true を返します。例えば "work/backend" は old="work", new="job" で "job/backend" になります。
+func RenameTag(tag, old, new string) (string, bool) {
+	if !TagMatches(tag, old) {
+		return tag, false
+	}
+	tag = strings.TrimSpace(tag)
+	return strings.TrimSpace(new) + tag[len(strings.TrimSpace(old)):], true
+}
+
+// TagDepth はタグの階層の深さを返します。"work" は 0、"work/backend" は 1 です。
+func TagDepth(tag string) int {
+	return strings.Count(strings.TrimSpace(tag), TagSeparator)
+}
+
+// TagInfo はタグレジストリに登録されたタグのメタデータです。
+type TagInfo struct {
+	Color       string `json:"color,omitempty"` // ANSI カラー番号 (0-255) または "#RRGGBB" 形式
+	Description string `json:"description,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"` // 一覧にタグを表示しない
+}
+
+// Validate はタグのメタデータが有効な値を持っているか検証します。
+func (ti *TagInfo) Validate() error {
+	ti.Description = sanitizeString(ti.Description)
+	if ti.Color == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(ti.Color); err == nil {
+		if n < 0 || n > 255 {
+			return fmt.Errorf("Invalid tag color: %s", ti.Color)
+		}
+		return nil
+	}
+	if hex := strings.TrimPrefix(ti.Color, "#"); hex != ti.Color && (len(hex) == 3 || len(hex) == 6) {
+		if _, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid tag color: %s", ti.Color)
+}
+
+// LookupTag はタグレジストリからタグのメタデータを探します。
+// タグ自体が登録されていない場合は、最も近い親のタグのメタデータを返します。
+func LookupTag(registry map[string]TagInfo, tag string) (TagInfo, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for tag != "" {
+		for name, info := range registry {
+			if strings.ToLower(name) == tag {
+				return info, true
+			}
+		}
+		i := strings.LastIndex(tag, TagSeparator)
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return TagInfo{}, false
+}
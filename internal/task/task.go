@@ -28,24 +28,44 @@ const (
 
 // Task は単一のタスクのデータ構造を定義します。
 type Task struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description,omitempty"`
-	Status      Status     `json:"status"`
-	Priority    Priority   `json:"priority"`
-	Tags        []string   `json:"tags,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"` // 完了時のみ設定されるためポインタ
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Status      Status          `json:"status"`
+	Priority    Priority        `json:"priority"`
+	Tags        []string        `json:"tags,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"` // 完了時のみ設定されるためポインタ
+	DueDate     *time.Time      `json:"due_date,omitempty"`     // 期限が設定されている場合のみ
+	Depends     []string        `json:"depends,omitempty"`      // このタスクが依存するタスクのID
+	WaitUntil   *time.Time      `json:"wait_until,omitempty"`   // この日時まで一覧に表示しない
+	Checklist   []ChecklistItem `json:"checklist,omitempty"`    // 順序付きのチェックリスト (軽量なサブタスク)
+}
+
+// ChecklistItem はタスク内のチェックリストの1項目を表します。
+type ChecklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
 }
 
 // Tasks はタスクのリストと全体データ構造を定義します。
 type Tasks struct {
-	Version   string    `json:"version"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Tasks     []Task    `json:"tasks"`
-	Settings  Settings  `json:"settings"`
+	Version   string         `json:"version"`
+	Revision  int64          `json:"revision"` // 保存のたびに増加し、他のプロセスによる更新の検出に使用する
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Tasks     []Task         `json:"tasks"`
+	Settings  Settings       `json:"settings"`
+	History   []HistoryEntry `json:"history,omitempty"`
+	UndoStack []Operation    `json:"undo_stack,omitempty"`
+	RedoStack []Operation    `json:"redo_stack,omitempty"`
+	// TagRegistry はタグ名とそのメタデータ (色、説明、非表示) の対応です。
+	TagRegistry map[string]TagInfo `json:"tag_registry,omitempty"`
+	// Tombstones は削除したタスクの記録です。同期で他方から削除したタスクが復活しないようにします。
+	Tombstones []Tombstone `json:"tombstones,omitempty"`
+	// Conflicts は同期で検出した、手動での解決を待っている競合です。
+	Conflicts []SyncConflict `json:"conflicts,omitempty"`
 }
 
 // Settings はアプリケーションの設定を定義します。
@@ -53,6 +73,7 @@ type Settings struct {
 	DefaultPriority Priority `json:"default_priority"`
 	AutoSave        bool     `json:"auto_save"`
 	Theme           string   `json:"theme"`
+	StrictTags      bool     `json:"strict_tags,omitempty"` // 未登録のタグを使用した場合に警告する
 }
 
 // Validate はTask構造体のフィールドが有効な値を持っているか検証します。
@@ -73,6 +94,14 @@ func (t *Task) Validate() error {
 		t.Tags[i] = sanitizeString(tag)
 	}
 
+	// サニタイズ: チェックリストの項目から制御文字を除去
+	for i := range t.Checklist {
+		t.Checklist[i].Text = sanitizeString(t.Checklist[i].Text)
+		if t.Checklist[i].Text == "" {
+			return errors.New("Checklist item text cannot be empty")
+		}
+	}
+
 	switch t.Status {
 	case StatusTODO, StatusInProgress, StatusDone, StatusPending:
 		// 有効なステータス
@@ -88,6 +117,47 @@ func (t *Task) Validate() error {
 	return nil
 }
 
+// Clone はタスクのディープコピーを返します。
+func (t Task) Clone() Task {
+	if t.Tags != nil {
+		t.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.CompletedAt != nil {
+		completedAt := *t.CompletedAt
+		t.CompletedAt = &completedAt
+	}
+	if t.DueDate != nil {
+		dueDate := *t.DueDate
+		t.DueDate = &dueDate
+	}
+	if t.WaitUntil != nil {
+		waitUntil := *t.WaitUntil
+		t.WaitUntil = &waitUntil
+	}
+	if t.Depends != nil {
+		t.Depends = append([]string(nil), t.Depends...)
+	}
+	if t.Checklist != nil {
+		t.Checklist = append([]ChecklistItem(nil), t.Checklist...)
+	}
+	return t
+}
+
+// ChecklistProgress はチェックリストの完了済み項目数と全項目数を返します。
+func (t *Task) ChecklistProgress() (done, total int) {
+	for _, item := range t.Checklist {
+		if item.Done {
+			done++
+		}
+	}
+	return done, len(t.Checklist)
+}
+
+// IsWaiting は指定された日時の時点でタスクが待機中 (一覧に表示しない状態) かを返します。
+func (t *Task) IsWaiting(now time.Time) bool {
+	return t.WaitUntil != nil && now.Before(*t.WaitUntil)
+}
+
 // sanitizeString は文字列から制御文字を除去します。
 func sanitizeString(s string) string {
 	return strings.Map(func(r rune) rune {
@@ -101,7 +171,7 @@ func sanitizeString(s string) string {
 }
 
 // SearchTasks はキーワードに基づいてタスクを検索します。
-// タイトルと詳細説明に対して大文字小文字を区別しない部分一致検索を行います。
+// タイトル、詳細説明、チェックリストの項目に対して大文字小文字を区別しない部分一致検索を行います。
 func SearchTasks(tasks []Task, keyword string) []Task {
 	if keyword == "" {
 		return tasks
@@ -117,9 +187,20 @@ func SearchTasks(tasks []Task, keyword string) []Task {
 		// fmt.Printf("Comparing: Title='%s' (lower='%s'), Desc='%s' (lower='%s'), Keyword='%s'\n",
 		// 	t.Title, lowerTitle, t.Description, lowerDescription, lowerKeyword)
 		if strings.Contains(lowerTitle, lowerKeyword) ||
-			strings.Contains(lowerDescription, lowerKeyword) {
+			strings.Contains(lowerDescription, lowerKeyword) ||
+			checklistContains(t.Checklist, lowerKeyword) {
 			foundTasks = append(foundTasks, t)
 		}
 	}
 	return foundTasks
 }
+
+// checklistContains はチェックリストのいずれかの項目が小文字化済みのキーワードを含むかを返します。
+func checklistContains(items []ChecklistItem, lowerKeyword string) bool {
+	for _, item := range items {
+		if strings.Contains(strings.ToLower(item.Text), lowerKeyword) {
+			return true
+		}
+	}
+	return false
+}
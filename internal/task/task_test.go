@@ -1,6 +1,7 @@
 package task
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -82,3 +83,212 @@ func TestTaskValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffTask(t *testing.T) {
+	now := time.Now()
+	before := Task{ID: "id-1", Title: "Old", Status: StatusTODO, Priority: PriorityLow, Tags: []string{"a"}}
+	after := before.Clone()
+	after.Title = "New"
+	after.Tags = append(after.Tags, "b")
+
+	entries := DiffTask(&before, &after, now, "update")
+	if len(entries) != 2 {
+		t.Fatalf("DiffTask() got %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].Field != "title" || entries[0].OldValue != "Old" || entries[0].NewValue != "New" {
+		t.Errorf("DiffTask() unexpected title entry: %+v", entries[0])
+	}
+	if entries[1].Field != "tags" || entries[1].OldValue != "a" || entries[1].NewValue != "a,b" {
+		t.Errorf("DiffTask() unexpected tags entry: %+v", entries[1])
+	}
+	if before.Tags[0] != "a" || len(before.Tags) != 1 {
+		t.Errorf("Clone() shared tags with the original task: %v", before.Tags)
+	}
+
+	// 作成・削除の場合は全ての設定済みフィールドが記録される
+	if created := DiffTask(nil, &before, now, "add"); len(created) != 4 {
+		t.Errorf("DiffTask() for creation got %d entries, want 4", len(created))
+	}
+	if deleted := DiffTask(&before, nil, now, "delete"); len(deleted) != 4 || deleted[0].TaskID != "id-1" {
+		t.Errorf("DiffTask() for deletion got %+v", deleted)
+	}
+}
+
+func TestTaskUrgency(t *testing.T) {
+	c := DefaultUrgencyCoefficients()
+	now := time.Now()
+	base := Task{ID: "id", Title: "Task", Status: StatusTODO, Priority: PriorityMedium, CreatedAt: now}
+
+	if got := base.Urgency(c, now, false, false); got != c.PriorityMedium {
+		t.Errorf("Urgency() got %.2f, want %.2f", got, c.PriorityMedium)
+	}
+
+	// 期限が近いほど、経過日数が長いほど緊急度が高い
+	overdue := base.Clone()
+	past := now.Add(-10 * 24 * time.Hour)
+	overdue.DueDate = &past
+	farFuture := base.Clone()
+	future := now.Add(60 * 24 * time.Hour)
+	farFuture.DueDate = &future
+	if overdue.Urgency(c, now, false, false) <= farFuture.Urgency(c, now, false, false) {
+		t.Errorf("Urgency() expected overdue task to be more urgent than a far future one")
+	}
+	old := base.Clone()
+	old.CreatedAt = now.Add(-365 * 24 * time.Hour)
+	if got, want := old.Urgency(c, now, false, false), c.PriorityMedium+c.Age; got != want {
+		t.Errorf("Urgency() for old task got %.2f, want %.2f", got, want)
+	}
+
+	// 状態・タグ・依存関係
+	inProgress := base.Clone()
+	inProgress.Status = StatusInProgress
+	inProgress.Tags = []string{"a", "b", "c"}
+	if got, want := inProgress.Urgency(c, now, true, false), c.PriorityMedium+c.InProgress+c.Tags+c.Blocking; got != want {
+		t.Errorf("Urgency() got %.2f, want %.2f", got, want)
+	}
+	if got, want := base.Urgency(c, now, false, true), c.PriorityMedium+c.Blocked; got != want {
+		t.Errorf("Urgency() for blocked task got %.2f, want %.2f", got, want)
+	}
+
+	// 完了済みのタスクは常に 0
+	done := base.Clone()
+	done.Status = StatusDone
+	if got := done.Urgency(c, now, true, false); got != 0 {
+		t.Errorf("Urgency() for done task got %.2f, want 0", got)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tpl := Template{
+		Name:        "release",
+		Title:       "Release v{{.version}} ({{date}})",
+		Description: "{{if .notes}}{{.notes}}{{end}}",
+		Priority:    PriorityHigh,
+		Tags:        []string{"release", "{{.team}}"},
+		Checklist:   []string{"Tag v{{.version}}", "Announce"},
+	}
+	if err := tpl.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	vars, err := tpl.Variables()
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+	if got := strings.Join(vars, ","); got != "notes,team,version" {
+		t.Errorf("Variables() got %s, want notes,team,version", got)
+	}
+
+	now := time.Date(2025, 3, 4, 10, 0, 0, 0, time.Local)
+	task, err := tpl.Render(map[string]string{"version": "1.2", "team": "core", "notes": ""}, now)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if task.Title != "Release v1.2 (2025-03-04)" {
+		t.Errorf("Render() title got %q", task.Title)
+	}
+	if task.Tags[1] != "core" || task.Checklist[0].Text != "Tag v1.2" || task.Checklist[0].Done {
+		t.Errorf("Render() got tags %v, checklist %v", task.Tags, task.Checklist)
+	}
+
+	// 変数が入力されていない場合はエラー
+	if _, err := tpl.Render(map[string]string{"version": "1.2"}, now); err == nil {
+		t.Errorf("Render() with missing variables should fail")
+	}
+
+	invalid := Template{Name: "broken", Title: "{{.oops"}
+	if err := invalid.Validate(); err == nil {
+		t.Errorf("Validate() with invalid syntax should fail")
+	}
+}
+
+func TestHierarchicalTags(t *testing.T) {
+	tests := []struct {
+		tag, filter string
+		want        bool
+	}{
+		{"work", "work", true},
+		{"work/backend", "work", true},
+		{"Work/Backend/API", "work/backend", true},
+		{"workshop", "work", false},
+		{"work", "work/backend", false},
+		{"work", "", false},
+	}
+	for _, tt := range tests {
+		if got := TagMatches(tt.tag, tt.filter); got != tt.want {
+			t.Errorf("TagMatches(%q, %q) = %v, want %v", tt.tag, tt.filter, got, tt.want)
+		}
+	}
+
+	if got, ok := RenameTag("work/backend", "work", "job"); !ok || got != "job/backend" {
+		t.Errorf("RenameTag() got %q, %v, want job/backend, true", got, ok)
+	}
+	if got, ok := RenameTag("workshop", "work", "job"); ok || got != "workshop" {
+		t.Errorf("RenameTag() should not rename unrelated tags, got %q", got)
+	}
+}
+
+func TestTagRegistry(t *testing.T) {
+	for _, color := range []string{"", "9", "255", "#fff", "#00ff88"} {
+		info := TagInfo{Color: color}
+		if err := info.Validate(); err != nil {
+			t.Errorf("Validate() with color %q error = %v", color, err)
+		}
+	}
+	for _, color := range []string{"256", "-1", "red", "#12345", "#gggggg"} {
+		info := TagInfo{Color: color}
+		if err := info.Validate(); err == nil {
+			t.Errorf("Validate() with color %q should fail", color)
+		}
+	}
+
+	registry := map[string]TagInfo{
+		"work":         {Color: "12", Description: "Work"},
+		"work/backend": {Color: "9"},
+	}
+	if info, ok := LookupTag(registry, "Work/Backend"); !ok || info.Color != "9" {
+		t.Errorf("LookupTag() got %+v, %v, want the backend tag", info, ok)
+	}
+	if info, ok := LookupTag(registry, "work/frontend/css"); !ok || info.Description != "Work" {
+		t.Errorf("LookupTag() should fall back to the parent tag, got %+v, %v", info, ok)
+	}
+	if _, ok := LookupTag(registry, "home"); ok {
+		t.Errorf("LookupTag() found an unregistered tag")
+	}
+}
+
+func TestMergeTask(t *testing.T) {
+	now := time.Now()
+	base := Task{ID: "id-1", Title: "Base", Status: StatusTODO, Priority: PriorityLow, Tags: []string{"a"}, UpdatedAt: now}
+	local := base.Clone()
+	local.Title = "Local"
+	local.Priority = PriorityHigh
+	local.UpdatedAt = now.Add(time.Minute)
+	remote := base.Clone()
+	remote.Tags = []string{"b"}
+	remote.Priority = PriorityMedium
+	remote.UpdatedAt = now.Add(2 * time.Minute)
+
+	merged, conflicts := MergeTask(&base, &local, &remote)
+	if merged.Title != "Local" || merged.Tags[0] != "b" || !merged.UpdatedAt.Equal(remote.UpdatedAt) {
+		t.Errorf("MergeTask() = %+v, want the local title, the remote tags and the newer update time", merged)
+	}
+	// 両方が変更したフィールドは更新日時が新しい側の値になり、競合として返される
+	if merged.Priority != PriorityMedium || len(conflicts) != 1 || conflicts[0] != "priority" {
+		t.Errorf("MergeTask() priority = %s, conflicts = %v, want MEDIUM and [priority]", merged.Priority, conflicts)
+	}
+	merged.Tags[0] = "changed"
+	if remote.Tags[0] != "b" {
+		t.Errorf("MergeTask() shared tags with the remote task")
+	}
+
+	// 共通の祖先がない場合は競合にしない
+	if merged, conflicts := MergeTask(nil, &local, &remote); merged.Title != "Base" || len(conflicts) != 0 {
+		t.Errorf("MergeTask() without base = %+v, %v, want the newer task without conflicts", merged, conflicts)
+	}
+
+	CopyFields(&local, &remote, []string{"tags", "priority"})
+	if local.Title != "Local" || local.Tags[0] != "b" || local.Priority != PriorityMedium {
+		t.Errorf("CopyFields() = %+v, want only tags and priority copied", local)
+	}
+}
@@ -0,0 +1,156 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Template は繰り返し作成するタスクのひな形を表します。
+// Title、Description、Tags、Checklist には text/template の書式でプレースホルダを記述できます。
+// {{date}} や {{time}} は作成時の日付・時刻に、{{.version}} のような変数は作成時に入力された値に置き換えられます。
+type Template struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Priority    Priority `json:"priority,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Checklist   []string `json:"checklist,omitempty"`
+}
+
+// templateFuncs はテンプレート内で使用できる関数を作成時の日時から生成します。
+func templateFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"date": func() string { return now.Format("2006-01-02") },
+		"time": func() string { return now.Format("15:04") },
+		"year": func() int { return now.Year() },
+	}
+}
+
+// texts はプレースホルダを含み得るテンプレートの文字列を返します。
+func (tpl *Template) texts() []string {
+	texts := []string{tpl.Title, tpl.Description}
+	texts = append(texts, tpl.Tags...)
+	return append(texts, tpl.Checklist...)
+}
+
+// Validate はテンプレートの内容と書式が正しいかを検証します。
+func (tpl *Template) Validate() error {
+	if strings.TrimSpace(tpl.Name) == "" {
+		return errors.New("template name is required")
+	}
+	if strings.TrimSpace(tpl.Title) == "" {
+		return errors.New("template title is required")
+	}
+	switch tpl.Priority {
+	case "", PriorityHigh, PriorityMedium, PriorityLow:
+	default:
+		return fmt.Errorf("invalid template priority: %s", tpl.Priority)
+	}
+	_, err := tpl.Variables()
+	return err
+}
+
+// Variables はテンプレートの作成時に入力が必要な変数名を名前順で返します。
+func (tpl *Template) Variables() ([]string, error) {
+	seen := make(map[string]struct{})
+	for _, text := range tpl.texts() {
+		t, err := template.New(tpl.Name).Funcs(templateFuncs(time.Time{})).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", text, err)
+		}
+		collectFields(t.Tree.Root, seen)
+	}
+
+	vars := make([]string, 0, len(seen))
+	for name := range seen {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+	return vars, nil
+}
+
+// collectFields はテンプレートの構文木をたどり、参照されている変数名 ({{.name}}) を集めます。
+func collectFields(node parse.Node, seen map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, seen)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			collectFields(c, seen)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, seen)
+		}
+	case *parse.FieldNode:
+		seen[n.Ident[0]] = struct{}{}
+	case *parse.IfNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.List, seen)
+		collectFields(n.ElseList, seen)
+	case *parse.RangeNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.List, seen)
+		collectFields(n.ElseList, seen)
+	case *parse.WithNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.List, seen)
+		collectFields(n.ElseList, seen)
+	}
+}
+
+// Render はプレースホルダを置き換えたタスクを作成します。
+// 入力されていない変数がある場合はエラーを返します。ID や状態などは設定されません。
+func (tpl *Template) Render(vars map[string]string, now time.Time) (Task, error) {
+	render := func(text string) (string, error) {
+		t, err := template.New(tpl.Name).Funcs(templateFuncs(now)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid template %q: %w", text, err)
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, vars); err != nil {
+			return "", fmt.Errorf("failed to render template %q: %w", text, err)
+		}
+		return b.String(), nil
+	}
+
+	var t Task
+	var err error
+	if t.Title, err = render(tpl.Title); err != nil {
+		return Task{}, err
+	}
+	if t.Description, err = render(tpl.Description); err != nil {
+		return Task{}, err
+	}
+	t.Priority = tpl.Priority
+	for _, tag := range tpl.Tags {
+		rendered, err := render(tag)
+		if err != nil {
+			return Task{}, err
+		}
+		t.Tags = append(t.Tags, rendered)
+	}
+	for _, item := range tpl.Checklist {
+		rendered, err := render(item)
+		if err != nil {
+			return Task{}, err
+		}
+		t.Checklist = append(t.Checklist, ChecklistItem{Text: rendered})
+	}
+	return t, nil
+}
@@ -0,0 +1,27 @@
+package task
+
+import "time"
+
+// TaskSnapshot は操作前後のある時点でのタスクの状態を表します。
+// Task が nil の場合、その時点でタスクが存在しなかったことを表します。
+type TaskSnapshot struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"` // タスクリスト内の位置 (存在しない場合は -1)
+	Task  *Task  `json:"task,omitempty"`
+}
+
+// Operation は取り消し・やり直しが可能な1回の操作を表します。
+type Operation struct {
+	Name      string         `json:"name"`
+	Timestamp time.Time      `json:"timestamp"`
+	Before    []TaskSnapshot `json:"before"`
+	After     []TaskSnapshot `json:"after"`
+	// TagRegistry はタグの一括変更で書き換えたタグレジストリの操作前後の状態です。登録を変更しない操作では nil です。
+	TagRegistry *TagRegistryChange `json:"tag_registry,omitempty"`
+}
+
+// TagRegistryChange は操作前後のタグレジストリの状態を表します。
+type TagRegistryChange struct {
+	Before map[string]TagInfo `json:"before"`
+	After  map[string]TagInfo `json:"after"`
+}
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"math"
+	"time"
+)
+
+// UrgencyCoefficients は緊急度スコアを計算する際の各要素の係数を定義します。
+// Taskwarrior の urgency を参考にしています。
+type UrgencyCoefficients struct {
+	PriorityHigh   float64 `json:"priority_high"`
+	PriorityMedium float64 `json:"priority_medium"`
+	PriorityLow    float64 `json:"priority_low"`
+	Age            float64 `json:"age"`          // 作成からの経過日数に応じた係数
+	AgeMaxDays     float64 `json:"age_max_days"` // 経過日数の影響が最大になる日数
+	Due            float64 `json:"due"`          // 期限の近さに応じた係数
+	Tags           float64 `json:"tags"`         // タグの有無に応じた係数
+	InProgress     float64 `json:"in_progress"`
+	Pending        float64 `json:"pending"`
+	Blocking       float64 `json:"blocking"` // 他の未完了タスクから依存されている場合
+	Blocked        float64 `json:"blocked"`  // 未完了のタスクに依存している場合
+}
+
+// DefaultUrgencyCoefficients はデフォルトの緊急度係数を返します。
+func DefaultUrgencyCoefficients() UrgencyCoefficients {
+	return UrgencyCoefficients{
+		PriorityHigh:   6.0,
+		PriorityMedium: 3.9,
+		PriorityLow:    1.8,
+		Age:            2.0,
+		AgeMaxDays:     365,
+		Due:            12.0,
+		Tags:           1.0,
+		InProgress:     4.0,
+		Pending:        -3.0,
+		Blocking:       8.0,
+		Blocked:        -5.0,
+	}
+}
+
+// Urgency はタスクの緊急度スコアを計算します。完了済みのタスクは常に 0 です。
+// blocking は他の未完了タスクから依存されているか、blocked は未完了のタスクに依存しているかを表します。
+func (t *Task) Urgency(c UrgencyCoefficients, now time.Time, blocking, blocked bool) float64 {
+	if t.Status == StatusDone {
+		return 0
+	}
+
+	var score float64
+	switch t.Priority {
+	case PriorityHigh:
+		score += c.PriorityHigh
+	case PriorityMedium:
+		score += c.PriorityMedium
+	case PriorityLow:
+		score += c.PriorityLow
+	}
+
+	if c.AgeMaxDays > 0 && !t.CreatedAt.IsZero() {
+		ageDays := now.Sub(t.CreatedAt).Hours() / 24
+		score += c.Age * math.Max(0, math.Min(ageDays/c.AgeMaxDays, 1))
+	}
+
+	if t.DueDate != nil {
+		score += c.Due * dueProximity(*t.DueDate, now)
+	}
+
+	switch n := len(t.Tags); {
+	case n == 1:
+		score += c.Tags * 0.8
+	case n == 2:
+		score += c.Tags * 0.9
+	case n >= 3:
+		score += c.Tags
+	}
+
+	switch t.Status {
+	case StatusInProgress:
+		score += c.InProgress
+	case StatusPending:
+		score += c.Pending
+	}
+
+	if blocking {
+		score += c.Blocking
+	}
+	if blocked {
+		score += c.Blocked
+	}
+	return score
+}
+
+// dueProximity は期限の近さを 0.2〜1.0 の値で返します。
+// 期限の14日以上前は 0.2、期限を7日以上過ぎると 1.0 となり、その間は線形に変化します。
+func dueProximity(due, now time.Time) float64 {
+	daysOverdue := now.Sub(due).Hours() / 24
+	switch {
+	case daysOverdue >= 7:
+		return 1.0
+	case daysOverdue >= -14:
+		return (daysOverdue+14)*0.8/21 + 0.2
+	default:
+		return 0.2
+	}
+}